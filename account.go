@@ -0,0 +1,154 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// A chart is a chart of accounts, the set of account names that transactions can use.
+type chart map[string]bool
+
+// The policies for other accounts not in the chart of accounts.
+const (
+	policyError = "error" // fail to parse the transaction
+	policyMap   = "map"   // map the other account to the default other account, or unknownAcct
+	policyWarn  = "warn"  // write a warning
+	unknownAcct = "Unknown"
+)
+
+var (
+	errAcctChart    = errors.New("account is not in the chart of accounts")
+	errAcctCurrency = errors.New("account currencies must be account=currency pairs separated by commas " +
+		"e.g. \"Assets:Current:PCUS1=NZD,Liabilities:Amex=USD\"")
+	errAcctHierarchy = errors.New("account is not in ledger hierarchy format e.g. \"Assets:Current:Cheque\"")
+	errUnknownPolicy = errors.New("unknown account policy must be error, map or warn")
+)
+
+// HierarchyPattern matches account names with at least two non-empty parts separated by colons.
+var hierarchyPattern = regexp.MustCompile(`^[^:\s]([^:]*[^:\s])?(:[^:\s]([^:]*[^:\s])?)+$`)
+
+/*
+LoadChart returns the chart of accounts read from the named file and nil.
+The file contains one account name per line, or ledger account directives e.g. "account Assets:Current".
+Blank lines and lines starting with ";" or "#" are ignored.
+If loadChart fails to read the file, it returns an error.
+*/
+func loadChart(name string) (chart, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cht := make(chart)
+	scnr := bufio.NewScanner(file)
+
+	for scnr.Scan() {
+		line := strings.TrimSpace(scnr.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "account ")
+		cht[nfc(strings.TrimSpace(line))] = true
+	}
+
+	err = scnr.Err()
+	if err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+
+	return cht, nil
+}
+
+/*
+ParseAcctCurrencies returns the currencies by account in the string of account=currency pairs and nil.
+If a pair is not valid, parseAcctCurrencies returns an error.
+*/
+func parseAcctCurrencies(str string) (map[string]string, error) {
+	curs := make(map[string]string)
+
+	for pair := range strings.SplitSeq(str, ",") {
+		acct, cur, ok := strings.Cut(pair, "=")
+
+		acct, cur = strings.TrimSpace(acct), strings.TrimSpace(cur)
+		if !ok || acct == "" || cur == "" {
+			return nil, fmt.Errorf("%w: %q", errAcctCurrency, pair)
+		}
+
+		curs[nfc(acct)] = cur
+	}
+
+	return curs, nil
+}
+
+/*
+CheckAccounts returns nil if the accounts of the transaction are known.
+An account is known if it is in the chart of accounts, when there is one,
+and it is in ledger hierarchy format, when that is checked.
+An empty other account is not checked,
+nor is the other account against the chart unless the unknown account policy is warn.
+If an account is not known, checkAccounts returns the first error.
+*/
+func (cfg *config) checkAccounts(trn *transact) error {
+	for inx, acct := range []string{trn.thisAcct, trn.otherAcct} {
+		isOther := inx == 1
+
+		switch {
+		case acct == "":
+			// other account is optional
+		case cfg.hierarchy && !hierarchyPattern.MatchString(acct):
+			return fmt.Errorf("%w: %q", errAcctHierarchy, acct)
+		case isOther && cfg.unknownPolicy != policyWarn:
+			// unknown other account was handled by applyUnknownPolicy
+		case cfg.chart != nil && !cfg.chart[acct]:
+			return fmt.Errorf("%w: %q", errAcctChart, acct)
+		}
+	}
+
+	return nil
+}
+
+/*
+ApplyUnknownPolicy applies the unknown account policy to the other account of the transaction and returns nil.
+If there is a chart of accounts and the other account is not empty string or in it,
+the policy error returns an error,
+and the policy map sets the other account to the default other account or, if there is none, unknownAcct.
+*/
+func (cfg *config) applyUnknownPolicy(trn *transact) error {
+	if cfg.chart == nil || trn.otherAcct == "" || cfg.chart[trn.otherAcct] {
+		return nil
+	}
+
+	switch cfg.unknownPolicy {
+	case policyError:
+		return fmt.Errorf("%w: %q", errAcctChart, trn.otherAcct)
+	case policyMap:
+		trn.otherAcct = cmp.Or(cfg.otherAcct, unknownAcct)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+/*
+FlagAliases are the old names of renamed flags, and alternative spellings, mapped to the current names.
+A flag set by an old name sets the current one, with a warning once per run,
+so scripts and profiles keep working.
+*/
+var flagAliases = map[string]string{
+	"encrypt-to":   "encryptto",
+	"memo-prefix":  "memoprefix",
+	"memo-suffix":  "memosuffix",
+	"print-config": "printconfig",
+	"whats-new":    "whatsnew",
+}
+
+// WarnedAliases are the old flag names that have been warned about in this run.
+var warnedAliases sync.Map
+
+/*
+ReplaceAliases returns the arguments with the names of flags that are aliases replaced by their current names,
+see flagAliases, and writes a warning about each alias to the writer, once per run.
+Only the arguments before the first non-flag, which flag sets parse, are replaced.
+*/
+func replaceAliases(fset *flag.FlagSet, args []string, writer io.Writer) []string {
+	args = append([]string(nil), args...)
+
+	for inx := 0; inx < len(args); inx++ {
+		arg := args[inx]
+		if arg == "--" || !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+
+		dashes := "-"
+		if strings.HasPrefix(arg, "--") {
+			dashes = "--"
+		}
+
+		name, val, hasVal := strings.Cut(strings.TrimPrefix(arg, dashes), "=")
+
+		if current, ok := flagAliases[name]; ok {
+			if _, warned := warnedAliases.LoadOrStore(name, true); !warned {
+				fmt.Fprintf(writer, "%v: warning: flag -%v is deprecated, use -%v\n", pgmName, name, current)
+			}
+
+			name, args[inx] = current, dashes+current
+			if hasVal {
+				args[inx] += "=" + val
+			}
+		}
+
+		flg := fset.Lookup(name)
+		if flg == nil || hasVal {
+			continue
+		}
+
+		if bflg, ok := flg.Value.(interface{ IsBoolFlag() bool }); !ok || !bflg.IsBoolFlag() {
+			inx++ // skips the flag's value
+		}
+	}
+
+	return args
+}
@@ -0,0 +1,357 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+/*
+A flatTable is a FlatBuffers table, the fields of Arrow metadata, by field ID, or nil where a field is absent.
+Fields are uint8, int16, int32 or int64 scalars, or a string, flatTable, vector of them or flatStructs,
+which are referred to by offset.
+*/
+type flatTable []any
+
+// FlatStructs is a FlatBuffers vector of structs, whose elements are aligned to eight bytes.
+type flatStructs struct {
+	num  int
+	data []byte
+}
+
+// A flatBuilder builds a FlatBuffer front to back, so each object is followed by the objects it refers to.
+type flatBuilder struct {
+	buf []byte
+}
+
+// The Arrow constants used.
+const (
+	arrowMagic      = "ARROW1"
+	arrowBatchRows  = 64 * 1024 // the maximum rows in a record batch
+	arrowV5         = 4         // metadata version
+	arrowSchema     = 1         // message header type
+	arrowBatch      = 3         // message header type
+	arrowUTF8       = 5         // type
+	arrowDecimal    = 7         // type
+	arrowDate       = 8         // type
+	arrowDay        = 0         // date unit
+	arrowBitWidth   = 128       // of decimals
+	arrowContinue   = 0xffffffff
+	arrowAlignment  = 8 // of messages and buffers
+	arrowDecimalLen = 16
+)
+
+/*
+WriteArrow writes the transactions to the writer as an Arrow IPC file, of record batches of up to arrowBatchRows
+rows, and returns nil.
+It has a column for each field of the standard format, then each extra field, like writeParquet,
+so the file can be read by Arrow libraries and DuckDB without parsing text.
+Dates are dates, amounts are decimals of scale parquetScale, and the other fields are strings, none of which are null.
+If writeArrow fails to write, it returns an error.
+*/
+func writeArrow(writer io.Writer, trns []transact, extraNames []string) error {
+	names := append([]string{"date", "thisacct", "otheracct", "memo", "amount", "currency"}, extraNames...)
+	types := make([]uint8, len(names))
+	fields := make([]flatTable, len(names))
+
+	for inx, name := range names {
+		types[inx] = arrowUTF8
+		typ := flatTable{}
+
+		switch name {
+		case "date":
+			types[inx], typ = arrowDate, flatTable{int16(arrowDay)}
+		case "amount": // precision, scale and bit width
+			types[inx] = arrowDecimal
+			typ = flatTable{int32(parquetPrecision), int32(parquetScale), int32(arrowBitWidth)}
+		}
+
+		// name, nullable, type type, type, dictionary and children
+		fields[inx] = flatTable{name, nil, types[inx], typ, nil, []flatTable{}}
+	}
+
+	schema := flatTable{nil, fields}
+
+	file := bytes.NewBufferString(arrowMagic + "\x00\x00")
+	writeArrowMessage(file, flatTable{int16(arrowV5), uint8(arrowSchema), schema, int64(0)}, nil)
+
+	var blocks []byte
+
+	for start := 0; start < len(trns) || start == 0; start += arrowBatchRows {
+		batch := trns[start:min(start+arrowBatchRows, len(trns))]
+		offset := file.Len()
+
+		meta, body := arrowBatchMessage(batch, types)
+		writeArrowMessage(file, meta, body)
+
+		blocks = binary.LittleEndian.AppendUint64(blocks, uint64(offset))
+		blocks = binary.LittleEndian.AppendUint32(blocks, uint32(file.Len()-offset-len(body)))
+		blocks = binary.LittleEndian.AppendUint32(blocks, 0) // padding
+		blocks = binary.LittleEndian.AppendUint64(blocks, uint64(len(body)))
+	}
+
+	file.Write(binary.LittleEndian.AppendUint32(nil, arrowContinue))
+	file.Write(binary.LittleEndian.AppendUint32(nil, 0)) // the end of the stream
+
+	const blockLen = 24
+
+	// version, schema, dictionaries and record batches
+	footer := flatBytes(flatTable{int16(arrowV5), schema, nil, flatStructs{len(blocks) / blockLen, blocks}})
+
+	file.Write(footer)
+	file.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	file.WriteString(arrowMagic)
+
+	_, err := writer.Write(file.Bytes())
+
+	return err
+}
+
+/*
+ArrowBatchMessage returns the metadata and body of the record batch message of the transactions,
+whose columns are of the types.
+Each column has an empty validity buffer, as there are no nulls, then offsets for strings and its values.
+*/
+func arrowBatchMessage(trns []transact, types []uint8) (flatTable, []byte) {
+	var (
+		body           bytes.Buffer
+		nodes, buffers []byte
+	)
+
+	// buffer adds the buffer, padded, to the body.
+	buffer := func(data []byte) {
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(body.Len()))
+		buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(data)))
+
+		body.Write(data)
+		body.Write(make([]byte, (arrowAlignment-len(data)%arrowAlignment)%arrowAlignment))
+	}
+
+	const (
+		secondsPerDay = 24 * 60 * 60
+		signShift     = 63
+	)
+
+	for inx, typ := range types {
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(len(trns)))
+		nodes = binary.LittleEndian.AppendUint64(nodes, 0) // null count
+
+		buffer(nil) // validity
+
+		var values []byte
+
+		switch typ {
+		case arrowDate:
+			for _, trn := range trns {
+				date, _ := time.Parse(time.DateOnly, trn.date)
+				values = binary.LittleEndian.AppendUint32(values, uint32(date.Unix()/secondsPerDay))
+			}
+		case arrowDecimal:
+			values = slices.Grow(values, len(trns)*arrowDecimalLen)
+
+			for _, trn := range trns {
+				units := int64(math.Round(trn.amount * math.Pow10(parquetScale)))
+				values = binary.LittleEndian.AppendUint64(values, uint64(units))
+				values = binary.LittleEndian.AppendUint64(values, uint64(units>>signShift))
+			}
+		default:
+			offsets := binary.LittleEndian.AppendUint32(nil, 0)
+
+			for _, trn := range trns {
+				values = append(values, arrowString(trn, inx)...)
+				offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(values)))
+			}
+
+			buffer(offsets)
+		}
+
+		buffer(values)
+	}
+
+	const nodeLen, bufferLen = 16, 16
+
+	// length, nodes and buffers
+	batch := flatTable{int64(len(trns)), flatStructs{len(nodes) / nodeLen, nodes},
+		flatStructs{len(buffers) / bufferLen, buffers}}
+
+	return flatTable{int16(arrowV5), uint8(arrowBatch), batch, int64(body.Len())}, body.Bytes()
+}
+
+// ArrowString returns the string field of the transaction in the indexed column, see writeArrow.
+func arrowString(trn transact, inx int) string {
+	const nStandard = 6
+
+	if nStandard <= inx {
+		return trn.extras[inx-nStandard]
+	}
+
+	return []string{"", trn.thisAcct, trn.otherAcct, trn.memo, "", trn.currency}[inx]
+}
+
+// WriteArrowMessage writes the encapsulated Arrow message, its metadata then its body, to the buffer.
+func writeArrowMessage(buf *bytes.Buffer, meta flatTable, body []byte) {
+	data := flatBytes(meta)
+
+	buf.Write(binary.LittleEndian.AppendUint32(nil, arrowContinue))
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(data))))
+	buf.Write(data)
+	buf.Write(body)
+}
+
+// FlatBytes returns the FlatBuffer of the root table, padded to eight bytes.
+func flatBytes(root flatTable) []byte {
+	fbr := flatBuilder{buf: make([]byte, 4)} // the offset of the root table
+
+	binary.LittleEndian.PutUint32(fbr.buf, uint32(fbr.table(root)))
+	fbr.pad(arrowAlignment)
+
+	return fbr.buf
+}
+
+// Pad pads the buffer with zeros to a multiple of the alignment.
+func (fbr *flatBuilder) pad(align int) {
+	for len(fbr.buf)%align != 0 {
+		fbr.buf = append(fbr.buf, 0)
+	}
+}
+
+/*
+Table adds the table, its vtable before it, then the objects its fields refer to, and returns its position.
+Its fields are laid out largest first, after the offset of its vtable, so each is aligned to its size.
+*/
+func (fbr *flatBuilder) table(tbl flatTable) int {
+	const vtableSize = 4
+
+	offsets := make([]int, len(tbl)) // of the fields in the table
+	ids := make([]int, 0, len(tbl))  // of the fields present, largest first
+
+	for id, val := range tbl {
+		if val != nil {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.SortStableFunc(ids, func(a, b int) int { return flatSize(tbl[b]) - flatSize(tbl[a]) })
+
+	end := vtableSize
+	for _, id := range ids {
+		size := flatSize(tbl[id])
+		end = (end + size - 1) / size * size
+		offsets[id] = end
+		end += size
+	}
+
+	fbr.pad(2)
+	vtable := len(fbr.buf)
+	fbr.buf = binary.LittleEndian.AppendUint16(fbr.buf, uint16(vtableSize+2*len(tbl)))
+	fbr.buf = binary.LittleEndian.AppendUint16(fbr.buf, uint16(end))
+
+	for _, off := range offsets {
+		fbr.buf = binary.LittleEndian.AppendUint16(fbr.buf, uint16(off))
+	}
+
+	fbr.pad(arrowAlignment)
+	pos := len(fbr.buf)
+	fbr.buf = append(fbr.buf, make([]byte, end)...)
+	binary.LittleEndian.PutUint32(fbr.buf[pos:], uint32(pos-vtable))
+
+	for _, id := range ids {
+		field := fbr.buf[pos+offsets[id]:]
+
+		switch val := tbl[id].(type) {
+		case uint8:
+			field[0] = val
+		case int16:
+			binary.LittleEndian.PutUint16(field, uint16(val))
+		case int32:
+			binary.LittleEndian.PutUint32(field, uint32(val))
+		case int64:
+			binary.LittleEndian.PutUint64(field, uint64(val))
+		}
+	}
+
+	for _, id := range ids {
+		at := pos + offsets[id]
+
+		if ref := fbr.ref(tbl[id]); 0 < ref {
+			binary.LittleEndian.PutUint32(fbr.buf[at:], uint32(ref-at))
+		}
+	}
+
+	return pos
+}
+
+/*
+Ref adds the object the field value refers to, a string, table or vector, and returns its position,
+or zero if the value is a scalar, which is in the table.
+*/
+func (fbr *flatBuilder) ref(val any) int {
+	switch val.(type) {
+	case uint8, int16, int32, int64:
+		return 0
+	}
+
+	fbr.pad(4)
+	pos := len(fbr.buf)
+
+	switch val := val.(type) {
+	case string:
+		fbr.buf = binary.LittleEndian.AppendUint32(fbr.buf, uint32(len(val)))
+		fbr.buf = append(append(fbr.buf, val...), 0)
+	case flatTable:
+		return fbr.table(val)
+	case []flatTable:
+		fbr.buf = binary.LittleEndian.AppendUint32(fbr.buf, uint32(len(val)))
+		fbr.buf = append(fbr.buf, make([]byte, 4*len(val))...)
+
+		for inx, tbl := range val {
+			at, ref := pos+4+4*inx, fbr.table(tbl)
+			binary.LittleEndian.PutUint32(fbr.buf[at:], uint32(ref-at))
+		}
+	case flatStructs:
+		if (pos+4)%arrowAlignment != 0 {
+			fbr.buf = append(fbr.buf, 0, 0, 0, 0)
+			pos += 4
+		}
+
+		fbr.buf = binary.LittleEndian.AppendUint32(fbr.buf, uint32(val.num))
+		fbr.buf = append(fbr.buf, val.data...)
+	}
+
+	return pos
+}
+
+// FlatSize returns the size of the field value in a table, its size if it is a scalar, else that of an offset.
+func flatSize(val any) int {
+	switch val.(type) {
+	case uint8:
+		return 1
+	case int16:
+		return 2
+	case int64:
+		return 8
+	default:
+		return 4
+	}
+}
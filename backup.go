@@ -0,0 +1,170 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	backupExt    = ".bak"            // of a backup of a file written by cas2trn
+	backupLayout = "20060102T150405" // of the time in the name of a backup, which sorts in time order
+)
+
+/*
+BackupFiles copies each file of this configuration that concurrent runs could corrupt, see lockNames,
+that exists and is not empty to a backup named for the time, e.g. "transactions.csv.20250131T070000.bak",
+then removes the oldest backups of it beyond those kept, and returns nil.
+A SQLite database is copied by the database, see copySQLite, so the backup includes changes in its journal.
+So a run that goes wrong, such as with a bad rule file, can be rolled back by copying a backup over the file.
+If backupFiles fails to copy a file or remove a backup, it returns an error.
+*/
+func (cfg *config) backupFiles(now time.Time) error {
+	dbName, isDB := sqliteFile(cfg.dbDriver, cfg.dbDSN)
+
+	for _, name := range cfg.lockNames() {
+		info, err := os.Stat(name)
+		if errors.Is(err, os.ErrNotExist) || (err == nil && info.Size() == 0) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("os.Stat: %w", err)
+		}
+
+		backup := name + "." + now.Format(backupLayout) + backupExt
+
+		if isDB && name == dbName {
+			err = copySQLite(cfg.dbDriver, cfg.dbDSN, backup)
+		} else {
+			err = copyFile(name, backup)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		backups, err := listBackups(name)
+		if err != nil {
+			return err
+		}
+
+		for len(backups) > int(cfg.backups) {
+			err = os.Remove(backups[0])
+			if err != nil {
+				return fmt.Errorf("os.Remove: %w", err)
+			}
+
+			backups = backups[1:]
+		}
+	}
+
+	return nil
+}
+
+/*
+ListBackups returns the names of the backups of the named file, oldest first, and nil.
+If listBackups fails to read the file's directory, it returns an error.
+*/
+func listBackups(name string) ([]string, error) {
+	dir, base := filepath.Split(name)
+
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadDir: %w", err)
+	}
+
+	var backups []string
+
+	for _, ent := range entries {
+		stamp, ok := strings.CutPrefix(ent.Name(), base+".")
+		stamp, isBackup := strings.CutSuffix(stamp, backupExt)
+
+		if ok && isBackup && len(stamp) == len(backupLayout) {
+			backups = append(backups, filepath.Join(dir, ent.Name()))
+		}
+	}
+
+	slices.Sort(backups)
+
+	return backups, nil
+}
+
+/*
+CopyFile copies the file named from to that named to, which keeps the permissions of from, and returns nil.
+If copyFile fails to read or write a file, it returns an error.
+*/
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("os.File.Stat: %w", err)
+	}
+
+	dst, err := os.OpenFile(to, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+
+	return errors.Join(err, dst.Close())
+}
+
+/*
+CopySQLite copies the SQLite database with the data source name to the file named to, and returns nil.
+The database copies itself, by VACUUM INTO, so the copy is consistent and includes changes still in its journal,
+which copying its file would miss.
+If copySQLite fails to open or copy the database, it returns an error.
+*/
+func copySQLite(driver, dsn, to string) error {
+	if !slices.Contains(sql.Drivers(), driver) {
+		return fmt.Errorf("%w: %v", errDBDriver, driver)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return fmt.Errorf("sql.Open: %w", err)
+	}
+	defer db.Close()
+
+	err = os.Remove(to) // VACUUM INTO fails if the file exists
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("os.Remove: %w", err)
+	}
+
+	_, err = db.Exec("VACUUM INTO ?", to)
+	if err != nil {
+		return fmt.Errorf("sql.DB.Exec: %w", err)
+	}
+
+	return nil
+}
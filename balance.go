@@ -0,0 +1,66 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+/*
+A balanceCheck checks the running balances of the records of a statement against their amounts.
+Statements can list records oldest or newest first, so either order is accepted.
+*/
+type balanceCheck struct {
+	amount, balance float64 // of the previous record
+	isSet           bool    // the previous record had a balance
+}
+
+/*
+Check writes a warning to the writer if the balance of the record on the line does not follow from
+that of the previous record and the amount, within the tolerance, such as when a record is missing.
+If the balance is empty string or fails to parse, the next record is not checked.
+If amounts are in minor units, so is the balance, in the configured currency.
+*/
+func (chk *balanceCheck) check(writer io.Writer, flds []string, amt float64, lineN int, cfg config) {
+	bal, err := parseFloat64(cfg.dialect.number(extract(cfg.amountPattern, flds[cfg.balanceI-1])))
+	if err != nil {
+		chk.isSet = false
+
+		return
+	}
+
+	if cfg.minorUnits {
+		bal /= math.Pow10(currencyExponent(cfg.currency))
+	}
+
+	// Allow for the binary representation of decimal amounts.
+	const epsilon = 0.000001
+
+	limit := cfg.tolerance + epsilon
+	if chk.isSet &&
+		limit < math.Abs(chk.balance+amt-bal) && // oldest first
+		limit < math.Abs(bal+chk.amount-chk.balance) { // newest first
+		fmt.Fprintf(writer, "%v: warning: balance %v on line %v does not follow from the previous balance %v, "+
+			"a record may be missing\n", pgmName, bal, lineN, chk.balance)
+	}
+
+	chk.amount, chk.balance, chk.isSet = amt, bal, true
+}
@@ -0,0 +1,178 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+/*
+A classifier suggests the other account of a transaction from the words of its memo.
+It is a multinomial naive Bayes classifier, trained on transactions in the standard format
+whose other accounts are known.
+*/
+type classifier struct {
+	nDocs  map[string]int            // memos trained on, by other account
+	nWords map[string]int            // words trained on, by other account
+	counts map[string]map[string]int // of each word, by other account
+	vocab  map[string]bool           // words trained on
+}
+
+// The indexes of fields in a transaction in the standard format.
+const (
+	stdOtherAcctI = 2
+	stdMemoI      = 3
+)
+
+/*
+LoadClassifier returns a classifier trained on the transactions in the named standard format file, and nil.
+Transactions with an empty other account, and comments, are ignored.
+If loadClassifier fails to read the file, it returns an error.
+*/
+func loadClassifier(name string) (*classifier, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cls := &classifier{nDocs: make(map[string]int), nWords: make(map[string]int),
+		counts: make(map[string]map[string]int), vocab: make(map[string]bool)}
+
+	rdr := csv.NewReader(file)
+	rdr.Comment, rdr.FieldsPerRecord = '#', -1
+
+	for {
+		flds, err := rdr.Read()
+		if errors.Is(err, io.EOF) {
+			return cls, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("reader.Read(): %w", err)
+		}
+
+		if len(flds) <= stdMemoI || flds[stdOtherAcctI] == "" {
+			continue
+		}
+
+		cls.train(flds[stdMemoI], flds[stdOtherAcctI])
+	}
+}
+
+// Train trains the classifier on a memo of a transaction with the other account.
+func (cls *classifier) train(memo, otherAcct string) {
+	cls.nDocs[otherAcct]++
+
+	if cls.counts[otherAcct] == nil {
+		cls.counts[otherAcct] = make(map[string]int)
+	}
+
+	for _, word := range memoWords(memo) {
+		cls.counts[otherAcct][word]++
+		cls.nWords[otherAcct]++
+		cls.vocab[word] = true
+	}
+}
+
+/*
+Classify returns the other account most probably that of a transaction with the memo,
+and the probability, from zero to one, that it is.
+Words not trained on are ignored, and if the memo has none that were, classify returns empty string.
+*/
+func (cls *classifier) classify(memo string) (string, float64) {
+	var words []string
+
+	for _, word := range memoWords(memo) {
+		if cls.vocab[word] {
+			words = append(words, word)
+		}
+	}
+
+	if len(words) == 0 {
+		return "", zero
+	}
+
+	nDocs := 0
+	for _, n := range cls.nDocs {
+		nDocs += n
+	}
+
+	// Log probabilities avoid underflow, and counts are smoothed so unseen words do not rule an account out.
+	logProbs := make(map[string]float64, len(cls.nDocs))
+	best := ""
+
+	for acct, n := range cls.nDocs {
+		logProb := math.Log(float64(n) / float64(nDocs))
+		for _, word := range words {
+			logProb += math.Log(float64(cls.counts[acct][word]+1) / float64(cls.nWords[acct]+len(cls.vocab)))
+		}
+
+		logProbs[acct] = logProb
+		if best == "" || logProbs[best] < logProb || (logProbs[best] == logProb && acct < best) {
+			best = acct
+		}
+	}
+
+	sum := zero
+	for _, logProb := range logProbs {
+		sum += math.Exp(logProb - logProbs[best])
+	}
+
+	return best, 1 / sum
+}
+
+/*
+Suggest sets the other account of the transaction, if it is empty string, to that classified from its memo,
+and the confidence field to the probability it is right, see classify.
+*/
+func (cls *classifier) suggest(trn *transact) {
+	if trn.otherAcct != "" {
+		return
+	}
+
+	acct, prob := cls.classify(trn.memo)
+	if acct == "" {
+		return
+	}
+
+	trn.otherAcct = acct
+	*trn.field(confidenceName) = strconv.FormatFloat(prob, 'f', 2, 64)
+}
+
+// MemoWords returns the words of the memo in lower case, ignoring numbers, punctuation and single letters.
+func memoWords(memo string) []string {
+	var words []string
+
+	for _, word := range strings.FieldsFunc(strings.ToLower(memo), func(chr rune) bool {
+		return !unicode.IsLetter(chr)
+	}) {
+		if 1 < len([]rune(word)) {
+			words = append(words, word)
+		}
+	}
+
+	return words
+}
@@ -0,0 +1,123 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// A clipboardCmd is a command that reads or writes the system clipboard.
+type clipboardCmd struct {
+	name string
+	args []string
+}
+
+var (
+	errClipboard      = errors.New("no clipboard command found, install wl-clipboard, xclip or xsel")
+	errClipboardFiles = errors.New("clipboard cannot be read when statement files are named")
+)
+
+/*
+ClipboardCmds returns the commands that read (paste), or write (copy), the system clipboard on this platform,
+in order of preference.
+*/
+func clipboardCmds(paste bool) []clipboardCmd {
+	switch runtime.GOOS {
+	case "darwin":
+		if paste {
+			return []clipboardCmd{{"pbpaste", nil}}
+		}
+
+		return []clipboardCmd{{"pbcopy", nil}}
+	case "windows":
+		if paste {
+			return []clipboardCmd{{"powershell", []string{"-NoProfile", "-Command", "Get-Clipboard -Raw"}}}
+		}
+
+		return []clipboardCmd{{"powershell", []string{"-NoProfile", "-Command", "$input | Set-Clipboard"}}}
+	default:
+		if paste {
+			return []clipboardCmd{
+				{"wl-paste", []string{"--no-newline"}},
+				{"xclip", []string{"-selection", "clipboard", "-out"}},
+				{"xsel", []string{"--clipboard", "--output"}},
+			}
+		}
+
+		return []clipboardCmd{
+			{"wl-copy", nil},
+			{"xclip", []string{"-selection", "clipboard", "-in"}},
+			{"xsel", []string{"--clipboard", "--input"}},
+		}
+	}
+}
+
+// FindClipboardCmd returns the first clipboard command that is installed, and nil.
+func findClipboardCmd(paste bool) (*exec.Cmd, error) {
+	for _, cmd := range clipboardCmds(paste) {
+		path, err := exec.LookPath(cmd.name)
+		if err == nil {
+			return exec.Command(path, cmd.args...), nil
+		}
+	}
+
+	return nil, errClipboard
+}
+
+/*
+ReadClipboard returns the text in the system clipboard and nil.
+If it fails to read the clipboard, readClipboard returns an error.
+*/
+func readClipboard() ([]byte, error) {
+	cmd, err := findClipboardCmd(true)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("exec.Cmd.Output: %w", err)
+	}
+
+	return data, nil
+}
+
+/*
+WriteClipboard writes the text to the system clipboard and returns nil.
+If it fails to write the clipboard, writeClipboard returns an error.
+*/
+func writeClipboard(text []byte) error {
+	cmd, err := findClipboardCmd(false)
+	if err != nil {
+		return err
+	}
+
+	cmd.Stdin = bytes.NewReader(text)
+
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("exec.Cmd.Run: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+/*
+A cloudConfig configures reading statements from cloud storage,
+named by paths such as "dropbox:///Statements/2025-01.csv", "gdrive://<file ID>/2025-01.csv"
+or "s3://bucket/2025-01.csv", and writing transactions to S3.
+*/
+type cloudConfig struct {
+	dropboxToken string // access token, mandatory for Dropbox paths
+	dropboxURL   string // of the Dropbox content API, mandatory
+	gdriveToken  string // access token, mandatory for Google Drive paths
+	gdriveURL    string // of the Google Drive files API, mandatory
+	s3           s3Config
+}
+
+const (
+	dropboxAPIURL   = "https://content.dropboxapi.com/2/files/download"
+	dropboxScheme   = "dropbox://"
+	dropboxTokenEnv = "CAS2TRN_DROPBOXTOKEN"
+	gdriveAPIURL    = "https://www.googleapis.com/drive/v3/files/"
+	gdriveScheme    = "gdrive://"
+	gdriveTokenEnv  = "CAS2TRN_GDRIVETOKEN"
+)
+
+var (
+	errCloudOutput = errors.New("transactions can only be written to S3 in cloud storage")
+	errCloudToken  = errors.New("cloud storage path needs an access token, see dropboxtoken and gdrivetoken")
+)
+
+// IsCloudPath returns true if the file is a path in cloud storage.
+func isCloudPath(file string) bool {
+	return strings.HasPrefix(file, dropboxScheme) || strings.HasPrefix(file, gdriveScheme) ||
+		strings.HasPrefix(file, s3Scheme)
+}
+
+/*
+Download returns the contents of the file in cloud storage and nil.
+A Dropbox path is the file's path in Dropbox.
+A Google Drive path is the file's ID, optionally followed by a name whose extension gives its type,
+as IDs have none.
+An S3 path is the object's bucket and key.
+If download fails, it returns an error.
+*/
+func (cld cloudConfig) download(file string) ([]byte, error) {
+	if strings.HasPrefix(file, s3Scheme) {
+		return cld.s3.get(file)
+	}
+
+	var req *http.Request
+
+	var err error
+
+	if path, ok := strings.CutPrefix(file, dropboxScheme); ok {
+		if cld.dropboxToken == "" {
+			return nil, errCloudToken
+		}
+
+		arg, _ := json.Marshal(map[string]string{"path": path}) // a map of strings cannot fail to marshal
+
+		req, err = http.NewRequest(http.MethodPost, cld.dropboxURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("http.NewRequest: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+cld.dropboxToken)
+		req.Header.Set("Dropbox-API-Arg", string(arg))
+	} else {
+		if cld.gdriveToken == "" {
+			return nil, errCloudToken
+		}
+
+		id, _, _ := strings.Cut(strings.TrimPrefix(file, gdriveScheme), "/")
+
+		req, err = http.NewRequest(http.MethodGet, cld.gdriveURL+url.PathEscape(id)+"?alt=media", nil)
+		if err != nil {
+			return nil, fmt.Errorf("http.NewRequest: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+cld.gdriveToken)
+	}
+
+	return apiDo(req)
+}
+
+/*
+WriteFile writes the data to the file, which can be an object in S3, and returns nil.
+If writeFile fails, or the file is elsewhere in cloud storage, it returns an error.
+*/
+func (cld cloudConfig) writeFile(file string, data []byte) error {
+	if strings.HasPrefix(file, s3Scheme) {
+		return cld.s3.put(file, data)
+	}
+
+	if isCloudPath(file) {
+		return errCloudOutput
+	}
+
+	const perm = 0o644
+
+	err := os.WriteFile(file, data, perm)
+	if err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ColumnFields are the fields whose columns can be named by the header row, by flags such as datecol.
+var columnFields = []string{"amount", "balance", "cheque", "credit", "currency", "date", "debit", "memo",
+	"otheracct", "thisacct"}
+
+// ColumnExamples are typical names of the columns of the fields in header rows, for the help of their flags.
+var columnExamples = map[string]string{
+	"amount": "Amount", "balance": "Balance", "cheque": "Cheque Number", "credit": "Paid In",
+	"currency": "Currency", "date": "Transaction Date", "debit": "Paid Out", "memo": "Description",
+	"otheracct": "Payee", "thisacct": "Account Number",
+}
+
+var errColumns = errors.New("neither named columns nor field indexes configure the statement validly")
+
+/*
+ResolveColumns returns the configuration with the indexes of the fields whose columns are named,
+see config.columns, set to the positions of those names in the header, compared ignoring case and spaces around,
+and the number of fields set to that of the header if it is zero, and true.
+If the header does not have all the named columns, resolveColumns returns false.
+*/
+func (cfg config) resolveColumns(header []string) (config, bool) {
+	inxs := cfg.indexPointers()
+
+	for _, field := range slices.Sorted(maps.Keys(cfg.columns)) {
+		inx := slices.IndexFunc(header, func(name string) bool {
+			return strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(cfg.columns[field]))
+		})
+		if inx < 0 {
+			return cfg, false
+		}
+
+		*inxs[field] = ui2ui8(uint(inx + 1))
+	}
+
+	if cfg.nFields == 0 {
+		cfg.nFields = ui2ui8(uint(len(header)))
+	}
+
+	cfg.columns = nil
+
+	return cfg, true
+}
+
+/*
+ProbeHeader returns a header with the named columns, see config.columns, in positions no other field index uses,
+so a configuration with named columns can be checked before a statement's header row is read.
+*/
+func (cfg *config) probeHeader() []string {
+	var used [maxNFields + 1]bool
+
+	last := 0
+
+	for field, inxp := range cfg.indexPointers() {
+		if _, named := cfg.columns[field]; !named {
+			used[*inxp], last = true, max(last, int(*inxp))
+		}
+	}
+
+	nFields := int(cfg.nFields)
+	if nFields == 0 {
+		nFields = min(last+len(cfg.columns), maxNFields)
+	}
+
+	header, inx := make([]string, nFields), 0
+
+	for _, field := range slices.Sorted(maps.Keys(cfg.columns)) {
+		for inx < nFields && used[inx+1] {
+			inx++
+		}
+
+		if inx < nFields {
+			header[inx] = cfg.columns[field]
+			inx++
+		}
+	}
+
+	return header
+}
+
+/*
+ApplyHeader returns the configuration of a statement whose first record is the fields, and true if the fields are
+its header row, which is not a transaction.
+If the configuration names columns, see config.columns, and the fields have them, it is the header row,
+else the field indexes are used.
+If neither gives a valid configuration, applyHeader returns an error.
+*/
+func (cfg config) applyHeader(fields []string) (config, bool, error) {
+	resolved, isHeader := cfg.resolveColumns(fields)
+	if !isHeader {
+		resolved.columns = nil
+	}
+
+	if resolved.isValid() != nil {
+		return cfg, false, errColumns
+	}
+
+	return resolved, isHeader, nil
+}
@@ -20,14 +20,20 @@ package main
 
 import (
 	"errors"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const (
 	// The inclusive limits for the number of fields in an input CSV record.
 	minNFields = 3 // date, memo and amount
 	maxNFields = 20
-	nIndexes   = 7 // number of field indexes in config
+	nIndexes   = 21 // number of field indexes in config
 )
 
 /*
@@ -42,50 +48,416 @@ type config struct {
 		The indexes of fields in an input CSV record.
 		If an index is zero, this record does not contain that field.
 	*/
-	amountI    uint8 // optional, but if zero then creditI and debitI must be non-zero
+	amountI    uint8 // optional, but if zero then creditI and debitI, or quantityI and priceI, must be non-zero
+	balanceI   uint8 // running balance, optional and checks the amounts, see tolerance
+	chequeI    uint8 // optional, adds field cheque to the output
 	creditI    uint8 // optional, see amountI
+	currencyI  uint8 // optional, see currency
 	dateI      uint8 // mandatory
+	dcI        uint8 // debit credit indicator, optional but if non-zero then amountI must be non-zero
 	debitI     uint8 // optional, see amountI
+	escrowI    uint8 // escrow part of a loan payment, optional see principalI
+	feeI       uint8 // optional, see feePolicy
+	interestI  uint8 // interest part of a loan payment, optional see principalI
 	memoI      uint8 // or description, mandatory
+	origAmtI   uint8 // original amount before conversion, optional and adds fields origamount and origcurrency
+	origCurI   uint8 // original currency, optional but if non-zero then origAmtI must be non-zero
 	otherAcctI uint8 // optional
+	priceI     uint8 // price of a unit of a security, optional and adds field price to the output
+	principalI uint8 // principal part of a loan payment, optional and splits the payment, see principalAcct
+	quantityI  uint8 // number of units of a security traded, optional and adds field quantity to the output
+	statusI    uint8 // pending or posted status, optional see pendingPolicy
+	symbolI    uint8 // ticker symbol of a security, optional and adds field symbol to the output
 	thisAcctI  uint8 // optional, see thisAcct
 	/*
 		Currency is the unit for amount.
-		It is optional e.g. "NZD".
+		It is optional e.g. "NZD", and overridden by the currency field if that is not empty string.
 	*/
 	currency string
+	/*
+		AcctCurrencies are the currencies of accounts, by this account.
+		They are optional, and override currency but are overridden by the currency field.
+	*/
+	acctCurrencies map[string]string
+	/*
+		MinorUnits means amounts are whole numbers of the minor units of their currency, e.g. "16250" is 162.50 NZD,
+		as some payment processors export them.
+		It is optional.
+	*/
+	minorUnits bool
+	/*
+		LaxNumbers accepts amounts in any form strconv.ParseFloat does, such as "1e3" or "Inf",
+		instead of only digits with an optional sign and decimal separator.
+		It is optional.
+	*/
+	laxNumbers bool
+	/*
+		MaxAmount is the largest absolute amount accepted, as a sanity bound against corrupted input.
+		It is optional, and zero means amounts are unbounded.
+	*/
+	maxAmount float64
 	/*
 		DateFormat is the format of the date field in an input CSV record.
 		It is mandatory and Go style e.g. "02/01/2006"
 	*/
 	dateFormat string
+	/*
+		DocRef is the template of a reference to a document, such as a scanned receipt.
+		It is optional e.g. "receipts/{date}_{amount}.pdf", and adds field document to the output.
+	*/
+	docRef string
+	/*
+		MemoPrefix and memoSuffix are templates of labels added to memos, see transact.label.
+		They are optional e.g. "[import {period}] ".
+		FileName is the name of the statement being translated, and stmtPeriod the year and month its period starts.
+	*/
+	memoPrefix, memoSuffix string
+	fileName, stmtPeriod   string
+	/*
+		DebitMark and creditMark are the values of the debit credit indicator field.
+		They are mandatory if dcI is non-zero e.g. "D" and "C", or "S" and "H".
+	*/
+	debitMark, creditMark string
+	/*
+		OtherAcct is the default other account,
+		for transactions whose other account is empty string after rules are applied.
+		It is optional e.g. "Expenses:Unknown".
+	*/
+	otherAcct string
 	/*
 		ThisAcct is the name of the account that the input CSV record belongs to.
-		It is optional, but if it is empty string then thisAcctI must be non-zero.
+		It is optional, but if it is empty string then thisAcctI must be non-zero or thisAcctPattern not nil.
 	*/
 	thisAcct string
+	/*
+		ThisAcctPattern matches this account in the name of the statement file, by its group named acct,
+		its first group, or the whole pattern.
+		It is optional, and used when the this account field is empty string.
+		FileAcct is the account it matches in the statement being translated.
+	*/
+	thisAcctPattern *regexp.Regexp
+	fileAcct        string
+	/*
+		MaxMemo is the maximum number of characters in a memo, including any label.
+		It is optional, and zero means memos are not truncated.
+	*/
+	maxMemo uint
+	/*
+		Mappings replace the values of transaction fields after parsing.
+		They are optional, and loaded from the file named by the mapfile flag.
+	*/
+	mappings mappings
+	/*
+		Rules set transaction fields from patterns matched in fields, after mappings are applied.
+		They are optional, and loaded from the file named by the rulefile flag.
+	*/
+	rules rules
+	/*
+		Classifier suggests the other accounts of transactions that rules leave empty, adding field confidence.
+		It is optional, and trained on the file named by the trainfile flag.
+	*/
+	classifier *classifier
+	/*
+		Review reviews transactions interactively before they are written,
+		and appends the fixes learned as rules to ruleFile, the file the rules were loaded from.
+		They are optional.
+	*/
+	review   bool
+	ruleFile string
+	/*
+		Categories are the other accounts assigned to memos when reviewing, which are suggested for similar memos.
+		CategoryFile is the file they are loaded from, and appended to.
+		They are optional.
+	*/
+	categories   []category
+	categoryFile string
+	// ExtraNames are the names of fields added to the standard format, in output order.
+	extraNames []string
+	/*
+		Chart is the chart of accounts that accounts are checked against.
+		It is optional, and loaded from the file named by the chartfile flag.
+	*/
+	chart chart
+	// Hierarchy checks accounts are in ledger hierarchy format, and is optional.
+	hierarchy bool
+	/*
+		UnknownPolicy is the policy for other accounts not in the chart of accounts.
+		It is mandatory, and one of policyError, policyMap or policyWarn.
+	*/
+	unknownPolicy string
+	/*
+		BothPolicy is the policy for records with both credit and debit.
+		It is optional, and one of policyError, bothCredit, bothDebit, bothNet or bothSplit.
+		Empty string means policyError.
+	*/
+	bothPolicy string
+	/*
+		FeePolicy is the policy for fees, and feeAcct is the other account of fee transactions.
+		They are optional, see feeI, and the policy is one of feeAdd, feeSplit or empty string.
+		Empty string means feeAdd.
+	*/
+	feePolicy, feeAcct string
+	/*
+		PrincipalAcct, interestAcct and escrowAcct are the other accounts of the parts of a loan payment.
+		They are optional, see principalI, but mandatory if the field index of their part is non-zero.
+	*/
+	principalAcct, interestAcct, escrowAcct string
+	/*
+		Earnings and deductions are the other accounts of the parts of a payslip, by field index.
+		They are optional, and split a transaction into a transaction for each part, see transact.setPayParts.
+	*/
+	earnings, deductions map[uint8]string
+	/*
+		PendingMark is the status of pending transactions, compared ignoring case, and pendingPolicy is their policy.
+		They are optional, see statusI, and the policy is one of pendingInclude, pendingSkip or empty string.
+		Empty string means pendingSkip.
+	*/
+	pendingMark, pendingPolicy string
+	/*
+		PlainMemo replaces typographic quotes, dashes and mojibake in memos with plain text.
+		It is optional.
+	*/
+	plainMemo bool
+	/*
+		MemoScript is the policy for the script of memos, memoPreserve or memoLatin, see latin.
+		It is optional, and empty string means memoPreserve.
+	*/
+	memoScript string
+	/*
+		FromClipboard reads a statement from, and toClipboard writes transactions to, the clipboard.
+		They are optional.
+	*/
+	fromClipboard, toClipboard bool
+	/*
+		OutFile names the file, or object in S3 e.g. "s3://bucket/transactions.csv",
+		that transactions are written to instead of standard output.
+		It is optional.
+	*/
+	outFile string
+	// Force overwrites an output file that is not empty, and is optional.
+	force bool
+	/*
+		Backups is the number of backups kept of the state file, output file and SQLite database,
+		made before each run changes them, see config.backupFiles.
+		It is optional, and zero disables backups.
+	*/
+	backups uint
+	/*
+		EncryptTo is the recipient, an age public key or GPG user, that transactions are encrypted to
+		before they are written.
+		It is optional.
+	*/
+	encryptTo string
+	/*
+		HMACKey is the key of an HMAC-SHA256 signature of the output file, written with it, see writeOutFile.
+		It is optional.
+	*/
+	hmacKey string
+	/*
+		BOM writes the UTF-8 byte order mark before transactions in text formats,
+		so Windows programs such as Excel read them as UTF-8.
+		It is optional.
+	*/
+	bom bool
+	/*
+		FirstLine and lastLine are the range of lines of a statement that records are translated from.
+		They are optional, and zero means the range is unbounded.
+	*/
+	firstLine, lastLine uint
+	/*
+		Sequence numbers transactions in their input order, in an extra field.
+		It is optional, and one of seqFile, seqGlobal or empty string.
+	*/
+	sequence string
+	/*
+		DedupeKey names the fields of a transaction that identify duplicates, of which only the first is written.
+		It is optional, and the names are those of transact.value.
+	*/
+	dedupeKey []string
+	/*
+		SortDate writes transactions sorted by date, keeping the input order of those on the same date.
+		DaySequence numbers transactions within each date, in an extra field.
+		They are optional.
+	*/
+	sortDate, daySequence bool
+	/*
+		Limit is the maximum number of transactions written, and sample the number of transactions
+		chosen at random to write. They are optional, and cannot both be non-zero.
+	*/
+	limit, sample uint
+	/*
+		MaxGap is the maximum number of days, on which the bank is open according to holidays,
+		that an account can have no transactions before a warning is written.
+		They are optional, and zero disables the warnings.
+	*/
+	maxGap   uint
+	holidays calendar
+	/*
+		OutlierFactor is how many times the largest prior debit, or credit, of an account an amount can be
+		before a warning is written, see history.
+		It is optional, and zero disables the warnings, else it needs a database with the history, see dbDSN.
+	*/
+	outlierFactor float64
+	/*
+		Output is the output format, one of outputArrow, outputDebitCredit, outputExcelCSV, outputLedger, outputParquet,
+		outputStandard, outputXLSX or empty string, the standard.
+	*/
+	output string
+	/*
+		Rounding is the mode for rounding the amounts of split transactions.
+		It is optional, and one of roundHalfEven, roundHalfUp or empty string, which rounds half up.
+	*/
+	rounding string
+	/*
+		PeriodPattern matches the first and last dates of the statement period, by its first two groups,
+		in a record that states it.
+		PeriodComment writes the period covered by the statements as a comment after the transactions.
+		They are optional.
+	*/
+	periodPattern *regexp.Regexp
+	periodComment bool
+	/*
+		ExpectCount and expectSum are the number of transactions, and sum of their amounts,
+		that are expected to be written, such as printed on the statement.
+		They are optional, and nil means there is no expectation.
+	*/
+	expectCount *uint
+	expectSum   *float64
+	/*
+		Tolerance is the largest difference between a running balance and that computed from the amounts
+		that is not warned about, see balanceI.
+		It is optional, and cannot be negative.
+	*/
+	tolerance float64
+	/*
+		DBDSN is the data source name of a database that transactions are written to instead of standard output,
+		with the database/sql driver dbDriver, in batches of dbBatch transactions.
+		It is optional.
+	*/
+	dbDSN, dbDriver string
+	dbBatch         uint
+	/*
+		StateFile records the progress of translating statement files, so an interrupted translation can resume.
+		It is optional.
+	*/
+	stateFile string
+	/*
+		ProfileDir is a directory of profiles, each configuring the statement files whose names match its pattern,
+		see profileMatch, and args are the flags that override them.
+		It is optional.
+	*/
+	profileDir string
+	profiles   []profileMatch
+	args       []string
+	// Stats writes statistics after translating, and is optional.
+	stats bool
+	// Explain writes how the configuration interprets a record instead of translating, and is optional.
+	explain bool
+	// Info is what to write instead of translating, e.g. infoHelp, set by parseConfig when it returns errInfo.
+	info string
+	// WhatsNew writes the numbers of new and imported transactions instead of translating, and is optional.
+	whatsNew bool
+	/*
+		ZipPassword decrypts statements in zip archives.
+		It is optional, and avoids extracting plain text statements to disk.
+	*/
+	zipPassword string
+	/*
+		Dialect is the variant of CSV format of statements, and is optional.
+		Its characters override those detected if detectDialect is set.
+	*/
+	dialect       dialect
+	detectDialect bool
+	/*
+		AmountPattern and datePattern extract the amount and date from fields that contain more,
+		such as "NZD 162.00" or "2019-12-24 13:05".
+		They are optional.
+	*/
+	amountPattern, datePattern *regexp.Regexp
+	/*
+		LinePattern matches the lines of text of PDF statements that are transactions.
+		Its groups are the fields of a record.
+		It is optional, and needed to translate PDF statements.
+	*/
+	linePattern *regexp.Regexp
+	/*
+		Columns are the names of the columns of fields, by the names of their fields e.g. "date",
+		in the header row of statements, see resolveColumns.
+		They are optional, and override the field indexes of statements that have a header row.
+	*/
+	columns map[string]string
+	// Cloud configures reading statements from cloud storage, and is optional.
+	cloud cloudConfig
+	// Imap configures the fetch command, and is optional.
+	imap imapConfig
+	// Sheet configures appending transactions to a Google Sheet instead of writing them, and is optional.
+	sheet sheetConfig
+	/*
+		Schedule runs the fetch command periodically, as a long-lived service.
+		It is optional, and parsed from the cron expression of the schedule flag.
+	*/
+	schedule *schedule
+	// MetricsAddr is the address that a service exposes its metrics on, and is optional.
+	metricsAddr string
 }
 
+// The scopes of transaction sequence numbers.
+const (
+	seqFile   = "file"   // numbers restart with each statement
+	seqGlobal = "global" // numbers continue across statements
+)
+
 var (
-	errAmountOpt    = errors.New("amount field index, or credit and debit indexes cannot both be zero")
-	errDateI        = errors.New("date field index cannot be zero")
-	errDateFormat   = errors.New("date format in input CSV record must be Go style e.g. \"02/01/2006\"")
-	errIndexUnique  = errors.New("field indexes cannot share a non-zero value")
-	errIndexRange   = errors.New("field index is out of range")
-	errMemoI        = errors.New("memo field index cannot be zero")
-	errNFieldsRange = errors.New("number of fields in input CSV record is out of range")
-	errThisAcctOpt  = errors.New("this account and this account index " +
-		"cannot be empty string and zero respectively")
+	errAmountOpt = errors.New("amount field index, credit and debit indexes, quantity and price indexes, " +
+		"and pay columns cannot all be zero or empty")
+	errBothPolicy    = errors.New("both policy must be error, credit, debit, net, split or empty string")
+	errDateI         = errors.New("date field index cannot be zero")
+	errDateFormat    = errors.New("date format in input CSV record must be Go style e.g. \"02/01/2006\"")
+	errDedupeKey     = errors.New("dedupe key must name fields of a transaction e.g. \"date,amount,memo\"")
+	errDBBatch       = errors.New("database batch size cannot be zero")
+	errDCAmount      = errors.New("debit credit indicator field index needs a non-zero amount field index")
+	errDCMarks       = errors.New("debit and credit marks cannot be empty string or equal")
+	errFeeAcct       = errors.New("fee account cannot be empty string when fee policy is split")
+	errFeePolicy     = errors.New("fee policy must be add, split or empty string")
+	errLoanAcct      = errors.New("loan part account cannot be empty string when its field index is non-zero")
+	errIndexUnique   = errors.New("field indexes cannot share a non-zero value")
+	errLimitSample   = errors.New("limit and sample cannot both be non-zero")
+	errLineRange     = errors.New("line range must be first-last line numbers, either can be omitted e.g. \"100-500\"")
+	errMaxAmountOpt  = errors.New("maximum amount cannot be negative")
+	errMaxMemo       = errors.New("maximum memo length cannot be shorter than the ellipsis, three when plain memo")
+	errIndexRange    = errors.New("field index is out of range")
+	errMemoScript    = errors.New("memo script must be preserve, latin or empty string")
+	errMemoI         = errors.New("memo field index cannot be zero")
+	errOutlierFactor = errors.New("outlier factor must be zero or greater than one")
+	errOutlierDB     = errors.New("outlier factor needs a database of account history, see dbdsn")
+	errOrigCurI      = errors.New("original currency field index needs a non-zero original amount field index")
+	errPendingMark   = errors.New("pending mark cannot be empty string when status field index is set")
+	errPendingPol    = errors.New("pending policy must be include, skip or empty string")
+	errNFieldsRange  = errors.New("number of fields in input CSV record is out of range")
+	errOutput        = errors.New("output must be standard, debitcredit, excel-csv, ledger, parquet, xlsx or arrow")
+	errPeriodGroups  = errors.New("period pattern must have groups for the first and last dates")
+	errRounding      = errors.New("rounding must be halfeven, halfup or empty string")
+	errSequence      = errors.New("sequence must be file, global or empty string")
+	errSinks         = errors.New("database, Google Sheet and file, clipboard or binary outputs cannot be combined")
+	errThisAcctOpt   = errors.New("this account, this account index and this account pattern " +
+		"cannot be empty string, zero and empty string respectively")
+	errTolerance = errors.New("balance tolerance cannot be negative")
 )
 
 /*
 AreIndexesValid returns nil if all field indexes are valid.
 It assumes the number of fields in an input CSV record nFields is in range.
-All indexes must be <= nFields, and all non-zero indexes must be unique.
+All indexes, including those of the earnings and deductions pay columns, must be <= nFields,
+and all non-zero indexes must be unique.
 If not, areIndexesValid returns the first error.
 */
 func (cfg *config) areIndexesValid() error {
-	inxs := [nIndexes]uint8{cfg.amountI, cfg.creditI, cfg.dateI, cfg.debitI, cfg.memoI, cfg.otherAcctI, cfg.thisAcctI}
+	inxs := []uint8{
+		cfg.amountI, cfg.creditI, cfg.dateI, cfg.debitI, cfg.memoI, cfg.otherAcctI, cfg.thisAcctI, cfg.chequeI,
+		cfg.dcI, cfg.currencyI, cfg.balanceI, cfg.statusI, cfg.origAmtI, cfg.origCurI, cfg.feeI,
+		cfg.quantityI, cfg.symbolI, cfg.priceI, cfg.principalI, cfg.interestI, cfg.escrowI,
+	}
+	inxs = slices.AppendSeq(slices.AppendSeq(inxs, maps.Keys(cfg.earnings)), maps.Keys(cfg.deductions))
 
 	var inUse [maxNFields + 1]bool
 
@@ -110,22 +482,223 @@ AreOptionsValid returns nil if the combination of options are valid.
 If not, areOptionsValid returns the first error.
 */
 func (cfg *config) areOptionsValid() error {
-	if cfg.thisAcct == "" && cfg.thisAcctI == 0 {
+	if cfg.thisAcct == "" && cfg.thisAcctI == 0 && cfg.thisAcctPattern == nil {
 		return errThisAcctOpt
 	}
 
-	if (cfg.amountI == 0) && (cfg.creditI == 0 || cfg.debitI == 0) {
+	if (cfg.amountI == 0) && (cfg.creditI == 0 || cfg.debitI == 0) && (cfg.quantityI == 0 || cfg.priceI == 0) &&
+		len(cfg.earnings)+len(cfg.deductions) == 0 {
 		return errAmountOpt
 	}
 
+	if !slices.Contains([]string{policyError, policyMap, policyWarn}, cfg.unknownPolicy) {
+		return errUnknownPolicy
+	}
+
+	if !slices.Contains([]string{"", policyError, bothCredit, bothDebit, bothNet, bothSplit}, cfg.bothPolicy) {
+		return errBothPolicy
+	}
+
+	if !slices.Contains([]string{"", feeAdd, feeSplit}, cfg.feePolicy) {
+		return errFeePolicy
+	}
+
+	if cfg.feeI != 0 && cfg.feePolicy == feeSplit && cfg.feeAcct == "" {
+		return errFeeAcct
+	}
+
+	for inx, acct := range map[uint8]string{
+		cfg.principalI: cfg.principalAcct, cfg.interestI: cfg.interestAcct, cfg.escrowI: cfg.escrowAcct,
+	} {
+		if inx != 0 && acct == "" {
+			return errLoanAcct
+		}
+	}
+
+	if cfg.origCurI != 0 && cfg.origAmtI == 0 {
+		return errOrigCurI
+	}
+
+	if !slices.Contains([]string{"", pendingInclude, pendingSkip}, cfg.pendingPolicy) {
+		return errPendingPol
+	}
+
+	if cfg.statusI != 0 && cfg.pendingMark == "" {
+		return errPendingMark
+	}
+
+	if !slices.Contains([]string{"", memoLatin, memoPreserve}, cfg.memoScript) {
+		return errMemoScript
+	}
+
+	if !slices.Contains([]string{"", seqFile, seqGlobal}, cfg.sequence) {
+		return errSequence
+	}
+
+	if cfg.limit != 0 && cfg.sample != 0 {
+		return errLimitSample
+	}
+
+	if cfg.dbDSN != "" && cfg.dbBatch == 0 {
+		return errDBBatch
+	}
+
+	nSinks := 0 // see newTranslator, as each replaces how transactions are written
+
+	for _, isSet := range []bool{
+		cfg.dbDSN != "", cfg.sheet.id != "", cfg.outFile != "" || cfg.toClipboard || cfg.encryptTo != "" ||
+			cfg.output == outputArrow || cfg.output == outputParquet || cfg.output == outputXLSX,
+	} {
+		if isSet {
+			nSinks++
+		}
+	}
+
+	if 1 < nSinks {
+		return errSinks
+	}
+
+	if cfg.output != "" && !slices.Contains(outputFormats, cfg.output) {
+		return errOutput
+	}
+
+	if !slices.Contains([]string{"", roundHalfEven, roundHalfUp}, cfg.rounding) {
+		return errRounding
+	}
+
+	if cfg.tolerance < zero {
+		return errTolerance
+	}
+
+	if cfg.maxAmount < zero {
+		return errMaxAmountOpt
+	}
+
+	if cfg.maxMemo != 0 && cfg.maxMemo < uint(utf8.RuneCountInString(cfg.ellipsis())) {
+		return errMaxMemo
+	}
+
+	if cfg.outlierFactor != zero && cfg.outlierFactor <= 1 {
+		return errOutlierFactor
+	} else if cfg.outlierFactor != zero && cfg.dbDSN == "" {
+		return errOutlierDB
+	}
+
+	if cfg.encryptTo != "" && cfg.toClipboard {
+		return errEncryptClipboard
+	}
+
+	if cfg.hmacKey != "" && cfg.outFile == "" {
+		return errHMACOutput
+	}
+
+	err := cfg.dialect.isValid()
+	if err != nil {
+		return err
+	}
+
+	if cfg.dcI != 0 {
+		if cfg.amountI == 0 {
+			return errDCAmount
+		}
+
+		if cfg.debitMark == "" || cfg.creditMark == "" || strings.EqualFold(cfg.debitMark, cfg.creditMark) {
+			return errDCMarks
+		}
+	}
+
 	return nil
 }
 
+// Ellipsis returns what truncated memos end with, see maxMemo.
+func (cfg *config) ellipsis() string {
+	if cfg.plainMemo {
+		return "..." // as plainText maps it
+	}
+
+	return "…"
+}
+
+/*
+IndexPointers returns pointers to the field indexes by the names of their fields,
+e.g. "date" or "otheracct", as named by line pattern groups and header columns.
+*/
+func (cfg *config) indexPointers() map[string]*uint8 {
+	return map[string]*uint8{
+		"amount": &cfg.amountI, "balance": &cfg.balanceI, "cheque": &cfg.chequeI, "credit": &cfg.creditI,
+		"currency": &cfg.currencyI, "date": &cfg.dateI, "dc": &cfg.dcI, "debit": &cfg.debitI,
+		"escrow": &cfg.escrowI, "fee": &cfg.feeI, "interest": &cfg.interestI, "memo": &cfg.memoI,
+		"origamount": &cfg.origAmtI, "origcurrency": &cfg.origCurI, "otheracct": &cfg.otherAcctI,
+		"price": &cfg.priceI, "principal": &cfg.principalI, "quantity": &cfg.quantityI, "status": &cfg.statusI,
+		"symbol": &cfg.symbolI, "thisacct": &cfg.thisAcctI,
+	}
+}
+
+/*
+SetLineIndexes sets the field indexes, and number of fields, from the named groups of the line pattern.
+A group named for a field, e.g. "(?P<date>...)" or "(?P<otheracct>...)", sets its index if it is zero.
+*/
+func (cfg *config) setLineIndexes() {
+	if cfg.nFields == 0 {
+		cfg.nFields = ui2ui8(uint(cfg.linePattern.NumSubexp()))
+	}
+
+	inxs := cfg.indexPointers()
+
+	for inx, name := range cfg.linePattern.SubexpNames() {
+		if inxp, ok := inxs[name]; ok && *inxp == 0 {
+			*inxp = ui2ui8(uint(inx))
+		}
+	}
+}
+
+/*
+ParseLineRange returns the first and last line numbers of the line range and nil.
+The range is "first-last", "first-", "-last" or a single line number, and zero means unbounded.
+If the range is not valid, parseLineRange returns an error.
+*/
+func parseLineRange(rng string) (uint, uint, error) {
+	firstStr, lastStr, isRange := strings.Cut(rng, "-")
+	if !isRange {
+		lastStr = firstStr
+	}
+
+	var vals [2]uint
+
+	for inx, str := range []string{firstStr, lastStr} {
+		if str == "" {
+			continue
+		}
+
+		val, err := strconv.ParseUint(str, 10, 0)
+		if err != nil || val == 0 {
+			return 0, 0, errLineRange
+		}
+
+		vals[inx] = uint(val)
+	}
+
+	if rng == "-" || (vals[1] != 0 && vals[1] < vals[0]) {
+		return 0, 0, errLineRange
+	}
+
+	return vals[0], vals[1], nil
+}
+
 /*
 IsValid returns nil if this configuration is valid.
 If not, isValid returns the first error.
 */
 func (cfg *config) isValid() error {
+	if len(cfg.columns) != 0 {
+		probe, ok := cfg.resolveColumns(cfg.probeHeader())
+		if !ok {
+			return errNFieldsRange
+		}
+
+		return probe.isValid()
+	}
+
 	val, _ := time.Parse(cfg.dateFormat, cfg.dateFormat)
 	if val.Format(time.DateOnly) != time.DateOnly {
 		return errDateFormat
@@ -0,0 +1,127 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const configFlag = "config"
+
+var errConfigLine = errors.New("configuration file line must be a key and value, " +
+	"e.g. \"datei = 1\" in TOML or \"datei: 1\" in YAML")
+
+/*
+ReadConfigFile returns the flags set by the named configuration file, in TOML or YAML, and nil.
+Each line is a flag name, in any case, and its value, e.g. "dateFormat = \"02/01/2006\"" or
+"dateformat: 02/01/2006", as -printconfig writes.
+Blank lines, comments and TOML tables or YAML documents markers are ignored, as are redacted secrets.
+If readConfigFile fails to read the file or parse a line, it returns an error.
+*/
+func readConfigFile(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var flags []string
+
+	scnr := bufio.NewScanner(file)
+
+	for lineN := 1; scnr.Scan(); lineN++ {
+		line := strings.TrimSpace(scnr.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") || line == "---" {
+			continue
+		}
+
+		key, val, ok := cutConfigLine(line)
+		if !ok {
+			return nil, fmt.Errorf("%w on line %v of %v", errConfigLine, lineN, name)
+		}
+
+		val, err = configValue(val)
+		if err != nil {
+			return nil, fmt.Errorf("%w on line %v of %v", err, lineN, name)
+		}
+
+		if val != redacted {
+			flags = append(flags, "-"+strings.ToLower(key)+"="+val)
+		}
+	}
+
+	err = scnr.Err()
+	if err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+
+	return flags, nil
+}
+
+/*
+CutConfigLine returns the key and value of the line, separated by "=" in TOML or ":" in YAML, and true.
+If the line has no key, cutConfigLine returns false.
+*/
+func cutConfigLine(line string) (string, string, bool) {
+	inx := strings.IndexAny(line, "=:")
+	if inx < 1 {
+		return "", "", false
+	}
+
+	key := strings.TrimSpace(line[:inx])
+	if strings.ContainsAny(key, " \t\"'") {
+		return "", "", false
+	}
+
+	return key, strings.TrimSpace(line[inx+1:]), true
+}
+
+/*
+ConfigValue returns the value, unquoted if it is a quoted string, without any comment after it, and nil.
+Double-quoted strings have TOML and YAML escapes, single-quoted ones none, as in both.
+If the quotes of the value are not matched, configValue returns an error.
+*/
+func configValue(val string) (string, error) {
+	switch {
+	case strings.HasPrefix(val, `"`):
+		quoted, err := strconv.QuotedPrefix(val)
+		if err != nil {
+			return "", fmt.Errorf("strconv.QuotedPrefix: %w", err)
+		}
+
+		return strconv.Unquote(quoted)
+	case strings.HasPrefix(val, "'"):
+		end := strings.Index(val[1:], "'")
+		if end < 0 {
+			return "", errConfigLine
+		}
+
+		return val[1 : end+1], nil
+	}
+
+	val, _, _ = strings.Cut(val, " #")
+
+	return strings.TrimSpace(val), nil
+}
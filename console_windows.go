@@ -0,0 +1,38 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import "syscall"
+
+// CodePageUTF8 is the Windows code page identifier of UTF-8.
+const codePageUTF8 = 65001
+
+/*
+On Windows, the console's code pages are set to UTF-8,
+so memos and account names with non-ASCII characters are neither mangled when read from, nor written to, it.
+Errors are ignored, as there may be no console, such as when output is redirected to a file.
+*/
+func init() {
+	kernel32 := syscall.NewLazyDLL("kernel32.dll")
+
+	for _, name := range []string{"SetConsoleCP", "SetConsoleOutputCP"} {
+		_, _, _ = kernel32.NewProc(name).Call(codePageUTF8)
+	}
+}
@@ -0,0 +1,340 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+A dbSink writes transactions to a table in a database, in batches.
+Each batch is inserted in a database transaction, so a batch is either written or not if cas2trn is interrupted.
+Each transaction has an ID, so writing it again updates it rather than inserting a duplicate.
+*/
+type dbSink struct {
+	added  []string // IDs of the transactions inserted rather than updated, see runReport
+	batch  []transact
+	db     *sql.DB
+	driver string // registered with database/sql e.g. "sqlite", "pgx" or "mysql"
+	dsn    string
+	err    error          // the first error writing, after which transactions are dropped
+	ids    []string       // of the transactions in the batch
+	nSame  map[string]int // transactions with the same fields, by their hash, see id
+	size   int            // of a batch
+}
+
+const (
+	dbDriver     = "sqlite" // the default
+	dbMaxRetries = 5
+	dbRetryDelay = 100 * time.Millisecond // doubled on each retry
+	dbTable      = "transactions"
+)
+
+// DBColumns are the columns of the table of transactions, after the ID, in the order they are inserted.
+var dbColumns = []string{"date", "thisacct", "otheracct", "memo", "amount", "currency", "extras"}
+
+/*
+DBCreateQuery creates the table of transactions if it does not exist.
+Amounts are exact decimals with four decimal places, the most minor units of a currency, see currencyExponents.
+*/
+const dbCreateQuery = "CREATE TABLE IF NOT EXISTS " + dbTable + " (id VARCHAR(64) PRIMARY KEY, " +
+	"date TEXT NOT NULL, thisacct TEXT NOT NULL, otheracct TEXT, memo TEXT NOT NULL, " +
+	"amount NUMERIC(18, 4) NOT NULL, currency TEXT, extras TEXT)"
+
+var errDBDriver = errors.New("database driver is not linked into cas2trn")
+
+// DBBusyErrors are the texts of errors from a database that is locked by another writer, which are retried.
+var dbBusyErrors = []string{"SQLITE_BUSY", "database is locked"}
+
+/*
+Add adds the transaction to the batch, and writes the batch if it is full.
+If writing fails, add keeps the error, see close, and drops this and later transactions.
+*/
+func (snk *dbSink) add(trn *transact) {
+	if snk.err != nil {
+		return
+	}
+
+	snk.batch, snk.ids = append(snk.batch, *trn), append(snk.ids, snk.id(trn))
+	if len(snk.batch) < snk.size {
+		return
+	}
+
+	snk.err = snk.flush()
+}
+
+/*
+Close writes the last batch, closes the database and returns nil.
+If writing or closing fails, close returns the first error.
+*/
+func (snk *dbSink) close() error {
+	if snk.err == nil && len(snk.batch) != 0 {
+		snk.err = snk.flush()
+	}
+
+	if snk.db == nil {
+		return snk.err
+	}
+
+	return errors.Join(snk.err, snk.db.Close())
+}
+
+/*
+Flush writes the batch to the database, opening it and creating the table if needed, and returns nil.
+If the database is busy, writing is retried after a delay that doubles each time.
+If flush fails to write the batch, it returns an error.
+*/
+func (snk *dbSink) flush() error {
+	if snk.db == nil {
+		err := snk.open()
+		if err != nil {
+			return err
+		}
+	}
+
+	delay := dbRetryDelay
+
+	for retryN := 0; ; retryN++ {
+		err := snk.insert()
+		if err == nil || !isBusy(err) || retryN == dbMaxRetries {
+			snk.batch, snk.ids = snk.batch[:0], snk.ids[:0]
+
+			return err
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+/*
+Open opens the database, creating the table of transactions if it does not exist, and returns nil.
+If the driver is not linked into cas2trn, or open fails to open the database or create the table,
+it returns an error.
+*/
+func (snk *dbSink) open() error {
+	if !slices.Contains(sql.Drivers(), snk.driver) {
+		return fmt.Errorf("%w: %v", errDBDriver, snk.driver)
+	}
+
+	var err error
+
+	snk.db, err = sql.Open(snk.driver, snk.dsn)
+	if err != nil {
+		return fmt.Errorf("sql.Open: %w", err)
+	}
+
+	_, err = snk.db.Exec(dbCreateQuery)
+	if err != nil {
+		return fmt.Errorf("sql.DB.Exec: %w", err)
+	}
+
+	return nil
+}
+
+/*
+ID returns the ID of the transaction, a hash of its date, accounts, memo, amount and currency.
+Transactions with the same fields, which are not duplicates, are numbered in the order they are written.
+*/
+func (snk *dbSink) id(trn *transact) string {
+	hash := sha256.Sum256([]byte(strings.Join([]string{trn.date, trn.thisAcct, trn.otherAcct, trn.memo,
+		strconv.FormatFloat(trn.amount, 'f', -1, 64), trn.currency}, "\x00")))
+	key := hex.EncodeToString(hash[:])
+
+	if snk.nSame == nil {
+		snk.nSame = make(map[string]int)
+	}
+
+	snk.nSame[key]++
+
+	const idLen = 32 // hex digits of the hash in the ID
+
+	return key[:idLen] + "-" + strconv.Itoa(snk.nSame[key])
+}
+
+// Placeholder returns the placeholder of the numbered parameter of a query, from one, in the syntax of the driver.
+func (snk *dbSink) placeholder(num int) string {
+	if slices.Contains([]string{"pgx", "postgres"}, snk.driver) {
+		return "$" + strconv.Itoa(num)
+	}
+
+	return "?"
+}
+
+/*
+InsertQuery returns the query that inserts a transaction into the table, or updates it if its ID is there.
+The placeholders and upsert syntax are those of the database driver.
+*/
+func (snk *dbSink) insertQuery() string {
+	cols := append([]string{"id"}, dbColumns...)
+	places := make([]string, len(cols))
+	sets := make([]string, len(dbColumns))
+
+	for inx := range cols {
+		places[inx] = snk.placeholder(inx + 1)
+	}
+
+	query := "INSERT INTO " + dbTable + " (" + strings.Join(cols, ", ") + ") VALUES (" +
+		strings.Join(places, ", ") + ")"
+
+	if snk.driver == "mysql" {
+		for inx, col := range dbColumns {
+			sets[inx] = col + " = VALUES(" + col + ")"
+		}
+
+		return query + " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	}
+
+	for inx, col := range dbColumns {
+		sets[inx] = col + " = excluded." + col
+	}
+
+	return query + " ON CONFLICT (id) DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+/*
+Insert inserts the transactions in the batch into the table in one database transaction and returns nil.
+Extra fields are stored as a JSON object.
+The IDs of transactions not already in the table are added to those inserted, once the batch is committed.
+If insert fails, the database transaction is rolled back and insert returns an error.
+*/
+func (snk *dbSink) insert() error {
+	dbTx, err := snk.db.Begin()
+	if err != nil {
+		return fmt.Errorf("sql.DB.Begin: %w", err)
+	}
+	defer dbTx.Rollback() // error is ignored as it fails after commit
+
+	stmt, err := dbTx.Prepare(snk.insertQuery())
+	if err != nil {
+		return fmt.Errorf("sql.Tx.Prepare: %w", err)
+	}
+	defer stmt.Close()
+
+	var added []string
+
+	for inx, trn := range snk.batch {
+		var nSame int
+
+		err = dbTx.QueryRow("SELECT COUNT(*) FROM "+dbTable+" WHERE id = "+snk.placeholder(1),
+			snk.ids[inx]).Scan(&nSame)
+		if err != nil {
+			return fmt.Errorf("sql.Row.Scan: %w", err)
+		}
+
+		if nSame == 0 {
+			added = append(added, snk.ids[inx])
+		}
+
+		extras := make(map[string]string, len(trn.extraNames))
+		for jnx, name := range trn.extraNames {
+			extras[name] = trn.extras[jnx]
+		}
+
+		data, _ := json.Marshal(extras) // a map of strings cannot fail to marshal
+
+		_, err = stmt.Exec(snk.ids[inx], trn.date, trn.thisAcct, trn.otherAcct, trn.memo, trn.amount,
+			trn.currency, string(data))
+		if err != nil {
+			return fmt.Errorf("sql.Stmt.Exec: %w", err)
+		}
+	}
+
+	err = dbTx.Commit()
+	if err != nil {
+		return fmt.Errorf("sql.Tx.Commit: %w", err)
+	}
+
+	snk.added = append(snk.added, added...)
+
+	return nil
+}
+
+/*
+Remove deletes the transactions with the IDs from the table in one database transaction,
+then closes the database, and returns the number deleted and nil.
+If remove fails, the database transaction is rolled back and remove returns an error.
+*/
+func (snk *dbSink) remove(ids []string) (int64, error) {
+	err := snk.open()
+	if err != nil {
+		return 0, err
+	}
+	defer snk.db.Close()
+
+	dbTx, err := snk.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("sql.DB.Begin: %w", err)
+	}
+	defer dbTx.Rollback() // error is ignored as it fails after commit
+
+	var nRemoved int64
+
+	for _, id := range ids {
+		res, err := dbTx.Exec("DELETE FROM "+dbTable+" WHERE id = "+snk.placeholder(1), id)
+		if err != nil {
+			return 0, fmt.Errorf("sql.Tx.Exec: %w", err)
+		}
+
+		num, _ := res.RowsAffected() // drivers that cannot count leave the count short
+		nRemoved += num
+	}
+
+	err = dbTx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("sql.Tx.Commit: %w", err)
+	}
+
+	return nRemoved, nil
+}
+
+/*
+SqliteFile returns the name of the file of the SQLite database with the data source name, and true.
+The SQLite driver is registered as both "sqlite" and "sqlite3", and its data source name can be a file name
+or a "file:" URI, either with query parameters, e.g. "file:trn.db?_busy_timeout=5000".
+If the driver is not SQLite, or the database is in memory, sqliteFile returns false.
+*/
+func sqliteFile(driver, dsn string) (string, bool) {
+	if dsn == "" || !slices.Contains([]string{dbDriver, "sqlite3"}, driver) {
+		return "", false
+	}
+
+	name, query, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	if name == "" || name == ":memory:" || strings.Contains(query, "mode=memory") {
+		return "", false
+	}
+
+	return name, true
+}
+
+// IsBusy returns true if the error is from a database that is locked by another writer.
+func isBusy(err error) bool {
+	return slices.ContainsFunc(dbBusyErrors, func(txt string) bool {
+		return strings.Contains(err.Error(), txt)
+	})
+}
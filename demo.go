@@ -0,0 +1,99 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const demoCmd = "demo"
+
+/*
+A demoStatement is an anonymised sample statement, in the format of a bank, and the flags, starting with
+a preset, that translate it.
+They let new users see what cas2trn does before trying it on their own statements.
+*/
+type demoStatement struct {
+	title string
+	flags []string
+	stmt  string
+}
+
+// DemoStatements are the statements translated by the demo command.
+var demoStatements = []demoStatement{
+	{
+		title: "Kiwibank full CSV statement, with a header and running balances",
+		flags: []string{"-preset=kiwibank-full"},
+		stmt: "Account number,Date,Memo/Description,Source Code (payment type),TP ref,TP part,TP code," +
+			"OP ref,OP part,OP code,OP name,OP Bank Account Number,Amount (credit),Amount (debit),Amount,Balance\n" +
+			"38-9000-7654321-00,02-01-2025,EFTPOS PURCHASE Corner Bakery,EFTPOS,,,,,,,,,,6.50,-6.50,1243.50\n" +
+			"38-9000-7654321-00,15-01-2025,SALARY Example Ltd,DC,,,,,,,Example Ltd,12-3456-7890123-00," +
+			"2100.00,,2100.00,3343.50\n",
+	},
+	{
+		title: "credit union statement, with debit and credit fields and no header",
+		flags: []string{"-preset=dmy-debit-credit", "-thisacct=Assets:Current:PCUS1", "-currency=NZD"},
+		stmt:  "24/12/2019,Brumby's,6.50,,330.04\n25/12/2019,Interest,,0.12,330.16\n",
+	},
+	{
+		title: "European statement, with \";\" delimiters and \",\" decimal separators",
+		flags: []string{"-preset=eu-semicolon", "-thisacct=Assets:Girokonto", "-currency=EUR"},
+		stmt:  "Buchungstag;Verwendungszweck;Betrag\n02.01.2025;Bäckerei Müller;-6,50\n31.01.2025;Gehalt;2.100,00\n",
+	},
+}
+
+// DemoOutputs are the output formats the demo statements are translated into.
+var demoOutputs = []string{outputStandard, outputDebitCredit, outputLedger}
+
+/*
+RunDemo translates each demo statement into each demo output format, writes the statement, its flags
+and the transactions to the writer, and returns nil.
+If a demo statement fails to translate, runDemo returns an error.
+*/
+func runDemo(writer io.Writer) error {
+	for _, demo := range demoStatements {
+		fmt.Fprintf(writer, "# A %v:\n%v", demo.title, demo.stmt)
+
+		for _, output := range demoOutputs {
+			args := append([]string{"-output=" + output}, demo.flags...)
+
+			cfg, err := parseConfig(flag.NewFlagSet(demoCmd, flag.ContinueOnError), args)
+			if err != nil {
+				return fmt.Errorf("parseConfig: %w", err)
+			}
+
+			fmt.Fprintf(writer, "\n# %v %v\n", pgmName, strings.Join(args, " "))
+
+			tlr := translator{cfg: cfg, write: func(trn *transact) { fmt.Fprintln(writer, trn.record(output)) }}
+
+			err = tlr.translateStatement(strings.NewReader(demo.stmt))
+			if err != nil {
+				return fmt.Errorf("translator.translateStatement: %w", err)
+			}
+		}
+
+		fmt.Fprintln(writer)
+	}
+
+	return nil
+}
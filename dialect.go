@@ -0,0 +1,374 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+A dialect is the variant of CSV format used by a statement.
+Each character is zero if it is not set, then the default is used.
+*/
+type dialect struct {
+	decimal   rune // decimal separator in amounts, '.' (the default) or ','
+	delimiter rune // between fields, default ','
+	quote     rune // around fields, '"' (the default) or '\''
+	recordSep rune // between records, default line breaks, see lineEndReader
+}
+
+/*
+A lineEndReader reads text with its record separators normalised to line feeds, as csv.Reader expects.
+Carriage returns alone, the line breaks of classic Mac OS, and the record separator, if set, are replaced.
+Carriage return line feed pairs are kept.
+*/
+type lineEndReader struct {
+	rdr       *bufio.Reader
+	recordSep rune
+}
+
+// A dialectReader reads CSV records in a dialect.
+type dialectReader struct {
+	*csv.Reader
+	swapQuotes bool // quote is '\'', which is swapped with '"' as csv.Reader only supports the latter
+}
+
+// A swapReader swaps double and single quotes in what it reads.
+type swapReader struct {
+	rdr io.Reader
+}
+
+// BOM is the UTF-8 byte order mark, which Windows programs such as Excel write at the start of text files.
+const bom = "\ufeff"
+
+// DialectSampleSize is the number of bytes at the start of a statement that its dialect is detected from.
+const dialectSampleSize = 4096
+
+var (
+	errDecimal   = errors.New("decimal separator must be \".\" or \",\"")
+	errDelimiter = errors.New("delimiter must be a single character other than a quote or line break")
+	errQuote     = errors.New("quote must be '\"' or \"'\"")
+	errRecordSep = errors.New("record separator must be a single character other than a quote or the delimiter")
+)
+
+// The patterns of amounts with a comma or point decimal separator, and optional thousands separators.
+var (
+	commaDecimalPattern = regexp.MustCompile(`^[-+]?\d{1,3}(\.?\d{3})*,\d+$`)
+	pointDecimalPattern = regexp.MustCompile(`^[-+]?\d{1,3}(,?\d{3})*\.\d+$`)
+)
+
+// QuoteSwapper swaps double and single quotes.
+var quoteSwapper = strings.NewReplacer(`"`, `'`, `'`, `"`)
+
+/*
+DetectDialect returns the dialect of the sample of a statement.
+The delimiter and quote split most lines into the same number of fields, more than one,
+and if that is a tie, the quote starts more fields.
+The decimal separator is the one most often in amounts.
+*/
+func detectDialect(sample []byte) dialect {
+	lines := strings.Split(strings.ReplaceAll(string(sample), "\r\n", "\n"), "\n")
+	if 1 < len(lines) {
+		lines = lines[:len(lines)-1] // the last line may be cut short
+	}
+
+	dlc := dialect{decimal: '.', delimiter: ',', quote: '"'}
+	bestN, bestNQuoted := 0, 0
+
+	for _, delim := range []rune{',', ';', '\t', '|'} {
+		for _, quote := range []rune{'"', '\''} {
+			nLines := make(map[int]int) // by number of fields
+			nQuoted := 0
+
+			for _, line := range lines {
+				flds, nq := splitLine(line, delim, quote)
+				nLines[len(flds)]++
+				nQuoted += nq
+			}
+
+			nFlds := slices.MaxFunc(slices.Collect(maps.Keys(nLines)), func(a, b int) int {
+				return cmp.Or(nLines[a]-nLines[b], a-b)
+			})
+			if 1 < nFlds && (bestN < nLines[nFlds] || (bestN == nLines[nFlds] && bestNQuoted < nQuoted)) {
+				dlc.delimiter, dlc.quote = delim, quote
+				bestN, bestNQuoted = nLines[nFlds], nQuoted
+			}
+		}
+	}
+
+	nDecimals := make(map[rune]int)
+
+	for _, line := range lines {
+		flds, _ := splitLine(line, dlc.delimiter, dlc.quote)
+		for _, fld := range flds {
+			switch {
+			case commaDecimalPattern.MatchString(fld):
+				nDecimals[',']++
+			case pointDecimalPattern.MatchString(fld):
+				nDecimals['.']++
+			}
+		}
+	}
+
+	if nDecimals['.'] < nDecimals[','] {
+		dlc.decimal = ','
+	}
+
+	return dlc
+}
+
+/*
+IsValid returns nil if the characters set in this dialect are valid.
+If not, isValid returns the first error.
+*/
+func (dlc dialect) isValid() error {
+	if dlc.decimal != 0 && dlc.decimal != '.' && dlc.decimal != ',' {
+		return errDecimal
+	}
+
+	if dlc.delimiter == utf8.RuneError || strings.ContainsRune("\r\n\"'", dlc.delimiter) {
+		return errDelimiter
+	}
+
+	if dlc.quote != 0 && dlc.quote != '"' && dlc.quote != '\'' {
+		return errQuote
+	}
+
+	if dlc.recordSep == utf8.RuneError || dlc.recordSep == '"' || dlc.recordSep == '\'' ||
+		(dlc.recordSep != 0 && dlc.recordSep == cmp.Or(dlc.delimiter, ',')) {
+		return errRecordSep
+	}
+
+	return nil
+}
+
+/*
+NewReader returns a reader of CSV records from the reader in this dialect.
+If the configuration detects dialects, the dialect is detected from the start of the reader,
+then overridden by the characters set in this dialect.
+It also returns the dialect, with the defaults for characters that are not set.
+*/
+func (dlc dialect) newReader(rdr io.Reader, detect bool) (dialectReader, dialect) {
+	out := dialect{decimal: '.', delimiter: ',', quote: '"'}
+
+	if detect {
+		brdr := bufio.NewReaderSize(rdr, dialectSampleSize)
+		sample, _ := brdr.Peek(dialectSampleSize) // a short statement is the whole sample
+		out, rdr = detectDialect(sample), brdr
+	}
+
+	for _, chr := range []struct{ set, out *rune }{
+		{&dlc.decimal, &out.decimal}, {&dlc.delimiter, &out.delimiter}, {&dlc.quote, &out.quote},
+	} {
+		if *chr.set != 0 {
+			*chr.out = *chr.set
+		}
+	}
+
+	swap := out.quote == '\''
+	if swap {
+		rdr = &swapReader{rdr}
+	}
+
+	reader := csv.NewReader(rdr)
+	reader.Comma = out.delimiter
+
+	return dialectReader{Reader: reader, swapQuotes: swap}, out
+}
+
+/*
+Number returns the amount in this dialect with a point decimal separator, as parseFloat64 expects.
+If the decimal separator is a comma, points are thousands separators and removed,
+otherwise commas grouping digits in threes before a point, e.g. "1,234.56" in PDF statements, are.
+*/
+func (dlc dialect) number(amt string) string {
+	if dlc.decimal != ',' {
+		if strings.Contains(amt, ",") && pointDecimalPattern.MatchString(amt) {
+			return strings.ReplaceAll(amt, ",", "")
+		}
+
+		return amt
+	}
+
+	return strings.ReplaceAll(strings.ReplaceAll(amt, ".", ""), ",", ".")
+}
+
+// ParseRune returns the character in the string, zero if it is empty string, or utf8.RuneError if it has more.
+func parseRune(str string) rune {
+	switch utf8.RuneCountInString(str) {
+	case 0:
+		return 0
+	case 1:
+		r, _ := utf8.DecodeRuneInString(str)
+
+		return r
+	default:
+		return utf8.RuneError
+	}
+}
+
+/*
+SplitLine returns the fields of the line split by the delimiter, and the number of them that are quoted.
+Quotes are removed from fields, and doubled quotes inside them are undoubled.
+*/
+func splitLine(line string, delim, quote rune) ([]string, int) {
+	var (
+		flds    []string
+		nQuoted int
+	)
+
+	for {
+		line = strings.TrimLeft(line, " ")
+
+		if rest, ok := strings.CutPrefix(line, string(quote)); ok {
+			var fld string
+
+			fld, line = unquote(rest, quote)
+			flds = append(flds, fld)
+			nQuoted++
+
+			inx := strings.IndexRune(line, delim)
+			if inx < 0 {
+				return flds, nQuoted
+			}
+
+			line = line[inx+utf8.RuneLen(delim):]
+
+			continue
+		}
+
+		fld, rest, ok := strings.Cut(line, string(delim))
+		flds = append(flds, strings.TrimSpace(fld))
+
+		if !ok {
+			return flds, nQuoted
+		}
+
+		line = rest
+	}
+}
+
+/*
+Unquote returns the start of the text up to its closing quote, with doubled quotes undoubled,
+and the rest of the text after the quote.
+*/
+func unquote(text string, quote rune) (string, string) {
+	var sbr strings.Builder
+
+	qte := string(quote)
+
+	for {
+		inx := strings.Index(text, qte)
+		if inx < 0 {
+			return sbr.String() + text, ""
+		}
+
+		sbr.WriteString(text[:inx])
+		text = text[inx+len(qte):]
+
+		if !strings.HasPrefix(text, qte) {
+			return sbr.String(), text
+		}
+
+		sbr.WriteString(qte)
+		text = text[len(qte):]
+	}
+}
+
+// Read reads a record, swapping quotes back in its fields if they were swapped.
+func (drdr dialectReader) Read() ([]string, error) {
+	rec, err := drdr.Reader.Read()
+	if drdr.swapQuotes {
+		for inx, fld := range rec {
+			rec[inx] = quoteSwapper.Replace(fld)
+		}
+	}
+
+	return rec, err
+}
+
+// Read reads bytes with double and single quotes swapped.
+func (srdr *swapReader) Read(buf []byte) (int, error) {
+	n, err := srdr.rdr.Read(buf)
+
+	for inx, b := range buf[:n] {
+		switch b {
+		case '"':
+			buf[inx] = '\''
+		case '\'':
+			buf[inx] = '"'
+		}
+	}
+
+	return n, err
+}
+
+// Read reads text with carriage returns alone, and the record separator, replaced by line feeds.
+func (lrdr *lineEndReader) Read(buf []byte) (int, error) {
+	n := 0
+
+	for n+utf8.UTFMax <= len(buf) {
+		r, size, err := lrdr.rdr.ReadRune()
+		if errors.Is(err, io.EOF) && 0 < n {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+
+		switch {
+		case r == utf8.RuneError && size == 1: // not UTF-8, so copied as is
+			_ = lrdr.rdr.UnreadRune() // cannot fail after ReadRune
+			buf[n], _ = lrdr.rdr.ReadByte()
+			n++
+
+			continue
+		case r == '\r':
+			if next, _ := lrdr.rdr.Peek(1); len(next) == 0 || next[0] != '\n' {
+				r = '\n'
+			}
+		case r == lrdr.recordSep && r != 0:
+			r = '\n'
+		}
+
+		n += utf8.EncodeRune(buf[n:], r)
+	}
+
+	return n, nil
+}
+
+// SkipBOM returns a reader of the text from the reader without its byte order mark, if any.
+func skipBOM(rdr io.Reader) io.Reader {
+	brdr := bufio.NewReader(rdr)
+
+	start, _ := brdr.Peek(len(bom)) // a short text cannot start with the mark
+	if string(start) == bom {
+		_, _ = brdr.Discard(len(bom))
+	}
+
+	return brdr
+}
@@ -0,0 +1,106 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const (
+	presetsCmd     = "presets"
+	presetsDiffCmd = "diff"
+)
+
+var (
+	errPresetsCmd  = errors.New("presets needs a subcommand, diff")
+	errPresetsDiff = errors.New("presets diff needs the names of two configuration files, profiles or presets")
+)
+
+/*
+RunPresets runs the presets subcommand in the arguments, writes its output to the writer and returns nil.
+If the subcommand is not valid or fails, runPresets returns an error.
+*/
+func runPresets(writer io.Writer, args []string) error {
+	switch {
+	case len(args) == 0 || args[0] != presetsDiffCmd:
+		return errPresetsCmd
+	case len(args) != 3:
+		return errPresetsDiff
+	}
+
+	return diffPresets(writer, args[1], args[2])
+}
+
+/*
+PresetArgs returns the flags that select the named configuration: a TOML or YAML configuration file,
+see readConfigFile, a profile, whose name can omit the profileExt extension, see readProfile,
+or else a user or built-in preset, see findPreset.
+*/
+func presetArgs(name string) []string {
+	switch ext := filepath.Ext(name); {
+	case slices.Contains([]string{".toml", ".yaml", ".yml"}, ext):
+		return []string{"-" + configFlag, name}
+	case ext == profileExt:
+		return []string{"-" + profileFlag, name}
+	}
+
+	if _, err := os.Stat(name + profileExt); err == nil {
+		return []string{"-" + profileFlag, name}
+	}
+
+	return []string{"-" + presetFlag, name}
+}
+
+/*
+DiffPresets writes the options that differ between the two named configurations, see presetArgs, and returns nil.
+Each option is written with its value in both configurations, defaults included,
+e.g. `dateformat "02-01-2006" "2006-01-02"`.
+A preset configures a statement format rather than an account, so one without this account is compared too.
+If diffPresets fails to read or parse a configuration, it returns an error.
+*/
+func diffPresets(writer io.Writer, name1, name2 string) error {
+	names := []string{name1, name2}
+	fsets := make([]*flag.FlagSet, len(names))
+
+	for inx, name := range names {
+		fsets[inx] = flag.NewFlagSet(name, flag.ContinueOnError)
+		fsets[inx].SetOutput(io.Discard)
+
+		_, err := parseConfig(fsets[inx], presetArgs(name))
+		if err != nil && !errors.Is(err, errThisAcctOpt) {
+			return fmt.Errorf("%v: %w", name, err)
+		}
+	}
+
+	fsets[0].VisitAll(func(flg *flag.Flag) {
+		other := fsets[1].Lookup(flg.Name).Value.String()
+		if flg.Name != configFlag && flg.Name != presetFlag && flg.Name != profileFlag &&
+			flg.Value.String() != other {
+			fmt.Fprintf(writer, "%v %q %q\n", flg.Name, flg.Value.String(), other)
+		}
+	})
+
+	return nil
+}
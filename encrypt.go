@@ -0,0 +1,71 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+var (
+	errEncrypt          = errors.New("no encryption command found, install age or gnupg")
+	errEncryptClipboard = errors.New("transactions written to the clipboard cannot be encrypted, see encryptto")
+)
+
+/*
+EncryptArgs returns the name and arguments of the command that encrypts to the recipient.
+Recipients that are age public keys, e.g. "age1...", or SSH public keys are encrypted to with age,
+and others, such as an email address or key ID, with GPG.
+GPG only encrypts to a key that is valid in its trust model, which can be changed in gpg.conf.
+*/
+func encryptArgs(recipient string) (string, []string) {
+	for _, prefix := range []string{"age1", "ssh-ed25519 ", "ssh-rsa "} {
+		if strings.HasPrefix(recipient, prefix) {
+			return "age", []string{"--encrypt", "--recipient", recipient}
+		}
+	}
+
+	return "gpg", []string{"--batch", "--yes", "--encrypt", "--recipient", recipient}
+}
+
+/*
+Encrypt returns the data encrypted to the recipient, see encryptArgs, and nil.
+If the command is not installed or fails to encrypt, encrypt returns an error.
+*/
+func encrypt(data []byte, recipient string) ([]byte, error) {
+	name, args := encryptArgs(recipient)
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, errEncrypt
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = bytes.NewReader(data)
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("exec.Cmd.Output: %w", err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,154 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DateFormatWords replaces the elements of a Go date format with words, longest first.
+var dateFormatWords = strings.NewReplacer(
+	"January", "month name", "Monday", "weekday name", "2006", "year",
+	"Jan", "abbreviated month name", "Mon", "abbreviated weekday",
+	"01", "month", "02", "day", "_2", "day", "06", "two-digit year", "15", "hour", "03", "hour",
+	"04", "minute", "05", "second", "PM", "AM or PM", "MST", "time zone",
+	"1", "month", "2", "day", "3", "hour")
+
+/*
+Explanation returns how the configuration interprets a record, in plain English, one sentence per line,
+such as "column 2 is the date in the format day/month/year".
+It makes reviewing a configuration, such as one shared by others, easier.
+*/
+func (cfg config) explanation() []string {
+	var lines []string
+
+	cols := map[uint8]string{
+		cfg.amountI: "the amount", cfg.balanceI: "the running balance", cfg.chequeI: "the cheque number",
+		cfg.creditI: "credits", cfg.currencyI: "the currency", cfg.dateI: "the date in the format " +
+			dateFormatWords.Replace(cfg.dateFormat),
+		cfg.debitI: "debits", cfg.memoI: "the memo", cfg.otherAcctI: "the other account",
+		cfg.origAmtI: "the original amount, before conversion", cfg.origCurI: "the original currency",
+		cfg.escrowI: "the escrow part of a loan payment", cfg.interestI: "the interest part of a loan payment",
+		cfg.principalI: "the principal part of a loan payment",
+		cfg.feeI:       "fees", cfg.priceI: "the price of a unit of a security", cfg.quantityI: "the quantity traded",
+		cfg.statusI: fmt.Sprintf("the status, which is %q for pending transactions", cfg.pendingMark),
+		cfg.symbolI: "the ticker symbol of a security", cfg.thisAcctI: "this account",
+	}
+	if cfg.dcI != 0 {
+		cols[cfg.dcI] = fmt.Sprintf("debit if it is %q or credit if it is %q, which gives the sign of the amount",
+			cfg.debitMark, cfg.creditMark)
+	}
+
+	for inx, acct := range cfg.earnings {
+		cols[inx] = fmt.Sprintf("earnings, whose other account is %q", acct)
+	}
+
+	for inx, acct := range cfg.deductions {
+		cols[inx] = fmt.Sprintf("deductions, whose other account is %q", acct)
+	}
+
+	delete(cols, 0)
+
+	var ignored []string
+
+	for inx := uint8(1); inx <= cfg.nFields; inx++ {
+		if desc, ok := cols[inx]; ok {
+			lines = append(lines, fmt.Sprintf("column %v is %v", inx, desc))
+		} else {
+			ignored = append(ignored, strconv.Itoa(int(inx)))
+		}
+	}
+
+	if len(ignored) == 1 {
+		lines = append(lines, "column "+ignored[0]+" is ignored")
+	} else if 1 < len(ignored) {
+		lines = append(lines, "columns "+strings.Join(ignored, ", ")+" are ignored")
+	}
+
+	lines = append(lines, cfg.optionsExplanation()...)
+
+	return slices.Insert(lines, 0, fmt.Sprintf("a record has %v fields", cfg.nFields))
+}
+
+// OptionsExplanation returns how the options of the configuration, other than field indexes, affect a record.
+func (cfg config) optionsExplanation() []string {
+	var lines []string
+
+	switch {
+	case cfg.thisAcct != "" && cfg.thisAcctI != 0:
+		lines = append(lines, fmt.Sprintf("this account is %q when its column is empty", cfg.thisAcct))
+	case cfg.thisAcct != "":
+		lines = append(lines, fmt.Sprintf("this account is %q", cfg.thisAcct))
+	case cfg.thisAcctPattern != nil:
+		lines = append(lines, fmt.Sprintf("this account is matched in the statement's file name by %q",
+			cfg.thisAcctPattern))
+	}
+
+	if cfg.otherAcct != "" {
+		lines = append(lines, fmt.Sprintf("the other account is %q if it is empty after rules", cfg.otherAcct))
+	}
+
+	if cfg.currency != "" {
+		line := "amounts are in " + cfg.currency
+		if cfg.currencyI != 0 {
+			line += " when their currency column is empty"
+		}
+
+		lines = append(lines, line)
+	}
+
+	if cfg.dialect.delimiter != 0 && cfg.dialect.delimiter != ',' {
+		lines = append(lines, fmt.Sprintf("fields are separated by %q", cfg.dialect.delimiter))
+	}
+
+	if cfg.dialect.decimal == ',' {
+		lines = append(lines, "amounts have a decimal comma e.g. \"1.234,56\"")
+	}
+
+	if cfg.amountPattern != nil {
+		lines = append(lines, fmt.Sprintf("the amount is extracted from its column by %q", cfg.amountPattern))
+	}
+
+	if cfg.datePattern != nil {
+		lines = append(lines, fmt.Sprintf("the date is extracted from its column by %q", cfg.datePattern))
+	}
+
+	if cfg.firstLine != 0 || cfg.lastLine != 0 {
+		lines = append(lines, fmt.Sprintf("only records on lines %v to %v are translated",
+			max(cfg.firstLine, 1), lineOrEnd(cfg.lastLine)))
+	}
+
+	if 0 < len(cfg.rules) {
+		lines = append(lines, fmt.Sprintf("%v rules set fields from patterns matched in fields", len(cfg.rules)))
+	}
+
+	return lines
+}
+
+// LineOrEnd returns the line number, or "the end" if it is zero.
+func lineOrEnd(lineN uint) string {
+	if lineN == 0 {
+		return "the end"
+	}
+
+	return strconv.FormatUint(uint64(lineN), 10)
+}
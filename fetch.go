@@ -0,0 +1,356 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	fetchCmd        = "fetch"
+	imapPasswordEnv = "CAS2TRN_IMAPPASSWORD"
+)
+
+// An imapConfig configures the fetching of statements from an IMAP mailbox.
+type imapConfig struct {
+	mailbox  string // mandatory e.g. "INBOX"
+	password string // mandatory
+	search   string // IMAP search criteria, mandatory e.g. "UNSEEN FROM \"statements@bank.example\""
+	server   string // host and port, mandatory e.g. "imap.example.com:993"
+	user     string // mandatory
+}
+
+/*
+An imapConn is a connection to an IMAP server.
+Commands are tagged with a sequence number.
+*/
+type imapConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tagN   int
+}
+
+// An attachment is a file attached to an email message.
+type attachment struct {
+	data []byte
+	name string
+}
+
+var (
+	errIMAPConfig   = errors.New("fetch needs an IMAP server, user, password, mailbox and search criteria")
+	errIMAPResponse = errors.New("IMAP server response is not OK")
+)
+
+// LiteralPattern matches the size of a literal string at the end of an IMAP response line e.g. "{1234}".
+var literalPattern = regexp.MustCompile(`\{(\d+)\}\r\n$`)
+
+/*
+Attachments returns the CSV, PDF and zip attachments of the email message and nil.
+Attachments of other types, such as OFX, are not returned.
+If attachments fails to parse the message, it returns an error.
+*/
+func attachments(msg []byte) ([]attachment, error) {
+	parsed, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		return nil, fmt.Errorf("mail.ReadMessage: %w", err)
+	}
+
+	return partAttachments(mail.Header(parsed.Header), parsed.Body)
+}
+
+/*
+Command sends the command to the IMAP server and returns the untagged responses and nil.
+Each response is a line, followed by the literal string it ends with, if any.
+If the command fails, command returns an error.
+*/
+func (ic *imapConn) command(format string, args ...any) ([]string, error) {
+	ic.tagN++
+	tag := "c" + strconv.Itoa(ic.tagN)
+
+	_, err := fmt.Fprintf(ic.conn, tag+" "+format+"\r\n", args...)
+	if err != nil {
+		return nil, fmt.Errorf("net.Conn.Write: %w", err)
+	}
+
+	var resps []string
+
+	for {
+		line, err := ic.reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("bufio.Reader.ReadString: %w", err)
+		}
+
+		if match := literalPattern.FindStringSubmatch(line); match != nil {
+			size, _ := strconv.Atoi(match[1])
+			lit := make([]byte, size)
+
+			_, err = io.ReadFull(ic.reader, lit)
+			if err != nil {
+				return nil, fmt.Errorf("io.ReadFull: %w", err)
+			}
+
+			line += string(lit)
+		}
+
+		if rest, ok := strings.CutPrefix(line, tag+" "); ok {
+			if !strings.HasPrefix(rest, "OK") {
+				return nil, fmt.Errorf("%w: %v", errIMAPResponse, strings.TrimSpace(rest))
+			}
+
+			return resps, nil
+		}
+
+		resps = append(resps, line)
+	}
+}
+
+/*
+RunFetch fetches statements from the IMAP mailbox, translates them and returns nil.
+Each run locks, and checks and backs up, the files it writes like a run that translates files, see runLocked.
+If a schedule is configured, runFetch fetches each time it matches, and only returns if it never matches.
+The service's metrics are exposed if configured.
+If it fails to fetch statements, runFetch returns an error.
+*/
+func runFetch(cfg config) error {
+	if cfg.schedule == nil {
+		tlr := newTranslator(cfg)
+
+		return cfg.runLocked(func() error { return errors.Join(tlr.fetchStatements(), tlr.finish()) })
+	}
+
+	var mts metrics
+
+	if cfg.metricsAddr != "" {
+		err := serveMetrics(cfg.metricsAddr, &mts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return runScheduled(*cfg.schedule, func() (stats, error) {
+		tlr := newTranslator(cfg)
+
+		err := cfg.runLocked(func() error { return errors.Join(tlr.fetchStatements(), tlr.finish()) })
+		mts.record(tlr.stats, err)
+
+		return tlr.stats, err
+	})
+}
+
+/*
+FetchStatements fetches the messages matching the search criteria from the IMAP mailbox and returns nil.
+It translates the financial transactions in the CSV, PDF and zip attachments of each message,
+then marks the message as seen.
+If fetchStatements fails to fetch a message or translate an attachment, it returns an error.
+*/
+func (tlr *translator) fetchStatements() error {
+	imap := tlr.cfg.imap
+	if imap.server == "" || imap.user == "" || imap.password == "" || imap.mailbox == "" || imap.search == "" {
+		return errIMAPConfig
+	}
+
+	host, _, _ := net.SplitHostPort(imap.server)
+
+	conn, err := tls.Dial("tcp", imap.server, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		return fmt.Errorf("tls.Dial: %w", err)
+	}
+	defer conn.Close()
+
+	ic := imapConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	_, err = ic.reader.ReadString('\n') // greeting
+	if err != nil {
+		return fmt.Errorf("bufio.Reader.ReadString: %w", err)
+	}
+
+	_, err = ic.command("LOGIN %v %v", imapQuote(imap.user), imapQuote(imap.password))
+	if err != nil {
+		return err
+	}
+
+	defer ic.command("LOGOUT") // error is ignored as the statements have been fetched
+
+	_, err = ic.command("SELECT %v", imapQuote(imap.mailbox))
+	if err != nil {
+		return err
+	}
+
+	resps, err := ic.command("UID SEARCH %v", imap.search)
+	if err != nil {
+		return err
+	}
+
+	for _, resp := range resps {
+		uids, ok := strings.CutPrefix(strings.TrimSpace(resp), "* SEARCH")
+		if !ok {
+			continue
+		}
+
+		for _, uid := range strings.Fields(uids) {
+			err = ic.fetchMessage(uid, tlr)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+FetchMessage fetches the message with the UID, translates its attachments, marks it as seen and returns nil.
+If fetchMessage fails, it returns an error.
+*/
+func (ic *imapConn) fetchMessage(uid string, tlr *translator) error {
+	resps, err := ic.command("UID FETCH %v BODY.PEEK[]", uid)
+	if err != nil {
+		return err
+	}
+
+	for _, resp := range resps {
+		_, msg, ok := strings.Cut(resp, "}\r\n")
+		if !ok {
+			continue
+		}
+
+		atts, err := attachments([]byte(msg))
+		if err != nil {
+			return fmt.Errorf("message UID %v: %w", uid, err)
+		}
+
+		for _, att := range atts {
+			err = tlr.translateAttachment(att)
+			if err != nil {
+				return fmt.Errorf("message UID %v attachment %v: %w", uid, att.name, err)
+			}
+		}
+	}
+
+	_, err = ic.command("UID STORE %v +FLAGS.SILENT (\\Seen)", uid)
+
+	return err
+}
+
+// ImapQuote returns the string as an IMAP quoted string.
+func imapQuote(str string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(str) + `"`
+}
+
+/*
+PartAttachments returns the CSV, PDF and zip attachments in the message part with the header and body, and nil.
+Multipart parts are searched recursively.
+If partAttachments fails to parse a part, it returns an error.
+*/
+func partAttachments(header mail.Header, body io.Reader) ([]attachment, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var atts []attachment
+
+		rdr := multipart.NewReader(body, params["boundary"])
+
+		for {
+			part, err := rdr.NextPart()
+			if errors.Is(err, io.EOF) {
+				return atts, nil
+			} else if err != nil {
+				return nil, fmt.Errorf("multipart.Reader.NextPart: %w", err)
+			}
+
+			partAtts, err := partAttachments(mail.Header(part.Header), part)
+			if err != nil {
+				return nil, err
+			}
+
+			atts = append(atts, partAtts...)
+		}
+	}
+
+	name := params["name"]
+	if _, dispParams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		if dispParams["filename"] != "" {
+			name = dispParams["filename"]
+		}
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".pdf", ".zip":
+		// translatable
+	case ".ofx", ".qfx":
+		fmt.Fprintf(os.Stderr, "%v: warning: attachment %v is OFX, which cannot be translated\n", pgmName, name)
+
+		return nil, nil
+	default:
+		return nil, nil
+	}
+
+	if strings.EqualFold(header.Get("Content-Transfer-Encoding"), "base64") {
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	return []attachment{{data: data, name: name}}, nil
+}
+
+/*
+TranslateAttachment translates the financial transactions in the CSV, PDF or zip attachment and returns nil.
+See translateStatement.
+If it fails to read the attachment, translateAttachment returns an error.
+*/
+func (tlr *translator) translateAttachment(att attachment) error {
+	tlr.fileName = att.name
+
+	switch strings.ToLower(filepath.Ext(att.name)) {
+	case ".pdf":
+		return tlr.translatePDF(att.data)
+	case ".zip":
+		arc, err := zip.NewReader(bytes.NewReader(att.data), int64(len(att.data)))
+		if err != nil {
+			return fmt.Errorf("zip.NewReader: %w", err)
+		}
+
+		return tlr.translateZip(arc)
+	}
+
+	return tlr.translateStatement(bytes.NewReader(att.data))
+}
@@ -0,0 +1,148 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*
+A calendar is the set of days on which a bank does not process transactions,
+such as weekends and public holidays.
+Days are either dates in ISO 8601 format, or weekday names e.g. "Saturday".
+*/
+type calendar map[string]bool
+
+var errHoliday = errors.New("holiday must be a date in ISO 8601 format or a weekday name " +
+	"e.g. \"2025-12-25\" or \"Saturday\"")
+
+/*
+LoadCalendar returns the calendar read from the named file and nil.
+The file contains one date or weekday name per line.
+Blank lines and lines starting with "#" are ignored.
+If loadCalendar fails to read or parse the file, it returns an error.
+*/
+func loadCalendar(name string) (calendar, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cal := make(calendar)
+	scnr := bufio.NewScanner(file)
+	lineN := 0
+
+	for scnr.Scan() {
+		lineN++
+
+		line := strings.TrimSpace(scnr.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		day, ok := parseHoliday(line)
+		if !ok {
+			return nil, fmt.Errorf("%w on line %v", errHoliday, lineN)
+		}
+
+		cal[day] = true
+	}
+
+	err = scnr.Err()
+	if err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+
+	return cal, nil
+}
+
+/*
+ParseHoliday returns the day of the calendar in the string, as a date or a weekday name, and true.
+Weekday names can be abbreviated to three letters, and are not case sensitive.
+If the string is neither, parseHoliday returns false.
+*/
+func parseHoliday(str string) (string, bool) {
+	date, err := time.Parse(time.DateOnly, str)
+	if err == nil {
+		return date.Format(time.DateOnly), true
+	}
+
+	const abbrevLen = 3
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := day.String()
+		if strings.EqualFold(str, name) || strings.EqualFold(str, name[:abbrevLen]) {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// IsOpen returns true if the bank processes transactions on the date, according to the calendar.
+func (cal calendar) isOpen(date time.Time) bool {
+	return !cal[date.Format(time.DateOnly)] && !cal[date.Weekday().String()]
+}
+
+/*
+OpenDays returns the number of days strictly between the dates, in ISO 8601 format,
+on which the bank processes transactions, according to the calendar.
+It assumes the dates are valid, and from is not after to.
+*/
+func (cal calendar) openDays(from, to string) int {
+	start, _ := time.Parse(time.DateOnly, from)
+	end, _ := time.Parse(time.DateOnly, to)
+
+	nDays := 0
+	for date := start.AddDate(0, 0, 1); date.Before(end); date = date.AddDate(0, 0, 1) {
+		if cal.isOpen(date) {
+			nDays++
+		}
+	}
+
+	return nDays
+}
+
+/*
+WriteGaps writes a warning to the writer for each gap in the dates of the transactions of each account,
+of more than the maximum number of days the bank processes transactions on, according to the calendar.
+A gap may be a missing statement, or period of a statement.
+The dates are in ISO 8601 format, by account.
+*/
+func writeGaps(writer io.Writer, dates map[string][]string, maxGap uint, cal calendar) {
+	for _, acct := range slices.Sorted(maps.Keys(dates)) {
+		days := slices.Compact(slices.Sorted(slices.Values(dates[acct])))
+
+		for inx := 1; inx < len(days); inx++ {
+			if nDays := cal.openDays(days[inx-1], days[inx]); int(maxGap) < nDays {
+				fmt.Fprintf(writer, "%v: warning: account %v has no transactions on %v days between %v and %v, "+
+					"a period may be missing\n", pgmName, acct, nDays, days[inx-1], days[inx])
+			}
+		}
+	}
+}
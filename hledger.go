@@ -0,0 +1,268 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+A migration is a cas2trn profile, and rules, equivalent to an hledger CSV rules file.
+Directives that have no equivalent are kept, so they can be migrated by hand.
+*/
+type migration struct {
+	flags       []string   // of the profile, one per line e.g. "-datei=1"
+	rules       [][]string // records of the rule file, see loadRules
+	unsupported []string   // directives of the rules file
+}
+
+const (
+	importCmd     = "import"     // subcommand that seeds a profile from an hledger CSV rules file
+	importRuleExt = ".rules.csv" // of the rule file written by the import command
+)
+
+/*
+HledgerFields are the flags of the field indexes, by the names of hledger CSV fields.
+Fields with other names are ignored.
+*/
+var hledgerFields = map[string]string{
+	"account1": "thisaccti", "account2": "otheraccti", "amount": "amounti", "amount-in": "crediti",
+	"amount-out": "debiti", "balance": "balancei", "code": "chequei", "currency": "currencyi", "date": "datei",
+	"description": "memoi",
+}
+
+// HledgerDateFormat replaces the strftime directives of hledger date formats with their Go equivalents.
+var hledgerDateFormat = strings.NewReplacer(
+	"%Y", "2006", "%y", "06", "%m", "01", "%-m", "1", "%d", "02", "%-d", "2", "%e", "_2", "%b", "Jan",
+	"%B", "January", "%H", "15", "%M", "04", "%S", "05", "%%", "%",
+)
+
+// HledgerAssigns are the fields of transactions, by the names of the hledger fields assigned in if blocks.
+var hledgerAssigns = map[string]string{"account1": "thisacct", "account2": "otheracct", "description": "memo"}
+
+var errImportArgs = errors.New("import needs an hledger CSV rules file and a profile name e.g. \"bank.rules bank\"")
+
+/*
+ImportHledger writes the profile, and rule file if there are rules, equivalent to the hledger CSV rules file
+named by the first argument, named by the second, then returns nil.
+It writes the names of the files written, and directives that have no equivalent, to the writer.
+If importHledger fails to read the rules file or write the profile or rule file, it returns an error.
+*/
+func importHledger(writer io.Writer, args []string) error {
+	if len(args) != 2 {
+		return errImportArgs
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	mgn, err := parseHledger(file)
+	if err != nil {
+		return fmt.Errorf("parseHledger: %w", err)
+	}
+
+	profile, ruleFile := args[1]+profileExt, args[1]+importRuleExt
+	if len(mgn.rules) != 0 {
+		mgn.flags = append(mgn.flags, "-rulefile="+ruleFile)
+	}
+
+	var bldr strings.Builder
+
+	fmt.Fprintf(&bldr, "# imported by %v from %v\n", pgmName, args[0])
+
+	for _, dir := range mgn.unsupported {
+		fmt.Fprintf(&bldr, "# unsupported: %v\n", dir)
+		fmt.Fprintf(writer, "%v: warning: unsupported directive %q\n", pgmName, dir)
+	}
+
+	bldr.WriteString(strings.Join(mgn.flags, "\n") + "\n")
+
+	const perm = 0o600
+
+	err = os.WriteFile(profile, []byte(bldr.String()), perm)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(writer, "%v: wrote %v\n", pgmName, profile)
+
+	if len(mgn.rules) == 0 {
+		return nil
+	}
+
+	var rules strings.Builder
+
+	wtr := csv.NewWriter(&rules)
+
+	err = wtr.WriteAll(mgn.rules)
+	if err != nil {
+		return fmt.Errorf("csv.Writer.WriteAll: %w", err)
+	}
+
+	err = os.WriteFile(ruleFile, []byte(rules.String()), perm)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(writer, "%v: wrote %v\n", pgmName, ruleFile)
+
+	return nil
+}
+
+/*
+ParseHledger returns the migration of the hledger CSV rules read from the reader and nil.
+The skip, fields, date-format, separator, decimal-mark, currency, account1 and account2 directives
+become flags, and if blocks that assign account1, account2 or description become rules.
+An if block's patterns match the memo, as hledger's match the whole record, unless they name a field.
+If parseHledger fails to read the rules, it returns an error.
+*/
+func parseHledger(rdr io.Reader) (migration, error) {
+	var (
+		mgn             migration
+		patterns        [][2]string // of the if block being read, by field name
+		inIf, inAssigns bool        // reading the matchers, or the assignments, of an if block
+	)
+
+	scnr := bufio.NewScanner(rdr)
+	for scnr.Scan() {
+		text := scnr.Text()
+		line := strings.TrimSpace(text)
+		indented := line != "" && (text[0] == ' ' || text[0] == '\t')
+
+		switch {
+		case line == "":
+			inIf, inAssigns = false, false
+		case strings.ContainsAny(line[:1], "#;*"):
+			// a comment
+		case line == "if" || strings.HasPrefix(line, "if "):
+			inIf, inAssigns, patterns = true, false, nil
+			if pat := strings.TrimSpace(strings.TrimPrefix(line, "if")); pat != "" {
+				patterns = append(patterns, hledgerPattern(pat))
+			}
+		case inIf && indented:
+			inAssigns = true
+			mgn.addAssign(patterns, line)
+		case inIf && !inAssigns && strings.HasPrefix(line, "&"):
+			// An and matcher has no equivalent, so neither has its block.
+			mgn.unsupported, patterns = append(mgn.unsupported, line), nil
+		case inIf && !inAssigns:
+			patterns = append(patterns, hledgerPattern(line))
+		default:
+			inIf, inAssigns = false, false
+			mgn.addDirective(line)
+		}
+	}
+
+	err := scnr.Err()
+	if err != nil {
+		return mgn, fmt.Errorf("scanner.Scan: %w", err)
+	}
+
+	return mgn, nil
+}
+
+/*
+HledgerPattern returns the field name and case-insensitive pattern of a matcher in an hledger if block,
+e.g. "%description ^ATM" is "memo" and "(?i)^ATM".
+A matcher that names no field, or one with no equivalent, matches the memo.
+*/
+func hledgerPattern(line string) [2]string {
+	fld := "memo"
+
+	if name, pat, ok := strings.Cut(line, " "); ok && strings.HasPrefix(name, "%") {
+		if assign, ok := hledgerAssigns[strings.TrimPrefix(name, "%")]; ok {
+			fld = assign
+		}
+
+		line = strings.TrimSpace(pat)
+	}
+
+	return [2]string{fld, "(?i)" + line}
+}
+
+// AddAssign adds a rule for each pattern of an if block, assigning the field in the line if it has an equivalent.
+func (mgn *migration) addAssign(patterns [][2]string, line string) {
+	name, val, _ := strings.Cut(line, " ")
+
+	fld, ok := hledgerAssigns[name]
+	if !ok || len(patterns) == 0 {
+		mgn.unsupported = append(mgn.unsupported, line)
+
+		return
+	}
+
+	for _, pat := range patterns {
+		mgn.rules = append(mgn.rules, []string{pat[0], pat[1], fld + "=" + strings.TrimSpace(val)})
+	}
+}
+
+// AddDirective adds the flags equivalent to a top-level directive, or keeps it if it has none.
+func (mgn *migration) addDirective(line string) {
+	name, val, _ := strings.Cut(line, " ")
+	val = strings.TrimSpace(val)
+
+	switch name {
+	case "skip":
+		nSkip, err := strconv.Atoi(val)
+		if val == "" || err != nil {
+			nSkip = 1
+		}
+
+		mgn.flags = append(mgn.flags, fmt.Sprintf("-lines=%v-", nSkip+1))
+	case "fields":
+		names := strings.Split(val, ",")
+		mgn.flags = append(mgn.flags, fmt.Sprintf("-nfields=%v", len(names)))
+
+		for inx, fld := range names {
+			if flg, ok := hledgerFields[strings.TrimSpace(fld)]; ok {
+				mgn.flags = append(mgn.flags, fmt.Sprintf("-%v=%v", flg, inx+1))
+			}
+		}
+	case "date-format":
+		mgn.flags = append(mgn.flags, "-dateformat="+hledgerDateFormat.Replace(val))
+	case "separator":
+		if len([]rune(val)) == 1 {
+			mgn.flags = append(mgn.flags, "-delimiter="+val)
+		} else {
+			mgn.flags = append(mgn.flags, "-detectdialect") // e.g. TAB, which a profile line cannot end with
+		}
+	case "decimal-mark":
+		mgn.flags = append(mgn.flags, "-decimal="+val)
+	case "currency":
+		mgn.flags = append(mgn.flags, "-currency="+val)
+	case "account1":
+		mgn.flags = append(mgn.flags, "-thisacct="+val)
+	case "account2":
+		mgn.flags = append(mgn.flags, "-otheracct="+val)
+	case "newest-first", "intra-day-reversed":
+		// cas2trn keeps the order of the statement, see sort
+	default:
+		mgn.unsupported = append(mgn.unsupported, line)
+	}
+}
@@ -0,0 +1,313 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// InferCmd is the subcommand that suggests a configuration for a sample statement.
+const inferCmd = "infer"
+
+var (
+	errInferArgs   = errors.New("infer needs the name of a sample statement file")
+	errInferDates  = errors.New("no field of the sample statement looks like dates")
+	errInferMemos  = errors.New("no field of the sample statement looks like memos")
+	errInferAmount = errors.New("no field of the sample statement looks like amounts")
+)
+
+/*
+InferConfig reads the first maxSampled records of the statement file named in the arguments,
+writes a configuration suggested for its format to the writer, in TOML, and returns nil.
+The configuration can be read by the config flag, once this account is set.
+Fields are recognised by their values: dates by a date format that parses most of them,
+amounts by being numbers with both signs, credits and debits by being numbers that are never both set,
+and memos by being the text with the most distinct values.
+A header row, whose values are neither dates nor numbers, names the credit and debit fields if it can.
+If inferConfig fails to read the statement, or to recognise the date, memo or amount fields, it returns an error.
+*/
+func inferConfig(writer io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errInferArgs
+	}
+
+	smp, err := readSample(args[0], maxSampled)
+	if err != nil {
+		return err
+	}
+
+	inf, err := smp.infer()
+	if err != nil {
+		return err
+	}
+
+	inf.write(writer, args[0])
+
+	return nil
+}
+
+/*
+A sample is the first records of a statement, see readSample.
+Its records all have the most common number of fields.
+*/
+type sample struct {
+	dlc     dialect
+	header  []string // nil if the statement has no header row
+	recs    [][]string
+	nFields int
+}
+
+/*
+ReadSample returns the first records, up to the maximum number, of the named statement file, and nil.
+Its dialect is detected, and its first record is the header row if none of its values are dates or numbers.
+If readSample fails to read the statement, it returns an error.
+*/
+func readSample(name string, maxN int) (sample, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return sample{}, err
+	}
+	defer file.Close()
+
+	var smp sample
+
+	reader, dlc := dialect{}.newReader(&lineEndReader{rdr: bufio.NewReader(skipBOM(file))}, true)
+	reader.FieldsPerRecord, smp.dlc = -1, dlc
+
+	for len(smp.recs) < maxN {
+		flds, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return sample{}, fmt.Errorf("reader.Read(): %w", err)
+		}
+
+		smp.recs = append(smp.recs, flds)
+	}
+
+	nRecs := make(map[int]int) // by number of fields
+	for _, rec := range smp.recs {
+		nRecs[len(rec)]++
+	}
+
+	if len(nRecs) == 0 {
+		return smp, nil
+	}
+
+	smp.nFields = slices.MaxFunc(slices.Collect(maps.Keys(nRecs)), func(a, b int) int { return nRecs[a] - nRecs[b] })
+	smp.recs = slices.DeleteFunc(smp.recs, func(rec []string) bool { return len(rec) != smp.nFields })
+
+	if 1 < len(smp.recs) && !slices.ContainsFunc(smp.recs[0], func(val string) bool {
+		_, isDate := guessDateFormat([]string{val})
+
+		return isDate || smp.isNumber(val)
+	}) {
+		smp.header, smp.recs = smp.recs[0], smp.recs[1:]
+	}
+
+	return smp, nil
+}
+
+// IsNumber returns true if the value parses as an amount in the dialect of this sample.
+func (smp *sample) isNumber(val string) bool {
+	return isNumber(smp.dlc.number(strings.TrimSpace(val)))
+}
+
+// Column returns the values, without spaces around, of the field with the index, from one, in this sample.
+func (smp *sample) column(inx int) []string {
+	vals := make([]string, len(smp.recs))
+	for jnx, rec := range smp.recs {
+		vals[jnx] = strings.TrimSpace(rec[inx-1])
+	}
+
+	return vals
+}
+
+/*
+Infer returns the configuration inferred from this sample, see inferConfig, and nil.
+If it fails to recognise the date, memo or amount fields, infer returns an error.
+*/
+func (smp *sample) infer() (inference, error) {
+	inf := inference{sample: smp}
+
+	inf.dateI, inf.dateFormat = smp.inferDate()
+	if inf.dateI == 0 {
+		return inf, errInferDates
+	}
+
+	inf.inferAmounts()
+	if inf.amountI == 0 && inf.creditI == 0 {
+		return inf, errInferAmount
+	}
+
+	inf.memoI = smp.inferMemo(inf.dateI, inf.amountI, inf.creditI, inf.debitI)
+	if inf.memoI == 0 {
+		return inf, errInferMemos
+	}
+
+	return inf, nil
+}
+
+// An inference is the configuration inferred from a sample statement, see inferConfig.
+type inference struct {
+	*sample
+	dateI, amountI, creditI, debitI, memoI int
+	dateFormat                             string
+}
+
+// InferDate returns the index of the first field that looks like dates, and their date format.
+func (smp *sample) inferDate() (int, string) {
+	for inx := 1; inx <= smp.nFields; inx++ {
+		if format, ok := guessDateFormat(smp.column(inx)); ok {
+			return inx, format
+		}
+	}
+
+	return 0, ""
+}
+
+/*
+InferAmounts sets the index of the amount field, the first whose values are mostly numbers with both signs,
+or if there is none, those of the first credit and debit fields, a pair whose values are numbers or empty
+and never both set.
+The header names which of the pair is credit, else the debit field is assumed to be first as is most common.
+*/
+func (inf *inference) inferAmounts() {
+	var optional []int // fields whose values are numbers, or empty
+
+	isNum := inf.isNumber
+
+	for inx := 1; inx <= inf.nFields; inx++ {
+		vals := inf.column(inx)
+		if !slices.ContainsFunc(vals, func(val string) bool { return val != "" && !isNum(val) }) &&
+			slices.ContainsFunc(vals, isNum) {
+			optional = append(optional, inx)
+		}
+
+		if isMostly(vals, isNum) &&
+			slices.ContainsFunc(vals, func(val string) bool { return strings.HasPrefix(val, "-") }) &&
+			slices.ContainsFunc(vals, func(val string) bool { return isNum(val) && !strings.HasPrefix(val, "-") }) {
+			inf.amountI = inx
+
+			return
+		}
+	}
+
+	// areExclusive returns true if the values of the fields are never both set.
+	areExclusive := func(first, second int) bool {
+		firsts, seconds := inf.column(first), inf.column(second)
+		for inx := range firsts {
+			if firsts[inx] != "" && seconds[inx] != "" {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	for jnx, first := range optional {
+		for _, second := range optional[jnx+1:] {
+			if !areExclusive(first, second) {
+				continue
+			}
+
+			inf.debitI, inf.creditI = first, second
+			if inf.header != nil && strings.Contains(strings.ToLower(inf.header[first-1]), "credit") {
+				inf.debitI, inf.creditI = inf.creditI, inf.debitI
+			}
+
+			return
+		}
+	}
+}
+
+// InferMemo returns the index of the field, other than those given, that is text with the most distinct values.
+func (smp *sample) inferMemo(not ...int) int {
+	memoI, bestN := 0, 0
+
+	for inx := 1; inx <= smp.nFields; inx++ {
+		vals := smp.column(inx)
+		if slices.Contains(not, inx) || isMostly(vals, smp.isNumber) {
+			continue
+		}
+
+		if _, ok := guessDateFormat(vals); ok {
+			continue
+		}
+
+		distinct := make(map[string]bool)
+		for _, val := range vals {
+			distinct[val] = true
+		}
+
+		if bestN < len(distinct) {
+			memoI, bestN = inx, len(distinct)
+		}
+	}
+
+	return memoI
+}
+
+// Write writes this inference, as a configuration in TOML for the named statement file, to the writer.
+func (inf *inference) write(writer io.Writer, name string) {
+	fmt.Fprintf(writer, "# configuration of %v inferred from %v, set thisacct before use\n", pgmName, name)
+	fmt.Fprintf(writer, "nfields = %v\n", inf.nFields)
+	fmt.Fprintf(writer, "dateformat = %v\n", tomlString(inf.dateFormat))
+
+	for _, fld := range []struct {
+		name string
+		inx  int
+	}{{"datei", inf.dateI}, {"memoi", inf.memoI}, {"amounti", inf.amountI}, {"debiti", inf.debitI},
+		{"crediti", inf.creditI}} {
+		if fld.inx == 0 {
+			continue
+		}
+
+		fmt.Fprintf(writer, "%v = %v", fld.name, fld.inx)
+
+		if inf.header != nil {
+			fmt.Fprintf(writer, " # %v", inf.header[fld.inx-1])
+		}
+
+		fmt.Fprintln(writer)
+	}
+
+	if inf.header != nil {
+		fmt.Fprintln(writer, `lines = "2-"`)
+	}
+
+	for _, chr := range []struct {
+		name   string
+		val, d rune
+	}{{"delimiter", inf.dlc.delimiter, ','}, {"quote", inf.dlc.quote, '"'}, {"decimal", inf.dlc.decimal, '.'}} {
+		if chr.val != chr.d {
+			fmt.Fprintf(writer, "%v = %v\n", chr.name, tomlString(string(chr.val)))
+		}
+	}
+
+	fmt.Fprintln(writer, `# thisacct = "Assets:Current:Bank"`)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+/*
+KeychainPrefix starts the value of a secret option, such as a password or access token,
+that is the name of a secret in the OS keychain instead of the secret itself, e.g. "keychain:imap".
+*/
+const keychainPrefix = "keychain:"
+
+var errKeychain = errors.New("no keychain command found, install secret-tool on Linux; " +
+	"macOS has security and Windows is not supported")
+
+/*
+KeychainArgs returns the name and arguments of the command that writes the secret with the name,
+stored for service cas2trn in the OS keychain, to standard output.
+If there is no such command on this platform, the name is empty string.
+*/
+func keychainArgs(name string) (string, []string) {
+	switch runtime.GOOS {
+	case "darwin":
+		return "security", []string{"find-generic-password", "-s", pgmName, "-a", name, "-w"}
+	case "windows":
+		return "", nil
+	default:
+		return "secret-tool", []string{"lookup", "service", pgmName, "account", name}
+	}
+}
+
+/*
+ResolveSecret returns the secret and nil.
+If the secret starts with keychainPrefix, it is looked up in the OS keychain, see keychainArgs,
+so profiles and scripts need not contain secrets in plain text.
+If resolveSecret fails to look up the secret, it returns an error.
+*/
+func resolveSecret(secret string) (string, error) {
+	name, ok := strings.CutPrefix(secret, keychainPrefix)
+	if !ok {
+		return secret, nil
+	}
+
+	cmdName, args := keychainArgs(name)
+	if cmdName == "" {
+		return "", errKeychain
+	}
+
+	path, err := exec.LookPath(cmdName)
+	if err != nil {
+		return "", errKeychain
+	}
+
+	out, err := exec.Command(path, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("keychain secret %v: exec.Cmd.Output: %w", name, err)
+	}
+
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
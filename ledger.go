@@ -0,0 +1,113 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	outputLedger = "ledger"   // output format, see transact.ledgerEntry
+	priceName    = "price"    // of the extra field for the price of a unit of a security
+	quantityName = "quantity" // of the extra field for the number of units of a security traded
+	symbolName   = "symbol"   // of the extra field for the ticker symbol of a security
+	ledgerIndent = "    "     // of postings and comments in a ledger journal entry
+)
+
+/*
+TradeAmount returns the amount of a trade of a security, the quantity times the price rounded to cents, and nil.
+Buying, a positive quantity, is a debit and selling is a credit.
+If it fails to parse the quantity or price, tradeAmount returns an error.
+*/
+func tradeAmount(fields []string, cfg config) (float64, error) {
+	qty, err := parseMoney(cfg.dialect.number(strings.TrimSpace(fields[cfg.quantityI])), cfg.laxNumbers)
+	if err != nil {
+		return zero, err
+	}
+
+	price, err := parseMoney(cfg.dialect.number(extract(cfg.amountPattern, fields[cfg.priceI])), cfg.laxNumbers)
+	if err != nil {
+		return zero, err
+	}
+
+	const (
+		minus1  = -1.00
+		perCent = 100
+	)
+
+	return math.Round(qty*math.Abs(price)*perCent) / perCent * minus1, nil
+}
+
+/*
+LedgerEntry returns the transaction as an entry in a ledger journal, readable by ledger and hledger.
+The entry has a posting of the amount to this account, and a balancing posting to the other account.
+If the transaction trades a security, i.e. it has a symbol and quantity,
+the other account's posting is of the quantity of the security at the total cost of the amount,
+so any fees folded into the amount are part of the cost.
+Other extra fields that are not empty string are written as comment tags.
+*/
+func (trn *transact) ledgerEntry() string {
+	amt := strconv.FormatFloat(trn.amount, 'f', -1, 64)
+	lines := []string{trn.date + " " + trn.memo, ledgerIndent + trn.thisAcct + "  " + ledgerAmount(amt, trn.currency)}
+
+	other := trn.otherAcct
+	if other == "" {
+		other = unknownAcct
+	}
+
+	sym, _ := trn.value(symbolName)
+	qty, _ := trn.value(quantityName)
+
+	if sym != "" && qty != "" {
+		cost := strconv.FormatFloat(math.Abs(trn.amount), 'f', -1, 64)
+		lines = append(lines,
+			ledgerIndent+other+"  "+qty+" "+ledgerCommodity(sym)+" @@ "+ledgerAmount(cost, trn.currency))
+	} else {
+		lines = append(lines, ledgerIndent+other)
+	}
+
+	for inx, name := range trn.extraNames {
+		if trn.extras[inx] != "" && name != symbolName && name != quantityName {
+			lines = append(lines, ledgerIndent+"; "+name+": "+trn.extras[inx])
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// LedgerAmount returns the amount followed by its commodity, if any, in a ledger journal.
+func ledgerAmount(amt, cur string) string {
+	if cur == "" {
+		return amt
+	}
+
+	return amt + " " + ledgerCommodity(cur)
+}
+
+// LedgerCommodity returns the commodity, quoted if it is not only letters, as a ledger journal needs.
+func ledgerCommodity(cur string) string {
+	if strings.IndexFunc(cur, func(r rune) bool { return !unicode.IsLetter(r) }) == -1 {
+		return cur
+	}
+
+	return strconv.Quote(cur)
+}
@@ -0,0 +1,201 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const lockExt = ".lock" // of the lock file of a file written by cas2trn
+
+var (
+	errLocked    = errors.New("another import is running")
+	errOverwrite = errors.New("output file is not empty, set force to overwrite it")
+)
+
+/*
+CheckOverwrite returns nil if the output file can be written: it is empty, does not exist, or force is set.
+So hand-curated transaction archives are not overwritten by accident.
+Objects in S3 are not checked.
+*/
+func (cfg *config) checkOverwrite() error {
+	if cfg.outFile == "" || cfg.force || isCloudPath(cfg.outFile) {
+		return nil
+	}
+
+	info, err := os.Stat(cfg.outFile)
+	if err == nil && info.Size() != 0 {
+		return fmt.Errorf("%w: %v", errOverwrite, cfg.outFile)
+	}
+
+	return nil
+}
+
+/*
+LockNames returns the names of the local files this configuration writes that concurrent runs could corrupt:
+the state file, the output file and a SQLite database.
+*/
+func (cfg *config) lockNames() []string {
+	var names []string
+
+	if cfg.stateFile != "" {
+		names = append(names, cfg.stateFile)
+	}
+
+	if cfg.outFile != "" && !isCloudPath(cfg.outFile) {
+		names = append(names, cfg.outFile)
+	}
+
+	if name, ok := sqliteFile(cfg.dbDriver, cfg.dbDSN); ok {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+/*
+RunLocked checks the output file can be written, see checkOverwrite, locks the files this configuration writes,
+see lockFiles, backs them up if configured, see backupFiles, then runs the function and returns its error.
+So every run that translates, whether started by hand, by cron or by a schedule, is guarded the same way.
+If runLocked fails to check, lock or back up the files, it returns an error without running the function.
+*/
+func (cfg *config) runLocked(run func() error) error {
+	err := cfg.checkOverwrite()
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockFiles(cfg.lockNames())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if cfg.backups != 0 {
+		err = cfg.backupFiles(time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	return run()
+}
+
+/*
+LockFiles takes an advisory lock on each named file, by creating a lock file next to it that holds the process ID,
+and returns a function that releases the locks and nil.
+So two runs, such as ones started by cron, cannot interleave writing the same files.
+A lock file whose process has ended, such as by being killed, is stale, so it is taken over.
+If a file is already locked, lockFiles releases the locks it took and returns an error naming the lock file.
+*/
+func lockFiles(names []string) (func(), error) {
+	var locks []string
+
+	unlock := func() {
+		for _, lock := range locks {
+			os.Remove(lock)
+		}
+	}
+
+	const (
+		flags = os.O_CREATE | os.O_EXCL | os.O_WRONLY
+		perm  = 0o600
+	)
+
+	for _, name := range names {
+		lock := name + lockExt
+
+		file, err := os.OpenFile(lock, flags, perm)
+		if errors.Is(err, os.ErrExist) && isStale(lock) {
+			os.Remove(lock)
+
+			file, err = os.OpenFile(lock, flags, perm)
+		}
+
+		if errors.Is(err, os.ErrExist) {
+			unlock()
+
+			pid, _ := os.ReadFile(lock)
+
+			return nil, fmt.Errorf("%w: %v is held by process %v, remove it if that process has ended",
+				errLocked, lock, strings.TrimSpace(string(pid)))
+		} else if err != nil {
+			unlock()
+
+			return nil, fmt.Errorf("os.OpenFile: %w", err)
+		}
+
+		locks = append(locks, lock)
+
+		_, err = file.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+		err = errors.Join(err, file.Close())
+		if err != nil {
+			unlock()
+
+			return nil, fmt.Errorf("os.File.WriteString: %w", err)
+		}
+	}
+
+	return unlock, nil
+}
+
+/*
+IsStale returns true if the lock file holds the ID of a process that is not running.
+If the lock file cannot be read or does not hold a process ID, such as one being written, it is not stale.
+*/
+func isStale(lock string) bool {
+	data, err := os.ReadFile(lock)
+	if err != nil {
+		return false
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+
+	return !isRunning(pid)
+}
+
+/*
+IsRunning returns true if the process with the ID is running.
+On Windows, finding a process opens it, which fails if it has ended, elsewhere signal zero checks it exists.
+*/
+func isRunning(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer proc.Release()
+
+	if runtime.GOOS == "windows" {
+		return true
+	}
+
+	err = proc.Signal(syscall.Signal(0))
+
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -26,7 +26,7 @@ For more information see:
 package main
 
 import (
-	"encoding/csv"
+	"cmp"
 	"errors"
 	"flag"
 	"fmt"
@@ -34,133 +34,859 @@ import (
 	"log"
 	"math"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 )
 
-const pgmName = "cas2trn" // see also pgmTitle
+const (
+	pgmName         = "cas2trn" // see also pgmTitle
+	exitNoTransacts = 3         // exit status when a statement has no transactions, see errNoTransacts
+)
+
+// What to write instead of translating, see config.info.
+const (
+	infoConfig  = "config"
+	infoHelp    = "help"
+	infoPresets = "presets"
+	infoVersion = "version"
+)
+
+var errInfo = errors.New("help, version, presets or configuration requested instead of translating")
+
+// ExportJS exports cas2trn to JavaScript, and is only set when cas2trn is built for WebAssembly.
+var exportJS func()
 
 // Main runs cas2trn.
 func main() {
 	log.SetPrefix(pgmName + ": ")
 	log.SetFlags(0)
 
-	cfg, err := parseConfig()
-	if err != nil {
-		log.Fatal(err)
+	if exportJS != nil {
+		exportJS()
+
+		return
 	}
 
-	var rdr *csv.Reader
+	cmd, args := "", os.Args[1:]
+	if 0 < len(args) && slices.Contains([]string{demoCmd, fetchCmd, importCmd, inferCmd, presetsCmd, reconcileCmd,
+		rulesCmd, serveCmd, setupCmd, undoCmd, updateCmd, verifyCmd}, args[0]) {
+		cmd, args = args[0], args[1:]
+	}
 
-	if 0 < flag.NArg() {
-		for _, stmt := range flag.Args() {
-			var file *os.File
+	if cmd == serveCmd {
+		err := serve(args)
+		if err != nil {
+			log.Fatal(err)
+		}
 
-			file, err = os.Open(stmt)
-			if err != nil {
-				log.Fatal(err)
-			}
+		return
+	}
 
-			rdr = csv.NewReader(file)
-			err = translateStatement(rdr, cfg)
+	if cmd == presetsCmd {
+		err := runPresets(os.Stdout, args)
+		if err != nil {
+			log.Fatal(err)
 		}
-	} else {
-		rdr = csv.NewReader(os.Stdin)
-		err = translateStatement(rdr, cfg)
+
+		return
 	}
 
-	if err != nil {
+	if cmd == updateCmd {
+		exe, err := os.Executable()
+		if err == nil {
+			err = selfUpdate(os.Stdout, releasesURL, exe)
+		}
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if cmd == demoCmd {
+		err := runDemo(os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if cmd == inferCmd {
+		err := inferConfig(os.Stdout, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if cmd == setupCmd {
+		err := runSetup(os.Stdin, os.Stdout, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if cmd == verifyCmd {
+		err := verifyFile(os.Stdout, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if cmd == importCmd {
+		err := importHledger(os.Stdout, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if cmd == rulesCmd {
+		err := runRules(os.Stdout, args)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	flag.Usage = usage
+
+	cfg, err := parseConfig(flag.CommandLine, args)
+	if errors.Is(err, errInfo) {
+		err = writeInfo(os.Stdout, flag.CommandLine, cfg.info)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	switch {
+	case cfg.explain:
+		for _, line := range cfg.explanation() {
+			fmt.Println(line)
+		}
+	case cfg.whatsNew:
+		err = whatsNew(os.Stdout, cfg, flag.Args())
+	case cmd == fetchCmd:
+		err = runFetch(cfg)
+	case cmd == reconcileCmd:
+		err = reconcileFiles(cfg, flag.Args())
+	case cmd == undoCmd:
+		err = undoRun(os.Stderr, cfg)
+	default:
+		err = cfg.runLocked(func() error {
+			if cfg.profileDir != "" {
+				return translateMatched(cfg, flag.Args())
+			}
+
+			tlr := newTranslator(cfg)
+
+			return errors.Join(tlr.translateFiles(flag.Args()), tlr.finish())
+		})
+	}
+
+	if errors.Is(err, errNoTransacts) {
+		log.Print(err)
+		os.Exit(exitNoTransacts)
+	} else if err != nil {
 		log.Fatal(err)
 	}
 }
 
+/*
+WriteInfo writes the information requested instead of translating, see config.info, to the writer and returns nil.
+Help is written by the flag set's usage function, and the configuration is that of its flags.
+If writeInfo fails to read the presets, it returns an error.
+*/
+func writeInfo(writer io.Writer, fset *flag.FlagSet, info string) error {
+	switch info {
+	case infoConfig:
+		writeConfig(writer, fset)
+	case infoHelp:
+		fset.Usage()
+	case infoPresets:
+		return writePresets(writer, userPresetDir())
+	case infoVersion:
+		writeVersion(writer)
+	}
+
+	return nil
+}
+
 /*
 Parseconfig returns the configuration for cas2trn and nil.
-The configuration is parsed from flags.
+The configuration is parsed from the flags in the arguments, which are defined in the flag set.
+If the flags request help, the version, the presets or the configuration, parseConfig returns errInfo
+with what to write in info, so its caller rather than parseConfig decides to exit, see writeInfo.
 If the configuration is not valid, parseConfig returns the first error.
 */
-func parseConfig() (config, error) {
-	flag.Usage = usage
+func parseConfig(fset *flag.FlagSet, args []string) (config, error) {
 
-	var help bool
+	var help, printConfig, version bool
 
-	flag.BoolVar(&help, "help", false, "write this help text then exit")
+	fset.BoolVar(&help, "help", false, "write this help text then exit")
+	fset.BoolVar(&version, "version", false, "write the version of cas2trn, the Go version, "+
+		"and the versions of the standard format and presets, then exit")
 
 	var cfg config
 
 	var nFlds uint
 
-	flag.UintVar(&nFlds, "nfields", 0, "number of fields in input CSV record, mandatory")
+	fset.UintVar(&nFlds, "nfields", 0, "number of fields in input CSV record, mandatory")
 
 	var vals [nIndexes]uint
 
-	flag.UintVar(&vals[0], "amounti", 0, "amount field index, "+
+	fset.UintVar(&vals[0], "amounti", 0, "amount field index, "+
 		"optional but if zero then crediti and debiti must be non-zero")
-	flag.UintVar(&vals[1], "crediti", 0, "credit field index, optional see amounti")
-	flag.UintVar(&vals[2], "datei", 0, "date field index, mandatory")
-	flag.UintVar(&vals[3], "debiti", 0, "debit field index, optional see amounti")
-	flag.UintVar(&vals[4], "memoi", 0, "memo or description field index, mandatory")
-	flag.UintVar(&vals[5], "otheraccti", 0, "other account number or name field index, optional")
-	flag.UintVar(&vals[6], "thisaccti", 0, "this account number or name field index, optional see thisacct")
+	fset.UintVar(&vals[1], "crediti", 0, "credit field index, optional see amounti")
+	fset.UintVar(&vals[2], "datei", 0, "date field index, mandatory")
+	fset.UintVar(&vals[3], "debiti", 0, "debit field index, optional see amounti")
+	fset.UintVar(&vals[4], "memoi", 0, "memo or description field index, mandatory")
+	fset.UintVar(&vals[5], "otheraccti", 0, "other account number or name field index, optional")
+	fset.UintVar(&vals[6], "thisaccti", 0, "this account number or name field index, optional see thisacct")
+	fset.UintVar(&vals[7], "chequei", 0, "cheque number field index, optional and adds field cheque to the output")
+	fset.UintVar(&vals[8], "dci", 0, "debit credit indicator field index, "+
+		"optional but if non-zero then amounti must be non-zero and dcmarks gives the sign of amounts")
+	fset.UintVar(&vals[9], "currencyi", 0, "currency field index, optional and if its field is not empty string "+
+		"it overrides currency")
+	fset.UintVar(&vals[10], "balancei", 0, "running balance field index, optional and warns about amounts that "+
+		"do not add up to it, such as when a row is missing")
+	fset.UintVar(&vals[11], "statusi", 0, "pending or posted status field index, optional see pendingpolicy")
+	fset.StringVar(&cfg.pendingMark, "pendingmark", "pending", "status of pending transactions, "+
+		"compared ignoring case, see statusi")
+	fset.StringVar(&cfg.pendingPolicy, "pendingpolicy", pendingSkip, "policy for pending transactions, "+
+		"see statusi: skip does not output them, as they are output again once posted, "+
+		"or include outputs them with field status")
+	fset.UintVar(&vals[12], "origamounti", 0, "original amount field index, before conversion to the currency "+
+		"of this account, optional and adds fields origamount and origcurrency to the output")
+	fset.UintVar(&vals[13], "origcurrencyi", 0, "original currency field index, optional see origamounti")
+	fset.UintVar(&vals[14], "feei", 0, "fee field index, optional see feepolicy")
+	fset.UintVar(&vals[15], "quantityi", 0, "quantity of a security traded field index, optional and "+
+		"if amounti, crediti and debiti are zero the amount is the quantity times the price")
+	fset.UintVar(&vals[16], "symboli", 0, "ticker symbol of a security field index, optional see quantityi")
+	fset.UintVar(&vals[17], "pricei", 0, "price of a unit of a security field index, optional see quantityi")
+	fset.UintVar(&vals[18], "principali", 0, "principal part of a loan payment field index, optional and "+
+		"splits the payment into a transaction for each part with other account principalacct, interestacct "+
+		"or escrowacct, and one for any rest")
+	fset.UintVar(&vals[19], "interesti", 0, "interest part of a loan payment field index, optional see principali")
+	fset.UintVar(&vals[20], "escrowi", 0, "escrow part of a loan payment field index, optional see principali")
+
+	cols := make([]string, len(columnFields))
+
+	for inx, field := range columnFields {
+		fset.StringVar(&cols[inx], field+"col", "", field+" column name in the header row, optional and "+
+			"overrides "+field+"i if the statement has a header row e.g. -"+field+"col=\""+columnExamples[field]+"\"")
+	}
+
+	fset.StringVar(&cfg.principalAcct, "principalacct", "", "other account of the principal part of "+
+		"loan payments, optional see principali e.g. \"Liabilities:Mortgage\"")
+	fset.StringVar(&cfg.interestAcct, "interestacct", "", "other account of the interest part of "+
+		"loan payments, optional see principali e.g. \"Expenses:Interest\"")
+	fset.StringVar(&cfg.escrowAcct, "escrowacct", "", "other account of the escrow part of "+
+		"loan payments, optional see principali e.g. \"Assets:Escrow\"")
+	fset.StringVar(&cfg.feePolicy, "feepolicy", feeAdd, "policy for fees, see feei: add adds them to the amount, "+
+		"or split emits a fee transaction with other account feeacct")
+	fset.StringVar(&cfg.feeAcct, "feeacct", "", "other account of fee transactions, "+
+		"optional see feepolicy e.g. \"Expenses:Fees\"")
+	var earnings, deductions string
+
+	fset.StringVar(&earnings, "earnings", "", "earnings field indexes of a payslip and their other accounts, "+
+		"optional and splits each record into a transaction for each earning and deduction, "+
+		"with any amount field as the net pay e.g. \"4=Income:Salary,5=Income:Overtime\"")
+	fset.StringVar(&deductions, "deductions", "", "deductions field indexes of a payslip and their other accounts, "+
+		"optional see earnings e.g. \"6=Expenses:Tax,7=Assets:Retirement\"")
+
+	fset.StringVar(&cfg.currency, "currency", "", "unit for amounts on this statement, optional e.g. \"NZD\"")
+	fset.BoolVar(&cfg.minorUnits, "minorunits", false, "amounts are whole numbers of minor units of their "+
+		"currency, optional e.g. \"16250\" is 162.50 NZD or 16250 JPY")
+	fset.Float64Var(&cfg.maxAmount, "maxamount", 1e12, "largest absolute amount accepted, "+
+		"optional and zero means unbounded e.g. \"1e6\"")
+	fset.BoolVar(&cfg.laxNumbers, "laxnumbers", false, "accept amounts in scientific notation and other "+
+		"numeric forms, optional and by default amounts are only digits, a sign and a decimal separator")
+	var acctCurrencies string
+
+	fset.StringVar(&acctCurrencies, "acctcurrency", "", "units for amounts by this account, optional and "+
+		"overrides currency e.g. \"Assets:Current:PCUS1=NZD,Liabilities:Amex=USD\"")
+	var dcMarks string
+
+	fset.StringVar(&dcMarks, "dcmarks", "D,C", "debit and credit marks in the debit credit indicator field, "+
+		"see dci e.g. \"S,H\"")
+	var delim, quote, decimal, recordSep string
+
+	fset.BoolVar(&cfg.detectDialect, "detectdialect", false, "detect the delimiter, quote and decimal separator "+
+		"of each statement from its start, optional and overridden by delimiter, quote and decimal")
+	fset.StringVar(&delim, "delimiter", "", "delimiter between fields, optional and defaults to \",\" e.g. \";\"")
+	fset.StringVar(&quote, "quote", "", "quote around fields, optional and defaults to '\"' e.g. \"'\"")
+	fset.StringVar(&decimal, "decimal", "", "decimal separator in amounts, optional and defaults to \".\" "+
+		"e.g. \",\" where thousands are separated by \".\"")
+	fset.StringVar(&recordSep, "recordseparator", "", "separator between records, other than a line break, "+
+		"optional e.g. \"~\" or the ASCII record separator")
+	fset.StringVar(&cfg.dateFormat, "dateformat", "", "date format, mandatory and Go style e.g. \"02/01/2006\"")
+	var amtPattern, datePattern string
+
+	fset.StringVar(&amtPattern, "amountpattern", "", "regular expression that extracts amounts from the amount, "+
+		"credit and debit fields with its first group, optional e.g. \"^NZD (.+)$\"")
+	fset.StringVar(&datePattern, "datepattern", "", "regular expression that extracts dates from the date field "+
+		"with its first group, optional e.g. \"^(\\S+) \\d\\d:\\d\\d$\"")
+	var linePattern string
+
+	fset.StringVar(&linePattern, "linepattern", "", "regular expression that matches transaction lines "+
+		"in text and PDF statements, with a group for each field, optional and groups named for fields "+
+		"set their indexes e.g. "+
+		"\"^(?P<date>\\S+)  (?P<memo>.+?)  (?P<amount>\\S+)$\"")
+	fset.StringVar(&cfg.docRef, "docref", "", "template of a document reference, optional and adds field document "+
+		"to the output e.g. \"receipts/{date}_{amount}_{reference}.pdf\"")
+	fset.StringVar(&cfg.memoPrefix, "memoprefix", "", "template of a label added before memos, optional and "+
+		"besides fields it can refer to {file}, {period} and {type} e.g. \"[import {period}] \"")
+	fset.StringVar(&cfg.memoSuffix, "memosuffix", "", "template of a label added after memos, "+
+		"optional see memoprefix e.g. \" ({file})\"")
+	fset.StringVar(&cfg.otherAcct, "otheracct", "", "default other account number or name, "+
+		"optional and used when the other account is empty string e.g. \"Expenses:Unknown\"")
+	fset.StringVar(&cfg.thisAcct, "thisacct", "", "this account number or name, "+
+		"optional but if empty string then thisaccti must be non-zero or thisacctpattern set")
+	var thisAcctPattern string
+
+	fset.StringVar(&thisAcctPattern, "thisacctpattern", "", "regular expression that matches this account "+
+		"in the statement file name by its group acct, optional and used when the this account field is empty "+
+		"e.g. \"(?P<acct>[A-Z]{2}-\\d{4}-\\d{7}-\\d{2})\"")
+
+	var chartFile string
+
+	fset.StringVar(&chartFile, "chartfile", "", "file of account names, one per line, "+
+		"optional and accounts not in it are warned about")
+	fset.BoolVar(&cfg.hierarchy, "hierarchy", false, "warn about accounts not in ledger hierarchy format, "+
+		"optional e.g. \"Assets:Current:Cheque\"")
+
+	fset.StringVar(&cfg.unknownPolicy, "unknownacct", policyWarn, "policy for other accounts not in the chart "+
+		"of accounts, see chartfile: error skips the transaction, map sets the account to otheracct "+
+		"or \""+unknownAcct+"\", or warn")
+	fset.StringVar(&cfg.bothPolicy, "bothpolicy", policyError, "policy for records with both credit and debit: "+
+		"error skips the record, credit or debit takes just that one, net takes the credit less the debit, "+
+		"or split emits debit and credit transactions")
+
+	var mapFile string
+
+	fset.StringVar(&mapFile, "mapfile", "", "CSV file of field value mappings, optional "+
+		"e.g. record \"otheracct,AA-BBBB-CCCCCCC-DD,Liabilities:Rates\"")
+
+	var ruleFile string
+
+	fset.StringVar(&ruleFile, "rulefile", "", "CSV file of rules that set fields from patterns, optional "+
+		"e.g. record \"memo,Ref: (\\w+),reference=$1\"")
+	fset.UintVar(&cfg.maxMemo, "maxmemo", 0, "maximum number of characters in a memo, "+
+		"optional and longer memos, with any label, are truncated with an ellipsis, so at least its length")
+	fset.BoolVar(&cfg.plainMemo, "plainmemo", false,
+		"replace typographic quotes, dashes and mojibake in memos with plain text, optional")
+	fset.StringVar(&cfg.memoScript, "memoscript", memoPreserve, "policy for the script of memos, optional and "+
+		"preserve keeps it, or latin transliterates Cyrillic and Greek to Latin and removes accents, "+
+		"so memos from foreign banks match rules and dedupe consistently")
+	fset.StringVar(&cfg.output, "output", outputStandard, "output format, standard, debitcredit, "+
+		"excel-csv, ledger, parquet, xlsx or arrow, optional and debitcredit has debit and credit fields "+
+		"instead of amount, for systems rejecting negative amounts")
+	fset.StringVar(&cfg.rounding, "rounding", roundHalfUp, "mode for rounding the amounts of transactions "+
+		"split by tax rate to cents, optional and halfeven or halfup")
+	fset.StringVar(&cfg.zipPassword, "zippassword", "", "password for encrypted statements in zip archives, "+
+		"optional and defaults to environment variable "+zipPasswordEnv)
+	fset.StringVar(&cfg.imap.server, "imapserver", "", "IMAP server host and port for fetch, "+
+		"e.g. \"imap.example.com:993\"")
+	fset.StringVar(&cfg.imap.user, "imapuser", "", "IMAP user name for fetch")
+	fset.StringVar(&cfg.imap.password, "imappassword", "", "IMAP password for fetch, "+
+		"defaults to environment variable "+imapPasswordEnv)
+	fset.StringVar(&cfg.imap.mailbox, "imapmailbox", "INBOX", "IMAP mailbox for fetch")
+	fset.StringVar(&cfg.imap.search, "imapsearch", "UNSEEN", "IMAP search criteria for the messages to fetch, "+
+		"e.g. \"UNSEEN FROM statements@bank.example\"")
+
+	fset.StringVar(&cfg.cloud.dropboxToken, "dropboxtoken", "", "Dropbox access token for statements named "+
+		"\"dropbox:///path\", optional and defaults to environment variable "+dropboxTokenEnv)
+	fset.StringVar(&cfg.cloud.gdriveToken, "gdrivetoken", "", "Google Drive access token for statements named "+
+		"\"gdrive://<file ID>/name\", optional and defaults to environment variable "+gdriveTokenEnv)
+	fset.StringVar(&cfg.cloud.s3.region, "s3region", "", "AWS region of statements and output files named "+
+		"\"s3://bucket/key\", optional and defaults to environment variable "+s3RegionEnv+" or us-east-1")
+	fset.StringVar(&cfg.cloud.s3.endpoint, "s3endpoint", "", "URL of S3-compatible object storage, "+
+		"optional and defaults to AWS S3 in s3region e.g. \"https://minio.example.com:9000\"")
+	fset.StringVar(&cfg.sheet.id, "sheetid", "", "ID of a Google Sheet that transactions are appended to, "+
+		"instead of standard output or another output, optional and it is in the spreadsheet's URL")
+	fset.StringVar(&cfg.sheet.rng, "sheetrange", "Sheet1", "range of the sheet transactions are appended to")
+	fset.StringVar(&cfg.sheet.credentials, "sheetcredentials", "", "file of the Google service account key "+
+		"that appends to the Google Sheet e.g. \"service-account.json\"")
+
+	var sched string
+
+	fset.StringVar(&sched, "schedule", "", "cron expression of when fetch runs, optional and if set "+
+		"fetch runs as a service e.g. \"0 7 * * MON\"")
+	fset.StringVar(&cfg.metricsAddr, "metrics", "", "address to expose Prometheus metrics of a service on, "+
+		"optional and needs schedule e.g. \":9090\"")
+	fset.BoolVar(&cfg.fromClipboard, "clipboard", false, "read the statement from the system clipboard "+
+		"instead of standard input, optional")
+	fset.BoolVar(&cfg.toClipboard, "toclipboard", false, "write transactions to the system clipboard "+
+		"instead of standard output, optional")
+	fset.StringVar(&cfg.outFile, "outfile", "", "file transactions are written to instead of standard output, "+
+		"optional and can be in S3 e.g. \"s3://bucket/transactions.csv\"")
+	fset.BoolVar(&cfg.force, "force", false, "overwrite outfile if it is not empty, optional and "+
+		"by default cas2trn refuses, protecting transaction archives from being overwritten by accident")
+	fset.UintVar(&cfg.backups, "backups", 0, "number of timestamped backups kept of the state file, outfile and "+
+		"SQLite database, each made before a run changes them, optional e.g. 5")
+	fset.BoolVar(&cfg.bom, "bom", false, "write the UTF-8 byte order mark before transactions, "+
+		"optional and Excel on Windows then reads non-ASCII characters correctly")
+	fset.StringVar(&cfg.encryptTo, "encryptto", "", "age public key or GPG user that transactions are "+
+		"encrypted to before they are written, optional e.g. \"age1ql3z...\" or \"me@example.com\"")
+	fset.StringVar(&cfg.hmacKey, "hmackey", "", "secret key of an HMAC-SHA256 signature of outfile, "+
+		"written to a file named for it ending in \""+signExt+"\", optional and see verify e.g. \"keychain:hmac\"")
+	var lines string
+
+	fset.StringVar(&lines, "lines", "", "range of line numbers of the records to translate, optional and "+
+		"either number can be omitted e.g. \"100-500\" or \"100-\"")
+	fset.StringVar(&cfg.sequence, "sequence", "", "number transactions in input order, optional and adds field "+
+		"sequence to the output: file restarts numbers with each statement, global does not")
+	var dedupe string
+
+	fset.StringVar(&dedupe, "dedupe", "", "names of the fields that identify duplicate transactions, optional and "+
+		"only the first of duplicates is written e.g. \"date,amount,memo\" or \"date,amount,reference\"")
+	fset.BoolVar(&cfg.sortDate, "sort", false, "write transactions sorted by date after translating, optional and "+
+		"those on the same date keep their input order")
+	fset.BoolVar(&cfg.daySequence, "daysequence", false, "number transactions within each date, optional and "+
+		"adds field daysequence to the output, useful with sort")
+	fset.UintVar(&cfg.limit, "limit", 0, "maximum number of transactions to write, optional and "+
+		"reading stops once it is reached")
+	fset.UintVar(&cfg.sample, "sample", 0, "number of transactions chosen at random to write, in their order, "+
+		"optional and useful for testing a configuration")
+	fset.Float64Var(&cfg.tolerance, "tolerance", 0, "largest difference between a running balance and "+
+		"the previous balance plus the amount that is not warned about, optional and see balancei e.g. 0.01")
+	var periodPattern string
+
+	fset.StringVar(&periodPattern, "periodpattern", "", "regular expression that matches the first and last "+
+		"dates of the statement period, in dateformat, by its first two groups in a record stating it, "+
+		"optional e.g. \"Period (\\S+) to (\\S+)\"")
+	fset.BoolVar(&cfg.periodComment, "periodcomment", false, "write the period covered by the statements "+
+		"as a comment after the transactions, optional e.g. \"# period 2025-01-01 2025-01-31\"")
+	var expectCount, expectSum string
+
+	fset.StringVar(&expectCount, "expectcount", "", "number of transactions expected, optional and "+
+		"cas2trn fails if it writes a different number e.g. the number printed on the statement")
+	fset.StringVar(&expectSum, "expectsum", "", "sum of the transaction amounts expected, optional and "+
+		"cas2trn fails if the sum written differs by a cent or more e.g. \"-1234.56\"")
+	fset.UintVar(&cfg.maxGap, "maxgap", 0, "maximum number of days an account can have no transactions "+
+		"before a warning that a period may be missing, optional e.g. 5")
+	var holidayFile string
+
+	fset.StringVar(&holidayFile, "holidayfile", "", "file of days the bank is closed, one date or weekday name "+
+		"per line, optional and not counted by maxgap e.g. \"2025-12-25\" or \"Saturday\"")
+	fset.Float64Var(&cfg.outlierFactor, "outlierfactor", 0, "how many times the largest prior debit, or credit, "+
+		"of an account an amount can be before a warning, optional and needs dbdsn for the history e.g. 10")
+	fset.StringVar(&cfg.dbDSN, "dbdsn", "", "data source name of a database that transactions are written to, "+
+		"instead of standard output or another output, optional e.g. \"transactions.db\" or "+
+		"\"postgres://user@host/finances\"")
+	fset.StringVar(&cfg.dbDriver, "dbdriver", dbDriver, "database driver for dbdsn e.g. sqlite, pgx or mysql")
+	fset.UintVar(&cfg.dbBatch, "dbbatch", 1000, "number of transactions written to the database "+
+		"in each database transaction, see dbdsn")
+	fset.StringVar(&cfg.stateFile, "statefile", "", "file recording the progress of translating statement files, "+
+		"optional and an interrupted translation resumes where it left off")
+	fset.BoolVar(&cfg.whatsNew, "whatsnew", false, "write the numbers of transactions in each statement file "+
+		"that are new and already imported according to statefile, instead of translating, optional")
+	fset.BoolVar(&cfg.stats, "stats", false, "write statistics, with totals for each currency "+
+		"and counts for each statement file, to standard error after translating, optional")
+	var trainFile string
+
+	fset.StringVar(&trainFile, "trainfile", "", "file of transactions in the standard format, with other accounts, "+
+		"that trains a classifier suggesting the other accounts rules leave empty, optional and "+
+		"adds field confidence to the output")
+	fset.BoolVar(&cfg.review, "review", false, "review each transaction in the terminal before it is written, "+
+		"to fix its memo or other account or skip it, optional and fixes are appended to rulefile as rules")
+	fset.StringVar(&cfg.categoryFile, "categoryfile", "", "CSV file of memos and the other accounts assigned "+
+		"to them in review, which suggests other accounts for similar memos, "+
+		"optional and defaults to \"categories.csv\" in the user's cas2trn configuration directory")
+	fset.BoolVar(&printConfig, "printconfig", false, "write the effective configuration, in TOML, "+
+		"then exit, optional and secrets are redacted")
+	var saveConfigFile string
+
+	fset.StringVar(&saveConfigFile, saveConfigFlag, "", "file the effective configuration is written to, in TOML, "+
+		"once it is valid, optional and read again by config, with secrets redacted e.g. \"mybank.toml\"")
+	fset.BoolVar(&cfg.explain, "explain", false, "write how this configuration interprets a record, "+
+		"in plain English, instead of translating, optional and eases reviewing shared configurations")
+	fset.StringVar(&cfg.profileDir, "profiledir", "", "directory of profiles, each with a filepattern, "+
+		"that configure the statement files whose names match, optional and the other flags override them")
+	fset.String(filePatternFlag, "", "regular expression matching the names of statement files "+
+		"this profile configures, optional and see profiledir e.g. \"Kiwibank.*Full.*\\.csv\"")
+	fset.String(headerPatternFlag, "", "regular expression matching the header row of statements "+
+		"this profile configures, when they are concatenated on standard input, optional and see profiledir "+
+		"e.g. \"^Date,Payee,Amount\"")
+	fset.String(configFlag, "", "TOML or YAML file of flag names and values, such as printconfig writes, "+
+		"that the other flags override, optional e.g. \"kiwibank.toml\"")
+	var presetName string
+
+	fset.StringVar(&presetName, presetFlag, "", "name of a built-in or user configuration of a bank's statement format, "+
+		"that the other flags override, optional and \"list\" lists them e.g. \"kiwibank-full\"")
+	fset.String(profileFlag, "", "file of flags, one per line, that the other flags override, "+
+		"optional and its \""+profileExt+"\" extension can be omitted e.g. \"westpac\"")
+
+	var sets []string
+
+	fset.Func(setFlag, "flag name=value that overrides the other flags and the profile, "+
+		"optional, repeatable and e.g. \"thisacct=Assets:Joint\"", func(set string) error {
+		sets = append(sets, set)
+
+		return nil
+	})
+
+	args, err := prependProfile(args)
+	if err != nil {
+		return cfg, err
+	}
+
+	err = fset.Parse(replaceAliases(fset, args, os.Stderr))
+	if err != nil {
+		return cfg, fmt.Errorf("flag.FlagSet.Parse: %w", err)
+	}
+
+	err = applySets(fset, sets)
+	if err != nil {
+		return cfg, err
+	}
+
+	switch {
+	case help:
+		cfg.info = infoHelp
+	case version:
+		cfg.info = infoVersion
+	case presetName == presetList:
+		cfg.info = infoPresets
+	}
 
-	flag.StringVar(&cfg.currency, "currency", "", "unit for amounts on this statement, optional e.g. \"NZD\"")
-	flag.StringVar(&cfg.dateFormat, "dateformat", "", "date format, mandatory and Go style e.g. \"02/01/2006\"")
-	flag.StringVar(&cfg.thisAcct, "thisacct", "", "this account number or name, "+
-		"optional but if empty string then thisaccti must be non-zero")
+	if cfg.info != "" {
+		return cfg, errInfo
+	}
 
-	flag.Parse()
+	if cfg.profileDir != "" {
+		// each statement file is configured by the profile it matches, see translateMatched
+		cfg.profiles, err = loadProfileMatches(cfg.profileDir)
+		cfg.args = args[:len(args)-fset.NArg()]
 
-	if help {
-		flag.Usage()
-		os.Exit(0)
+		return cfg, err
 	}
 
 	cfg.nFields, cfg.amountI = ui2ui8(nFlds), ui2ui8(vals[0])
 	cfg.creditI, cfg.dateI = ui2ui8(vals[1]), ui2ui8(vals[2])
 	cfg.debitI, cfg.memoI = ui2ui8(vals[3]), ui2ui8(vals[4])
 	cfg.otherAcctI, cfg.thisAcctI = ui2ui8(vals[5]), ui2ui8(vals[6])
+	cfg.chequeI, cfg.dcI = ui2ui8(vals[7]), ui2ui8(vals[8])
+	cfg.currencyI, cfg.balanceI = ui2ui8(vals[9]), ui2ui8(vals[10])
+	cfg.statusI, cfg.origAmtI, cfg.origCurI = ui2ui8(vals[11]), ui2ui8(vals[12]), ui2ui8(vals[13])
+	cfg.feeI, cfg.quantityI = ui2ui8(vals[14]), ui2ui8(vals[15])
+	cfg.symbolI, cfg.priceI = ui2ui8(vals[16]), ui2ui8(vals[17])
+	cfg.principalI, cfg.interestI, cfg.escrowI = ui2ui8(vals[18]), ui2ui8(vals[19]), ui2ui8(vals[20])
+
+	for inx, field := range columnFields {
+		if cols[inx] != "" {
+			if cfg.columns == nil {
+				cfg.columns = make(map[string]string)
+			}
+
+			cfg.columns[field] = cols[inx]
+		}
+	}
+
+	if cfg.zipPassword == "" {
+		cfg.zipPassword = os.Getenv(zipPasswordEnv)
+	}
 
-	err := cfg.isValid()
+	cfg.cloud.dropboxURL, cfg.cloud.gdriveURL, cfg.sheet.apiURL = dropboxAPIURL, gdriveAPIURL, sheetsAPIURL
+
+	if cfg.cloud.dropboxToken == "" {
+		cfg.cloud.dropboxToken = os.Getenv(dropboxTokenEnv)
+	}
+
+	if cfg.cloud.gdriveToken == "" {
+		cfg.cloud.gdriveToken = os.Getenv(gdriveTokenEnv)
+	}
+
+	cfg.cloud.s3.accessKey, cfg.cloud.s3.secretKey = os.Getenv(s3AccessKeyEnv), os.Getenv(s3SecretKeyEnv)
+	cfg.cloud.s3.sessionToken = os.Getenv(s3SessionTokenEnv)
+
+	if cfg.cloud.s3.region == "" {
+		cfg.cloud.s3.region = cmp.Or(os.Getenv(s3RegionEnv), s3Region)
+	}
+
+	if cfg.cloud.s3.endpoint == "" {
+		cfg.cloud.s3.endpoint = "https://s3." + cfg.cloud.s3.region + ".amazonaws.com"
+	}
+
+	if cfg.imap.password == "" {
+		cfg.imap.password = os.Getenv(imapPasswordEnv)
+	}
+
+	for _, secret := range []*string{&cfg.zipPassword, &cfg.imap.password, &cfg.hmacKey, &cfg.cloud.dropboxToken,
+		&cfg.cloud.gdriveToken, &cfg.cloud.s3.accessKey, &cfg.cloud.s3.secretKey, &cfg.cloud.s3.sessionToken} {
+		*secret, err = resolveSecret(*secret)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	cfg.debitMark, cfg.creditMark, _ = strings.Cut(dcMarks, ",")
+	cfg.dialect = dialect{decimal: parseRune(decimal), delimiter: parseRune(delim), quote: parseRune(quote),
+		recordSep: parseRune(recordSep)}
+
+	if amtPattern != "" {
+		cfg.amountPattern, err = regexp.Compile(amtPattern)
+		if err != nil {
+			return cfg, fmt.Errorf("regexp.Compile: %w", err)
+		}
+	}
+
+	if datePattern != "" {
+		cfg.datePattern, err = regexp.Compile(datePattern)
+		if err != nil {
+			return cfg, fmt.Errorf("regexp.Compile: %w", err)
+		}
+	}
+
+	if thisAcctPattern != "" {
+		cfg.thisAcctPattern, err = regexp.Compile(thisAcctPattern)
+		if err != nil {
+			return cfg, fmt.Errorf("regexp.Compile: %w", err)
+		}
+	}
+
+	if periodPattern != "" {
+		cfg.periodPattern, err = regexp.Compile(periodPattern)
+		if err != nil {
+			return cfg, fmt.Errorf("regexp.Compile: %w", err)
+		}
+
+		if cfg.periodPattern.NumSubexp() < 2 {
+			return cfg, errPeriodGroups
+		}
+	}
+
+	if linePattern != "" {
+		cfg.linePattern, err = regexp.Compile(linePattern)
+		if err != nil {
+			return cfg, fmt.Errorf("regexp.Compile: %w", err)
+		}
+
+		cfg.setLineIndexes()
+
+		if cfg.linePattern.NumSubexp() != int(cfg.nFields) {
+			return cfg, errLineGroups
+		}
+	}
+
+	if expectCount != "" {
+		cnt, err := strconv.ParseUint(expectCount, 10, 0)
+		if err != nil {
+			return cfg, fmt.Errorf("strconv.ParseUint: %w", err)
+		}
+
+		count := uint(cnt)
+		cfg.expectCount = &count
+	}
+
+	if expectSum != "" {
+		sum, err := parseFloat64(expectSum)
+		if err != nil {
+			return cfg, err
+		}
+
+		cfg.expectSum = &sum
+	}
+
+	if earnings != "" {
+		cfg.earnings, err = parsePayColumns(earnings)
+		if err != nil {
+			return cfg, fmt.Errorf("parsePayColumns: %w", err)
+		}
+	}
+
+	if deductions != "" {
+		cfg.deductions, err = parsePayColumns(deductions)
+		if err != nil {
+			return cfg, fmt.Errorf("parsePayColumns: %w", err)
+		}
+	}
+
+	if acctCurrencies != "" {
+		cfg.acctCurrencies, err = parseAcctCurrencies(acctCurrencies)
+		if err != nil {
+			return cfg, fmt.Errorf("parseAcctCurrencies: %w", err)
+		}
+	}
+
+	if lines != "" {
+		cfg.firstLine, cfg.lastLine, err = parseLineRange(lines)
+		if err != nil {
+			return cfg, fmt.Errorf("parseLineRange: %w", err)
+		}
+	}
+
+	if sched != "" {
+		sch, err := parseSchedule(sched)
+		if err != nil {
+			return cfg, fmt.Errorf("parseSchedule: %w", err)
+		}
+
+		cfg.schedule = &sch
+	}
+
+	if cfg.metricsAddr != "" && cfg.schedule == nil {
+		return cfg, errMetrics
+	}
+
+	if printConfig {
+		cfg.info = infoConfig
+
+		return cfg, errInfo
+	}
+
+	if cfg.chequeI != 0 {
+		cfg.extraNames = append(cfg.extraNames, chequeName)
+	}
+
+	if cfg.statusI != 0 && cfg.pendingPolicy == pendingInclude {
+		cfg.extraNames = append(cfg.extraNames, statusName)
+	}
+
+	if cfg.origAmtI != 0 {
+		cfg.extraNames = append(cfg.extraNames, origAmountName, origCurrencyName)
+	}
+
+	for _, trade := range []struct {
+		inx  uint8
+		name string
+	}{{cfg.quantityI, quantityName}, {cfg.symbolI, symbolName}, {cfg.priceI, priceName}} {
+		if trade.inx != 0 {
+			cfg.extraNames = append(cfg.extraNames, trade.name)
+		}
+	}
+
+	err = cfg.isValid()
 	if err != nil {
 		return cfg, fmt.Errorf("config.isValid: %w", err)
 	}
 
-	return cfg, nil
-}
+	if saveConfigFile != "" {
+		err = saveConfig(saveConfigFile, fset)
+		if err != nil {
+			return cfg, fmt.Errorf("saveConfig: %w", err)
+		}
+	}
 
-/*
-TranslateStatement translates financial transactions in an account statement
-from an arbitrary CSV format to the standard format and returns nil.
-It reads each transaction, and parses it according to the cas2trn ration.
-If it fails to read the statement, translateStatement returns an error.
-If it fails to parse a transaction,
-translateStatement writes an error to standard error and continues.
-If it successfully parses a transaction,
-translateStatement writes it in the standard format to standard output and continues.
-*/
-func translateStatement(reader *csv.Reader, cfg config) error {
-	// Disable number of fields per record check; it is done in transact.transact() instead.
-	reader.FieldsPerRecord = -1
+	if chartFile != "" {
+		cfg.chart, err = loadChart(chartFile)
+		if err != nil {
+			return cfg, fmt.Errorf("loadChart: %w", err)
+		}
+	}
+
+	if holidayFile != "" {
+		cfg.holidays, err = loadCalendar(holidayFile)
+		if err != nil {
+			return cfg, fmt.Errorf("loadCalendar: %w", err)
+		}
+	}
+
+	if mapFile != "" {
+		cfg.mappings, err = loadMappings(mapFile)
+		if err != nil {
+			return cfg, fmt.Errorf("loadMappings: %w", err)
+		}
+	}
+
+	cfg.ruleFile = ruleFile
+
+	if cfg.review {
+		if cfg.categoryFile == "" {
+			dir, dirErr := os.UserConfigDir()
+			if dirErr == nil {
+				cfg.categoryFile = filepath.Join(dir, pgmName, "categories.csv")
+			}
+		}
+
+		if cfg.categoryFile != "" {
+			cfg.categories, err = loadCategories(cfg.categoryFile)
+			if err != nil {
+				return cfg, fmt.Errorf("loadCategories: %w", err)
+			}
+		}
+	}
+
+	if ruleFile != "" {
+		cfg.rules, err = loadRules(ruleFile, cfg.extraNames)
+		if err != nil {
+			return cfg, fmt.Errorf("loadRules: %w", err)
+		}
+
+		for _, name := range cfg.rules.extraNames() {
+			if !slices.Contains(cfg.extraNames, name) {
+				cfg.extraNames = append(cfg.extraNames, name)
+			}
+		}
+	}
+
+	if trainFile != "" {
+		cfg.classifier, err = loadClassifier(trainFile)
+		if err != nil {
+			return cfg, fmt.Errorf("loadClassifier: %w", err)
+		}
+
+		cfg.extraNames = append(cfg.extraNames, confidenceName)
+	}
 
-	for {
-		flds, err := reader.Read()
-		if errors.Is(err, io.EOF) {
-			return nil
-		} else if err != nil {
-			return fmt.Errorf("reader.Read(): %w", err)
+	for _, template := range []string{cfg.memoPrefix, cfg.memoSuffix} {
+		trn := transact{extraNames: cfg.extraNames, extras: make([]string, len(cfg.extraNames))}
+
+		err = trn.isValidTemplate(labelNames.Replace(template))
+		if err != nil {
+			return cfg, fmt.Errorf("transact.isValidTemplate: %w", err)
 		}
+	}
 
-		var trn transact
+	if cfg.docRef != "" {
+		trn := transact{extraNames: cfg.extraNames, extras: make([]string, len(cfg.extraNames))}
 
-		err = trn.transact(flds, cfg)
+		err = trn.isValidTemplate(cfg.docRef)
 		if err != nil {
-			lineN, _ := reader.FieldPos(0)
-			fmt.Fprintln(os.Stderr,
-				fmt.Errorf("%v: transact.transact: %w on line %v", pgmName, err, lineN))
+			return cfg, fmt.Errorf("transact.isValidTemplate: %w", err)
+		}
 
-			continue
+		cfg.extraNames = append(cfg.extraNames, documentName)
+	}
+
+	if dedupe != "" {
+		cfg.dedupeKey = strings.Split(dedupe, ",")
+		trn := transact{extraNames: cfg.extraNames, extras: make([]string, len(cfg.extraNames))}
+
+		for _, name := range cfg.dedupeKey {
+			_, ok := trn.value(name)
+			if !ok {
+				return cfg, fmt.Errorf("%w: %q", errDedupeKey, name)
+			}
 		}
+	}
 
-		fmt.Fprintln(os.Stdout, trn.string())
+	if cfg.sequence != "" {
+		cfg.extraNames = append(cfg.extraNames, sequenceName)
 	}
+
+	if cfg.daySequence {
+		cfg.extraNames = append(cfg.extraNames, daySequenceName)
+	}
+
+	return cfg, nil
 }
 
 /*
@@ -180,6 +906,20 @@ func usage() {
 	const pgmTitle = "Cas2trn"
 
 	fmt.Fprintf(os.Stderr, "usage: %v [flags] [file names]\n", pgmName)
+	fmt.Fprintf(os.Stderr, "       %v %v [flags] statement journal\n", pgmName, reconcileCmd)
+	fmt.Fprintf(os.Stderr, "       %v %v [flags]\n", pgmName, fetchCmd)
+	fmt.Fprintf(os.Stderr, "       %v %v [flags]\n", pgmName, undoCmd)
+	fmt.Fprintf(os.Stderr, "       %v %v -hmackey key file\n", pgmName, verifyCmd)
+	fmt.Fprintf(os.Stderr, "       %v %v\n", pgmName, demoCmd)
+	fmt.Fprintf(os.Stderr, "       %v %v statement\n", pgmName, inferCmd)
+	fmt.Fprintf(os.Stderr, "       %v %v statement\n", pgmName, setupCmd)
+	fmt.Fprintf(os.Stderr, "       %v %v\n", pgmName, updateCmd)
+	fmt.Fprintf(os.Stderr, "       %v %v [-listen address] [-rpclisten address] -profiledir directory\n",
+		pgmName, serveCmd)
+	fmt.Fprintf(os.Stderr, "       %v %v %v configuration configuration\n", pgmName, presetsCmd, presetsDiffCmd)
+	fmt.Fprintf(os.Stderr, "       %v %v hledgerrules profile\n", pgmName, importCmd)
+	fmt.Fprintf(os.Stderr, "       %v %v %v rulefile transactions\n", pgmName, rulesCmd, rulesTestCmd)
+	fmt.Fprintf(os.Stderr, "       %v %v %v oldrulefile newrulefile transactions\n", pgmName, rulesCmd, rulesDiffCmd)
 	fmt.Fprintln(os.Stderr)
 	fmt.Fprintf(os.Stderr, "%v %v\n", pgmTitle,
 		"translates financial transactions from an arbitrary comma-separated values (CSV) format to the standard format.")
@@ -187,18 +927,98 @@ func usage() {
 		`The program's name stands for CSV account statement to transactions, 
 and it allows transactions from statements in different formats to be combined.
 If the names of statement files are not given, cas2trn reads transactions from standard input.
+Names of statement files can be glob patterns, e.g. "statements\*.csv", which Windows shells do not expand.
+A byte order mark at the start of a statement, as Excel writes, is ignored.
+Statement files named "*.zip" are zip archives of statements, which can be encrypted, see zippassword.
+If linepattern is set, statements are text, such as copied internet banking pages or print files,
+and each line matching it is a transaction whose fields are its groups.
+Groups named for fields, e.g. "(?P<date>\S+)", set the field indexes, and nfields defaults to the number of groups.
+Statement files named "*.pdf" are PDF statements, whose lines of text are matched by linepattern;
+the columns of tables are separated by two or more spaces.
+Presets with a linepattern are templates of banks' PDF statements, e.g. "-preset apple-card-pdf",
+and a template for another bank can be added as a user preset, see preset.
+PDF statements whose fonts are composite (CID), or embedded without a standard encoding, cannot be read.
+Statements can be read from cloud storage, such as where a bank's app saves them,
+by names such as "dropbox:///Statements/2025-01.csv" or "gdrive://<file ID>/2025-01.csv",
+whose name after the Google Drive file ID gives the type of the file.
+They need an access token, see dropboxtoken and gdrivetoken.
+Statements named "s3://bucket/key" are read from AWS S3, or S3-compatible object storage, see s3endpoint,
+with credentials from environment variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+Transactions can be written to S3 too, see outfile, so cas2trn can run in a scheduled job on a bucket.
+A local output file that is not empty is only overwritten if force is set.
+As transactions are sensitive, they can be encrypted with age or GPG before they are written, see encryptto.
+For audits, an output file can be signed, see hmackey, and the verify command checks it has not been modified.
+Passwords and access tokens, from flags, profiles or environment variables, can be kept in the OS keychain:
+a value such as "keychain:imap" is the secret stored for service cas2trn and account imap,
+e.g. by "secret-tool store --label=cas2trn service cas2trn account imap" on Linux
+or "security add-generic-password -s cas2trn -a imap -w" on macOS.
+If clipboard is set, cas2trn reads a statement from the system clipboard, such as a table copied from
+internet banking, which is often tab separated, see delimiter.
+Statements in other CSV dialects, such as European ones with ";" delimiters and "," decimal separators,
+are read with delimiter, quote and decimal, or detectdialect.
+Lines of statements may end in carriage returns alone, as old Mac statements do, or records may be separated
+by another character, see recordseparator.
+If trainfile is set, a naive Bayes classifier is trained on the memos of its transactions, such as those
+translated and categorised before, and suggests the other accounts that rules leave empty from the words of memos,
+with the probability the suggestion is right in field confidence e.g. "0.93".
+If review is set, cas2trn shows each transaction in the terminal before writing it,
+and the memo or other account can be fixed, or the transaction skipped.
+Each fix is learned as a rule matching the memo, which is applied to later transactions with the same memo
+and appended to rulefile, so they need no review next time.
+The other accounts assigned to memos are remembered in categoryfile,
+and the one assigned to the most similar memo, sharing at least half its words, is suggested.
+Flags that have been renamed can still be set by their old names, with a warning once per run.
+If preset is set, the statement format of a bank, or a common one, is configured by name,
+e.g. "-preset kiwibank-full -thisacct=Assets:Cheque", and "-preset list" lists the presets.
+Presets can be added, or built-in ones overridden, by files of flags or configuration files named for them,
+e.g. "westpac.flags" or "westpac.toml", in "presets" in the user's cas2trn configuration directory.
+If config is set, the flags in it, in TOML or YAML e.g. "nFields = 5" or "dateformat: 02/01/2006",
+are read first, so typing field indexes and formats every run can be avoided.
+If saveconfig is set, the flags are written to its file once they are valid, for the next run's config flag.
+If profile is set, its flags are read first too, so the other flags override them,
+and set overrides a flag without editing the profile e.g. "-profile westpac -set thisacct=Assets:Joint".
+If profiledir is set, each statement file is configured by the first profile in it, by name,
+whose filepattern matches the file's name, so one run over a downloads folder translates each bank's files.
+If no statement files are named, standard input is split into statements at lines that match the headerpattern
+of a profile, each configured by the first such profile, so statements concatenated by a script can be translated.
+If explain is set, cas2trn writes how the flags interpret a record in plain English instead of translating,
+e.g. "column 1 is the date in the format day/month/year", which eases reviewing shared flags and profiles.
 
 The standard transaction format, written as a CSV record to standard output, contains the following fields:
  * date in ISO 8601 format, which is sortable, e.g. "2006-01-02"
  * this account number or name
- * other account number or name, optional and can be empty string
+ * other account number or name, optional and can be empty string unless otheracct is set
  * memo or description
  * amount
  * currency, optional
 
+If output is debitcredit, the amount field is replaced by a debit and a credit field,
+one of which is empty string and the other a positive amount.
+If output is excel-csv, the standard format is written for reviewing in Excel: UTF-8 with a byte order mark,
+CRLF line endings, quoted fields and account numbers as text, so "12-3456-7890123-00" is not mangled into a date.
+If output is parquet, the transactions are written as a Parquet file after translating, for data analysis,
+with a date, a decimal amount to four decimal places, as some currencies have, and strings for the other fields.
+If output is arrow, they are written as an Arrow IPC file of record batches, with the same columns,
+for programs such as DuckDB, pandas and Polars that analyse millions of transactions without parsing CSV.
+If output is xlsx, the transactions are written as an Excel workbook after translating, for sharing,
+with a sheet for each this account, a frozen header row, and date and amount cell formats.
+If output is ledger, each transaction is written as a ledger journal entry, with postings to this account
+and the other account, and any extra fields as comment tags.
+Trades of securities, configured by quantityi, symboli and pricei, are postings of the quantity of the security
+at the total cost of the amount, so share trades are translated into ledger postings rather than cash amounts.
+
+Extra fields, such as the cheque number, the status of a pending transaction, the original amount and currency
+of a foreign purchase, those added by rules, the confidence of a suggested other account,
+the document reference or the sequence numbers, follow the currency field.
+A template refers to the fields of a transaction by their names in braces, e.g. "{date}" or "{reference}".
+The memoprefix and memosuffix templates can also refer to the statement file name, the year and month its period
+starts, or else of the date, and whether the transaction is a credit or debit, as "{file}", "{period}" and "{type}".
+
 Parsing the arbitrary input transaction format is configured by flags.
 Fields in the CSV records are linked to those in transactions by field indexes.
 An index of zero means these records do not contain that field.
+Fields that contain more than a date or an amount, such as "2019-12-24 13:05" or "NZD 162.00",
+are handled by datepattern and amountpattern, whose first group extracts the date or amount.
 The flags are:
 `)
 	flag.PrintDefaults()
@@ -212,5 +1032,143 @@ The output transaction, in standard format, would be "2019-12-24,PCUS1,,Brumby's
 
 If cas2trn fails to parse a CSV record as a transaction, it prints an error on standard error then continues.
 Errors about unparseable header lines can be ignored.
+If most records fail to parse, cas2trn suggests fixes to the configuration after translating,
+e.g. a date format that parses the dates, or a field that looks like amounts.
+
+After a transaction is parsed, the mappings in the map file replace field values,
+then each rule in the rule file whose pattern matches a field sets the fields in its assignments.
+For example, rule "memo,Ref: (\w+),reference=$1,otheracct=Expenses:Rates" adds field reference to the output,
+and rule "memo,^Cheque (\d+),cheque=$1" extracts cheque numbers from memos.
+Rules can also assign taxrate, the percentage of tax included in the amount, and taxacct, the account for the tax.
+These are not output, instead the transaction is split into net and tax transactions,
+e.g. rule "otheracct,^Expenses:,taxrate=15,taxacct=Liabilities:GST".
+The tax is rounded to cents by rounding, and the net amount is the rest, so the two sum exactly to the amount.
+Rules are applied in order of priority, highest first, then in the order they are in the file; by default
+every rule that matches is applied, so a later rule can overwrite a field set by an earlier one.
+Options after the pattern change this: "!priority=n" sets the priority, zero by default,
+and "!stop" stops applying rules after this one matches,
+e.g. "memo,^Transfer to savings,!priority=10,!stop,otheracct=Assets:Savings".
+
+If dedupe is set, a transaction whose named fields all equal those of an earlier transaction is a duplicate,
+and is not written, e.g. when statements overlap.
+Banks differ in what makes a duplicate, e.g. some change the memo of a pending transaction once it clears,
+so "date,amount" or "date,amount,reference" may suit better than "date,amount,memo".
+
+If balancei is set, cas2trn warns about running balances that do not follow from the previous balance and
+the amount, as a record may be missing.
+Statements can list records oldest or newest first.
+Some banks round running balances differently from amounts, which tolerance allows for.
+
+The period covered by the statements is written with the statistics, and after the transactions if
+periodcomment is set.
+It is that stated by a record matching periodpattern, such as a heading, else the first to the last date.
+
+If dbdsn is set, transactions are written to table transactions in a database instead,
+such as SQLite, PostgreSQL or MySQL, see dbdriver.
+The table has columns id, the fields of the standard format, and extras, the extra fields as a JSON object.
+The id is a hash of the fields, so writing a transaction again updates it rather than duplicating it.
+Transactions are written in batches, each in a database transaction, which is retried if the database is busy.
+The SQLite driver is linked into cas2trn when it is built with cgo, and other drivers must be linked into it.
+If outlierfactor and dbdsn are set, cas2trn warns about an amount more than that many times the largest debit,
+or credit, of its account in the database and the transactions before it, which catches a misconfigured
+decimal separator before it pollutes the ledger.
+
+If sheetid is set, transactions are appended to a Google Sheet instead, after translating,
+e.g. to track a budget.
+Cas2trn authorises as a Google Cloud service account, whose key is in the credentials file,
+and the spreadsheet must be shared with the service account's email address.
+
+If a statement has no transactions, such as one of only headers or records that fail to parse,
+cas2trn writes "no transactions found" with its name, translates the other statements, then exits with status 3,
+so cron jobs notice broken downloads.
+
+If statefile is set, cas2trn records how far it has translated each statement file in it,
+so a translation of many statements that is interrupted can be resumed by running it again.
+Statements already translated are skipped, and a statement partly translated resumes at its last line saved.
+Progress is saved every thousand records, so some transactions may be written again, see dedupe.
+The state file, output file and SQLite database are locked while translating or fetching, by a lock file next to
+each ending in ".lock", so runs started together, such as by cron, fail with "another import is running".
+A lock file left by a run that was killed is taken over, as the process ID it holds is no longer running.
+If backups is set, each of them is copied to a backup named for the time, e.g. "transactions.csv.20250131T070000.bak",
+before the run changes it, and only that many of the latest backups are kept,
+so a run with a bad rule file or dedupe can be rolled back by copying a backup over the file.
+A SQLite database copies itself, so its backup includes changes still in its journal.
+
+If maxgap is set, cas2trn warns about gaps in the dates of each account's transactions after translating,
+which may be missing statements.
+Days in the holiday file, such as weekends and public holidays, are not counted,
+so low-activity accounts do not warn about quiet weekends.
+
+The reconcile command matches the transactions in a statement file to the entries in a ledger journal file.
+A transaction matches an entry if one of its postings is to this account with the transaction's signed amount,
+and their dates are at most three days apart; ties are broken by the similarity of memo and payee.
+Transactions and entries that do not match are written to standard output.
+
+The fetch command fetches the messages matching imapsearch from an IMAP mailbox over TLS,
+translates the transactions in their CSV, PDF and zip attachments, then marks the messages as seen.
+OFX attachments cannot be translated and are warned about.
+If schedule is set, fetch runs as a service at the times of its cron expression,
+with fields minute, hour, day of month, month and day of week, and logs each run to standard error.
+If metrics is also set, the service exposes Prometheus metrics at path /metrics,
+including statements and transactions processed, parse failures and the time of the last successful run.
+
+The undo command undoes the most recent run with the same flags, which reverts a misconfigured import.
+Each run that writes to a state file or database reports what it changed in a file named for the state file,
+or else the SQLite database, ending in ".runs": the transactions it inserted and the progress it made.
+Undo deletes those transactions from the database, restores the progress in the state file, and removes the report,
+so undoing again undoes the run before.
+The output file is written afresh by each run, so undo leaves it, but it can be restored from a backup, see backups.
+
+The demo command translates anonymised sample statements, in the formats of several banks,
+into several output formats, showing the flags that configure each, so cas2trn can be tried before
+it is configured for one's own statements.
+
+The infer command reads the first hundred records of a sample statement, recognises the fields that look like
+dates, amounts, credits and debits, and memos, and writes a suggested configuration, for the config flag,
+so a new statement format can be configured in one command.
+
+The setup command configures a statement format interactively: it shows the columns of a sample statement,
+asks which column is which, checks the date format against the dates, previews the transactions,
+and writes the configuration to a file for the config flag.
+
+The self-update command replaces cas2trn with the binary of the latest release for this platform,
+once its signature is verified with the key the release binaries are signed with,
+so cas2trn can be kept up to date without rebuilding it from source.
+It only updates to a later version, so it never downgrades, and a binary built from source has no key to verify with.
+
+The verify command checks that a signed output file matches its signature, written with it when hmackey is set,
+so an archive of translated statements can be shown to be unmodified.
+
+The serve command translates statements uploaded to its HTTP endpoint "POST /translate".
+The request is a multipart form with fields statement, the CSV or zip statement file,
+profile, the name of a file of flags in profiledir without its "`+profileExt+`" extension,
+and format, csv (the default) or json.
+The response is the transactions in the standard format.
+Profiles cannot set interactive flags, e.g. review, or flags that write elsewhere, e.g. outfile or dbdsn.
+If rpclisten is set, serve also accepts JSON-RPC 1.0 connections, with methods
+"TranslationService.TranslateStatement" and "TranslationService.TranslateStatementStream" taking
+params [{"Profile": ..., "Name": ..., "Statement": base64}], with each transaction in the JSON format.
+The first returns {"Transactions": [...]}.
+The second streams the transactions as they are translated, each in a notification
+{"method": "TranslationService.Transaction", "params": [request id, transaction], "id": null},
+then returns {"Count": n}, or an error, after which the transactions notified should be discarded.
+
+The presets diff command writes the options that differ between two configurations, each with its value in both,
+which helps find why a shared preset behaves differently from one's own flags.
+Each is a TOML or YAML configuration file, a profile, or else the name of a preset,
+e.g. "cas2trn presets diff mycfg.toml kiwibank-full".
+
+The import command seeds a profile from an hledger CSV rules file, easing migration from hledger.
+Its fields, skip, date-format, separator, decimal-mark, currency and account directives become flags,
+in profile ".flags", and its if blocks that set account1, account2 or description become rules,
+in profile ".rules.csv". Directives with no equivalent are written as comments, to be migrated by hand.
+Beancount importers are Python code, so they cannot be imported.
+
+The rules test command applies the rules in a rule file to a file of transactions in the standard format,
+and reports the transactions no rule matched, and how many transactions each rule matched,
+so rules that never match can be found in large rule files.
+The rules diff command applies the rules in an old and a new rule file to a file of transactions
+in the standard format, and reports the fields, such as otheracct, that the new rules change,
+so edits to rules can be checked before they are used.
 `)
 }
@@ -0,0 +1,96 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+/*
+Mappings map the values of transaction fields to replacement values.
+They are keyed by field name then value.
+*/
+type mappings map[string]map[string]string
+
+const nMappingFields = 3 // field name, value and replacement
+
+var (
+	errMappingField   = errors.New("field name in mapping must be currency, memo, otheracct or thisacct")
+	errMappingNFields = errors.New("mapping must have three fields: field name, value and replacement")
+)
+
+/*
+LoadMappings returns the mappings read from the named CSV file and nil.
+Each record in the file is a mapping of field name, value and replacement,
+e.g. "otheracct,AA-BBBB-CCCCCCC-DD,Liabilities:Rates".
+If loadMappings fails to read or parse a mapping, it returns an error.
+*/
+func loadMappings(name string) (mappings, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	maps := make(mappings)
+	rdr := csv.NewReader(file)
+	rdr.FieldsPerRecord = -1
+
+	for {
+		flds, err := rdr.Read()
+		if errors.Is(err, io.EOF) {
+			return maps, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("reader.Read(): %w", err)
+		}
+
+		lineN, _ := rdr.FieldPos(0)
+
+		if len(flds) != nMappingFields {
+			return nil, fmt.Errorf("%w on line %v", errMappingNFields, lineN)
+		}
+
+		var trn transact
+		if trn.field(flds[0]) == nil {
+			return nil, fmt.Errorf("%w on line %v", errMappingField, lineN)
+		}
+
+		if maps[flds[0]] == nil {
+			maps[flds[0]] = make(map[string]string)
+		}
+
+		maps[flds[0]][nfc(flds[1])] = nfc(flds[2])
+	}
+}
+
+// Apply replaces the values of the transaction's fields that have a mapping.
+func (maps mappings) apply(trn *transact) {
+	for name, vals := range maps {
+		fld := trn.field(name)
+
+		repl, ok := vals[*fld]
+		if ok {
+			*fld = repl
+		}
+	}
+}
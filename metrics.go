@@ -0,0 +1,109 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+Metrics are the totals of the runs of a service, exposed to Prometheus.
+They are safe to use concurrently.
+*/
+type metrics struct {
+	mutex       sync.Mutex
+	lastSuccess time.Time
+	nFailed     int // records that failed to parse as a transaction
+	nFiles      int // statements translated
+	nRuns       int
+	nRunsFailed int
+	nTransacts  int
+}
+
+var errMetrics = errors.New("metrics needs schedule, as they are only exposed by a service")
+
+// Record adds the statistics of a run, which failed if the error is not nil, to the metrics.
+func (mts *metrics) record(sts stats, err error) {
+	mts.mutex.Lock()
+	defer mts.mutex.Unlock()
+
+	mts.nFailed += sts.nFailed
+	mts.nFiles += sts.nFiles
+	mts.nTransacts += sts.nTransacts()
+	mts.nRuns++
+
+	if err != nil {
+		mts.nRunsFailed++
+	} else {
+		mts.lastSuccess = time.Now()
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (mts *metrics) ServeHTTP(writer http.ResponseWriter, _ *http.Request) {
+	mts.mutex.Lock()
+	defer mts.mutex.Unlock()
+
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	lastSuccess := 0.0
+	if !mts.lastSuccess.IsZero() {
+		lastSuccess = float64(mts.lastSuccess.UnixMilli()) / float64(time.Second/time.Millisecond)
+	}
+
+	for _, mtc := range []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"files_processed_total", "counter", "Statements translated.", float64(mts.nFiles)},
+		{"transactions_total", "counter", "Transactions written.", float64(mts.nTransacts)},
+		{"parse_failures_total", "counter", "Records that failed to parse as a transaction.", float64(mts.nFailed)},
+		{"runs_total", "counter", "Scheduled runs.", float64(mts.nRuns)},
+		{"runs_failed_total", "counter", "Scheduled runs that failed.", float64(mts.nRunsFailed)},
+		{"last_success_timestamp_seconds", "gauge", "Time of the last successful run.", lastSuccess},
+	} {
+		fmt.Fprintf(writer, "# HELP %[1]v_%[2]v %[3]v\n# TYPE %[1]v_%[2]v %[4]v\n%[1]v_%[2]v %[5]v\n",
+			pgmName, mtc.name, mtc.help, mtc.kind, mtc.value)
+	}
+}
+
+/*
+ServeMetrics listens on the address, then serves the metrics at path /metrics in the background, and returns nil.
+If serveMetrics fails to listen, it returns an error.
+*/
+func serveMetrics(addr string, mts *metrics) error {
+	lnr, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("net.Listen: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", mts)
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: time.Minute}
+
+	go srv.Serve(lnr)
+
+	return nil
+}
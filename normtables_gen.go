@@ -0,0 +1,308 @@
+//go:build ignore
+
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+/*
+Normtables_gen writes normtables.go, the tables nfc and transliterate use, from the Unicode character database.
+It reads UnicodeData.txt and CompositionExclusions.txt from the -ucd URL or directory, e.g.
+
+	go run normtables_gen.go -ucd ~/ucd
+*/
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// UnicodeVersion is the version of the Unicode character database the tables are generated from.
+const unicodeVersion = "14.0.0"
+
+// The Unicode data of a character used in the tables.
+type ucdChar struct {
+	class uint8
+	dec   []rune // canonical decomposition
+}
+
+// The file header, which is the same as that of the other source files.
+const header = `/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// Code generated by normtables_gen.go from the Unicode %v character database. DO NOT EDIT.
+
+package main
+`
+
+func main() {
+	ucd := flag.String("ucd", "https://www.unicode.org/Public/"+unicodeVersion+"/ucd/",
+		"URL or directory of the Unicode character database")
+	out := flag.String("out", "normtables.go", "file to write the tables to")
+	flag.Parse()
+
+	chars, err := parseUnicodeData(openUCD(*ucd, "UnicodeData.txt"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	excls, err := parseExclusions(openUCD(*ucd, "CompositionExclusions.txt"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	src, err := format.Source(tables(chars, excls))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = os.WriteFile(*out, src, 0o644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// OpenUCD returns a reader of the named file in the Unicode character database, a URL or directory.
+func openUCD(ucd, name string) io.Reader {
+	if !strings.HasPrefix(ucd, "http://") && !strings.HasPrefix(ucd, "https://") {
+		data, err := os.ReadFile(filepath.Join(ucd, name))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return bytes.NewReader(data)
+	}
+
+	resp, err := http.Get(strings.TrimSuffix(ucd, "/") + "/" + name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		log.Fatalf("http.Get %v: %v", name, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return bytes.NewReader(data)
+}
+
+/*
+ParseUnicodeData returns the combining class and canonical decomposition of each character in UnicodeData.txt
+that has either.
+Compatibility decompositions, which have a tag such as <font>, are ignored.
+*/
+func parseUnicodeData(reader io.Reader) (map[rune]ucdChar, error) {
+	chars := make(map[rune]ucdChar)
+
+	scnr := bufio.NewScanner(reader)
+	for scnr.Scan() {
+		flds := strings.Split(scnr.Text(), ";")
+
+		const nFields = 15
+		if len(flds) != nFields {
+			continue
+		}
+
+		r, err := strconv.ParseUint(flds[0], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("strconv.ParseUint: %w", err)
+		}
+
+		cls, err := strconv.ParseUint(flds[3], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("strconv.ParseUint: %w", err)
+		}
+
+		chr := ucdChar{class: uint8(cls)}
+
+		if flds[5] != "" && !strings.HasPrefix(flds[5], "<") {
+			for _, hex := range strings.Fields(flds[5]) {
+				d, err := strconv.ParseUint(hex, 16, 32)
+				if err != nil {
+					return nil, fmt.Errorf("strconv.ParseUint: %w", err)
+				}
+
+				chr.dec = append(chr.dec, rune(d))
+			}
+		}
+
+		if chr.class != 0 || chr.dec != nil {
+			chars[rune(r)] = chr
+		}
+	}
+
+	return chars, scnr.Err()
+}
+
+// ParseExclusions returns the characters listed in CompositionExclusions.txt.
+func parseExclusions(reader io.Reader) ([]rune, error) {
+	var excls []rune
+
+	scnr := bufio.NewScanner(reader)
+	for scnr.Scan() {
+		line, _, _ := strings.Cut(scnr.Text(), "#")
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		r, err := strconv.ParseUint(line, 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("strconv.ParseUint: %w", err)
+		}
+
+		excls = append(excls, rune(r))
+	}
+
+	return excls, scnr.Err()
+}
+
+/*
+Tables returns the Go source of the decompositions, compositionExclusions and combiningClasses tables.
+The exclusions are those with a decomposition to two characters, the listed exclusions
+and the non-starter decompositions, which begin with a combining character.
+*/
+func tables(chars map[rune]ucdChar, excls []rune) []byte {
+	var (
+		src                 bytes.Buffer
+		decs, twos, classed []rune
+	)
+
+	for r, chr := range chars {
+		if chr.dec != nil {
+			decs = append(decs, r)
+		}
+
+		if len(chr.dec) == 2 && (slices.Contains(excls, r) || chars[chr.dec[0]].class != 0) {
+			twos = append(twos, r)
+		}
+
+		if chr.class != 0 {
+			classed = append(classed, r)
+		}
+	}
+
+	slices.Sort(decs)
+	slices.Sort(twos)
+	slices.Sort(classed)
+
+	fmt.Fprintf(&src, header, unicodeVersion)
+	src.WriteString(`
+/*
+The canonical decompositions of characters, except Hangul syllables which are decomposed algorithmically.
+A decomposition to a single character has zero as its second character.
+*/
+var decompositions = map[rune][2]rune{
+`)
+
+	const perDecLine = 4
+
+	for inx, r := range decs {
+		dec := chars[r].dec
+		if len(dec) == 1 {
+			fmt.Fprintf(&src, "0x%04X: {0x%04X, 0},", r, dec[0])
+		} else {
+			fmt.Fprintf(&src, "0x%04X: {0x%04X, 0x%04X},", r, dec[0], dec[1])
+		}
+
+		src.WriteString(separator(inx, len(decs), perDecLine))
+	}
+
+	src.WriteString(`}
+
+// The characters excluded from canonical composition, though they have a decomposition to two characters.
+var compositionExclusions = []rune{
+`)
+
+	const perExclLine = 10
+
+	for inx, r := range twos {
+		fmt.Fprintf(&src, "0x%04X,", r)
+		src.WriteString(separator(inx, len(twos), perExclLine))
+	}
+
+	src.WriteString(`}
+
+// The canonical combining classes of characters, as ranges; any other character has class zero.
+var combiningClasses = []combiningClass{
+`)
+
+	const perClassLine = 4
+
+	var ranges [][2]rune // of the first and last characters
+
+	for _, r := range classed {
+		last := len(ranges) - 1
+		if 0 <= last && ranges[last][1] == r-1 && chars[r].class == chars[ranges[last][0]].class {
+			ranges[last][1] = r
+		} else {
+			ranges = append(ranges, [2]rune{r, r})
+		}
+	}
+
+	for inx, rng := range ranges {
+		fmt.Fprintf(&src, "{0x%04X, 0x%04X, %v},", rng[0], rng[1], chars[rng[0]].class)
+		src.WriteString(separator(inx, len(ranges), perClassLine))
+	}
+
+	src.WriteString("}\n")
+
+	return src.Bytes()
+}
+
+// Separator returns what follows the indexed of n elements written perLine to a line, a space or a line break.
+func separator(inx, n, perLine int) string {
+	if (inx+1)%perLine == 0 || inx == n-1 {
+		return "\n"
+	}
+
+	return " "
+}
@@ -0,0 +1,111 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"slices"
+)
+
+/*
+A history holds the largest debit and credit of each account, by its name,
+so an amount that is a large outlier, such as from a misconfigured decimal separator, can be warned about.
+It is loaded from the database, if any, and grows with the transactions translated.
+*/
+type history map[string]extremes
+
+// Extremes are the largest debit, which is negative or zero, and largest credit of an account.
+type extremes struct {
+	debit, credit float64
+}
+
+/*
+LoadHistory returns the history of the transactions in the table of the database and nil.
+If the table does not exist, the history is empty.
+If loadHistory fails to open or query the database, it returns an error.
+*/
+func loadHistory(driver, dsn string) (history, error) {
+	if !slices.Contains(sql.Drivers(), driver) {
+		return nil, fmt.Errorf("%w: %v", errDBDriver, driver)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open: %w", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(dbCreateQuery)
+	if err != nil {
+		return nil, fmt.Errorf("sql.DB.Exec: %w", err)
+	}
+
+	rows, err := db.Query("SELECT thisacct, MIN(amount), MAX(amount) FROM " + dbTable + " GROUP BY thisacct")
+	if err != nil {
+		return nil, fmt.Errorf("sql.DB.Query: %w", err)
+	}
+	defer rows.Close()
+
+	hst := make(history)
+
+	for rows.Next() {
+		var (
+			acct        string
+			least, most float64
+		)
+
+		err = rows.Scan(&acct, &least, &most)
+		if err != nil {
+			return nil, fmt.Errorf("sql.Rows.Scan: %w", err)
+		}
+
+		hst[acct] = extremes{debit: min(least, 0), credit: max(most, 0)}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("sql.Rows.Next: %w", err)
+	}
+
+	return hst, nil
+}
+
+/*
+Check writes a warning to the writer if the amount of the transaction on the line is more than factor times
+the largest prior debit, or credit, of its account, then adds the amount to the history.
+An account with no prior debit, or credit, is not warned about.
+*/
+func (hst history) check(writer io.Writer, trn *transact, factor float64, lineN int) {
+	ext := hst[trn.thisAcct]
+
+	switch {
+	case trn.amount < 0 && ext.debit < 0 && trn.amount < factor*ext.debit:
+		fmt.Fprintf(writer, "%v: warning: amount %v on line %v is more than %v times the largest debit %v "+
+			"of account %v, check the decimal separator\n", pgmName, trn.amount, lineN, factor, ext.debit, trn.thisAcct)
+	case 0 < trn.amount && 0 < ext.credit && factor*ext.credit < trn.amount:
+		fmt.Fprintf(writer, "%v: warning: amount %v on line %v is more than %v times the largest credit %v "+
+			"of account %v, check the decimal separator\n", pgmName, trn.amount, lineN, factor, ext.credit, trn.thisAcct)
+	}
+
+	ext.debit, ext.credit = min(ext.debit, trn.amount), max(ext.credit, trn.amount)
+	hst[trn.thisAcct] = ext
+}
@@ -0,0 +1,295 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+/*
+A parquetColumn is a column of a Parquet file, whose values are encoded plainly.
+Columns are required, so have no definition or repetition levels.
+*/
+type parquetColumn struct {
+	name      string
+	physical  int32 // Parquet type
+	converted int32 // Parquet converted type
+	values    bytes.Buffer
+}
+
+/*
+A thriftWriter writes structs in the Thrift compact protocol, which Parquet uses for its metadata.
+Fields must be written in order of their IDs, and each struct ended.
+*/
+type thriftWriter struct {
+	buf     bytes.Buffer
+	fieldID int16   // of the last field written in the current struct
+	lastIDs []int16 // of the last field written in each enclosing struct
+}
+
+// The Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// The Parquet constants used.
+const (
+	parquetMagic        = "PAR1"
+	parquetInt32        = 1  // type
+	parquetInt64        = 2  // type
+	parquetByteArray    = 6  // type
+	parquetUTF8         = 0  // converted type
+	parquetDecimal      = 5  // converted type
+	parquetDate         = 6  // converted type
+	parquetRequired     = 0  // repetition type
+	parquetPlain        = 0  // encoding
+	parquetRLE          = 3  // encoding, of levels
+	parquetUncompressed = 0  // compression codec
+	parquetDataPage     = 0  // page type
+	parquetPrecision    = 18 // of decimal amounts
+	parquetScale        = 4  // of decimal amounts, the most minor units of a currency, see currencyExponents
+)
+
+/*
+WriteParquet writes the transactions to the writer as a Parquet file and returns nil.
+The file has one row group with a column for each field of the standard format, then each extra field.
+Dates are dates, amounts are decimals of scale parquetScale, and the other fields are strings.
+If writeParquet fails to write, it returns an error.
+*/
+func writeParquet(writer io.Writer, trns []transact, extraNames []string) error {
+	names := append([]string{"date", "thisacct", "otheracct", "memo", "amount", "currency"}, extraNames...)
+	cols := make([]*parquetColumn, len(names))
+
+	for inx, name := range names {
+		cols[inx] = &parquetColumn{name: name, physical: parquetByteArray, converted: parquetUTF8}
+	}
+
+	const dateI, amountI = 0, 4
+
+	cols[dateI].physical, cols[dateI].converted = parquetInt32, parquetDate
+	cols[amountI].physical, cols[amountI].converted = parquetInt64, parquetDecimal
+
+	const secondsPerDay = 24 * 60 * 60
+
+	for _, trn := range trns {
+		strs := append([]string{"", trn.thisAcct, trn.otherAcct, trn.memo, "", trn.currency}, trn.extras...)
+
+		for inx, col := range cols {
+			switch col.physical {
+			case parquetInt32:
+				date, _ := time.Parse(time.DateOnly, trn.date)
+				col.values.Write(binary.LittleEndian.AppendUint32(nil, uint32(date.Unix()/secondsPerDay)))
+			case parquetInt64:
+				units := int64(math.Round(trn.amount * math.Pow10(parquetScale)))
+				col.values.Write(binary.LittleEndian.AppendUint64(nil, uint64(units)))
+			default:
+				col.values.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(strs[inx]))))
+				col.values.WriteString(strs[inx])
+			}
+		}
+	}
+
+	file := bytes.NewBufferString(parquetMagic)
+	offsets := make([]int64, len(cols))
+
+	for inx, col := range cols {
+		offsets[inx] = int64(file.Len())
+
+		var hdr thriftWriter
+
+		hdr.i32(1, parquetDataPage)
+		hdr.i32(2, int32(col.values.Len())) // uncompressed size
+		hdr.i32(3, int32(col.values.Len())) // compressed size
+		hdr.beginStruct(5)                  // data page header
+		hdr.i32(1, int32(len(trns)))
+		hdr.i32(2, parquetPlain)
+		hdr.i32(3, parquetRLE)
+		hdr.i32(4, parquetRLE)
+		hdr.endStruct()
+		hdr.endStruct()
+
+		file.Write(hdr.buf.Bytes())
+		file.Write(col.values.Bytes())
+	}
+
+	meta := parquetMetadata(cols, offsets, int64(file.Len()), int64(len(trns)))
+
+	file.Write(meta)
+	file.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(meta))))
+	file.WriteString(parquetMagic)
+
+	_, err := writer.Write(file.Bytes())
+
+	return err
+}
+
+/*
+ParquetMetadata returns the file metadata of a Parquet file with the columns, starting at the offsets,
+the end of the last column, and the number of rows.
+*/
+func parquetMetadata(cols []*parquetColumn, offsets []int64, end, nRows int64) []byte {
+	var meta thriftWriter
+
+	meta.i32(1, 1) // version
+	meta.list(2, thriftStruct, len(cols)+1)
+	meta.beginElem() // schema root
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(cols)))
+	meta.endStruct()
+
+	for _, col := range cols {
+		meta.beginElem()
+		meta.i32(1, col.physical)
+		meta.i32(3, parquetRequired)
+		meta.binary(4, col.name)
+		meta.i32(6, col.converted)
+
+		if col.converted == parquetDecimal {
+			meta.i32(7, parquetScale)
+			meta.i32(8, parquetPrecision)
+		}
+
+		meta.endStruct()
+	}
+
+	meta.i64(3, nRows)
+	meta.list(4, thriftStruct, 1)
+	meta.beginElem() // row group
+	meta.list(1, thriftStruct, len(cols))
+
+	for inx, col := range cols {
+		size := end - offsets[inx]
+		if inx+1 < len(offsets) {
+			size = offsets[inx+1] - offsets[inx]
+		}
+
+		meta.beginElem() // column chunk
+		meta.i64(2, offsets[inx])
+		meta.beginStruct(3) // column metadata
+		meta.i32(1, col.physical)
+		meta.list(2, thriftI32, 1)
+		meta.varint(zigzag(parquetPlain))
+		meta.list(3, thriftBinary, 1)
+		meta.varint(uint64(len(col.name)))
+		meta.buf.WriteString(col.name)
+		meta.i32(4, parquetUncompressed)
+		meta.i64(5, nRows)
+		meta.i64(6, size)
+		meta.i64(7, size)
+		meta.i64(9, offsets[inx])
+		meta.endStruct()
+		meta.endStruct()
+	}
+
+	meta.i64(2, end-int64(len(parquetMagic))) // total byte size
+	meta.i64(3, nRows)
+	meta.endStruct()
+	meta.binary(6, pgmName) // created by
+	meta.endStruct()
+
+	return meta.buf.Bytes()
+}
+
+// Zigzag returns the integer zigzag encoded, so small negative integers are small.
+func zigzag(val int64) uint64 {
+	const signShift = 63
+
+	return uint64(val<<1) ^ uint64(val>>signShift)
+}
+
+// BeginElem begins a struct that is an element of a list.
+func (twr *thriftWriter) beginElem() {
+	twr.lastIDs = append(twr.lastIDs, twr.fieldID)
+	twr.fieldID = 0
+}
+
+// BeginStruct begins the struct field with the ID.
+func (twr *thriftWriter) beginStruct(id int16) {
+	twr.field(id, thriftStruct)
+	twr.beginElem()
+}
+
+// Binary writes the binary, or string, field with the ID.
+func (twr *thriftWriter) binary(id int16, str string) {
+	twr.field(id, thriftBinary)
+	twr.varint(uint64(len(str)))
+	twr.buf.WriteString(str)
+}
+
+// EndStruct ends the current struct.
+func (twr *thriftWriter) endStruct() {
+	twr.buf.WriteByte(0) // stop
+
+	if last := len(twr.lastIDs) - 1; 0 <= last {
+		twr.fieldID, twr.lastIDs = twr.lastIDs[last], twr.lastIDs[:last]
+	}
+}
+
+// Field writes the header of the field with the ID and type.
+func (twr *thriftWriter) field(id int16, typ byte) {
+	const maxDelta = 15
+
+	if delta := id - twr.fieldID; 0 < delta && delta <= maxDelta {
+		twr.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		twr.buf.WriteByte(typ)
+		twr.varint(zigzag(int64(id)))
+	}
+
+	twr.fieldID = id
+}
+
+// I32 writes the 32-bit integer field with the ID.
+func (twr *thriftWriter) i32(id int16, val int32) {
+	twr.field(id, thriftI32)
+	twr.varint(zigzag(int64(val)))
+}
+
+// I64 writes the 64-bit integer field with the ID.
+func (twr *thriftWriter) i64(id int16, val int64) {
+	twr.field(id, thriftI64)
+	twr.varint(zigzag(val))
+}
+
+// List writes the header of the list field with the ID, and the type and number of its elements.
+func (twr *thriftWriter) list(id int16, elemType byte, size int) {
+	twr.field(id, thriftList)
+
+	const maxShortSize = 15
+
+	if size < maxShortSize {
+		twr.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		twr.buf.WriteByte(0xf0 | elemType)
+		twr.varint(uint64(size))
+	}
+}
+
+// Varint writes the unsigned integer in the variable length encoding of protocol buffers.
+func (twr *thriftWriter) varint(val uint64) {
+	twr.buf.Write(binary.AppendUvarint(nil, val))
+}
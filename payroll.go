@@ -0,0 +1,105 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var errPayColumns = errors.New("pay columns must be index=account pairs separated by commas " +
+	"e.g. \"4=Income:Salary,5=Expenses:Tax\"")
+
+/*
+ParsePayColumns returns the other accounts by field index in the string of index=account pairs and nil.
+If a pair is not valid or repeats an index, parsePayColumns returns an error.
+*/
+func parsePayColumns(str string) (map[uint8]string, error) {
+	cols := make(map[uint8]string)
+
+	for pair := range strings.SplitSeq(str, ",") {
+		inx, acct, ok := strings.Cut(pair, "=")
+
+		val, err := strconv.ParseUint(strings.TrimSpace(inx), 10, 8)
+		acct = strings.TrimSpace(acct)
+
+		if !ok || err != nil || val == 0 || acct == "" {
+			return nil, fmt.Errorf("%w: %q", errPayColumns, pair)
+		}
+
+		if _, found := cols[uint8(val)]; found {
+			return nil, fmt.Errorf("%w: %q", errIndexUnique, pair)
+		}
+
+		cols[uint8(val)] = nfc(acct)
+	}
+
+	return cols, nil
+}
+
+// IsPayOnly returns true if the amount of a transaction is the sum of its pay parts, as there is no amount field.
+func (cfg *config) isPayOnly() bool {
+	return cfg.amountI == 0 && cfg.creditI == 0 && cfg.quantityI == 0 && len(cfg.earnings)+len(cfg.deductions) != 0
+}
+
+/*
+SetPayParts sets the earnings and deductions parts of this payslip, from the fields, and returns nil.
+Earnings are credits and deductions are debits, whatever their signs, in field index order.
+If there is no amount field, the amount is the sum of the parts, else the rest of it is a part of its own.
+Parts whose field is empty string are left out.
+If it fails to parse a part, setPayParts returns an error.
+*/
+func (trn *transact) setPayParts(fields []string, cfg config) error {
+	const minus1 = -1.00
+
+	for _, cols := range []struct {
+		accts map[uint8]string
+		sign  float64
+	}{{cfg.earnings, 1}, {cfg.deductions, minus1}} {
+		for _, inx := range slices.Sorted(maps.Keys(cols.accts)) {
+			amt := cfg.dialect.number(extract(cfg.amountPattern, fields[inx]))
+			if amt == "" {
+				continue
+			}
+
+			val, err := parseMoney(amt, cfg.laxNumbers)
+			if err != nil {
+				return err
+			}
+
+			if cfg.minorUnits {
+				val /= math.Pow10(currencyExponent(trn.currency))
+			}
+
+			val = math.Abs(val) * cols.sign
+			trn.parts = append(trn.parts, part{amount: val, otherAcct: cols.accts[inx]})
+
+			if cfg.isPayOnly() {
+				trn.amount += val
+			}
+		}
+	}
+
+	return nil
+}
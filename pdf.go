@@ -0,0 +1,618 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A pdfItem is a piece of text on a PDF page, at its position in points from the bottom left.
+type pdfItem struct {
+	text       string
+	x, y, endX float64
+}
+
+// A pdfMatrix is a PDF transformation matrix [a b c d e f].
+type pdfMatrix [6]float64
+
+/*
+A pdfState is the state of the PDF content stream interpreter.
+Only the state needed to position text is kept.
+*/
+type pdfState struct {
+	ctm, tlm, tm      pdfMatrix
+	ctms              []pdfMatrix // saved by the q operator
+	fontSize, leading float64
+	items             []pdfItem
+}
+
+// The constants of laying out PDF text in lines.
+const (
+	pdfCharWidth   = 0.5  // estimated width of a character, as a fraction of the font size
+	pdfColumnGap   = 1.5  // gap between items, in characters, that separates table columns
+	pdfLineDiff    = 0.3  // difference in y of items on a line, as a fraction of the font size
+	pdfSpaceAdjust = -200 // TJ adjustment, in thousandths of the font size, wide enough to be a space
+)
+
+var (
+	errLineGroups  = errors.New("line pattern must have a group for each field, see nfields")
+	errLinePattern = errors.New("line pattern is needed to translate PDF statements, see linepattern")
+	errPDF         = errors.New("PDF statement has no text, it may be scanned or its text encrypted")
+	errPDFFont     = errors.New("PDF statement has a font whose encoding is not supported, " +
+		"such as a CID or embedded font, so its text cannot be read")
+)
+
+// StreamPattern matches the start of a PDF stream, with the dictionary of the stream.
+var streamPattern = regexp.MustCompile(`(?s)<<((?:[^<>]|<[^<]|>[^>]|<<(?:[^<>]|<[^<]|>[^>])*>>)*)>>\s*stream\r?\n`)
+
+var pdfIdentity = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+// The patterns of PDF objects and fonts, see pdfFont.
+var (
+	pdfObjPattern        = regexp.MustCompile(`(?s)(\d+)\s+\d+\s+obj\b(.*?)\bendobj`)
+	pdfFontPattern       = regexp.MustCompile(`/Type\s*/Font\b`)
+	pdfCIDPattern        = regexp.MustCompile(`/Subtype\s*/(?:Type0|Type3|CIDFontType[02])\b|/Identity-[HV]\b`)
+	pdfBaseFontPattern   = regexp.MustCompile(`/BaseFont\s*/([^\s/<>\[\]()]+)`)
+	pdfDescriptorPattern = regexp.MustCompile(`/FontDescriptor\s+(\d+)\s+\d+\s+R`)
+	pdfEncodingPattern   = regexp.MustCompile(`/Encoding\s*/(?:WinAnsi|Standard|MacRoman|PDFDoc)Encoding\b`)
+	pdfFontFilePattern   = regexp.MustCompile(`/FontFile[23]?\b`)
+)
+
+/*
+PdfLines returns the lines of text in the PDF document and nil.
+Text on each page is laid out in lines from top to bottom and left to right,
+with columns of tables separated by two or more spaces.
+Only unencrypted documents with FlateDecode or uncompressed content streams are supported,
+and characters are decoded as Windows-1252, so fonts with other encodings are not, see pdfFont.
+If the document has such a font, or pdfLines finds no text, it returns an error.
+*/
+func pdfLines(data []byte) ([]string, error) {
+	var lines []string
+
+	objStms := [][]byte{data} // the document and its object streams, which can hold fonts
+
+	for _, loc := range streamPattern.FindAllSubmatchIndex(data, -1) {
+		dict, start := string(data[loc[2]:loc[3]]), loc[1]
+
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 || strings.Contains(dict, "/Subtype/Image") || strings.Contains(dict, "/Subtype /Image") {
+			continue
+		}
+
+		content := data[start : start+end]
+
+		if strings.Contains(dict, "/Filter") {
+			if !strings.Contains(dict, "/FlateDecode") {
+				continue
+			}
+
+			rdr, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+
+			content, err = io.ReadAll(rdr)
+			if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+				continue
+			}
+		}
+
+		if strings.Contains(dict, "/ObjStm") {
+			objStms = append(objStms, content)
+
+			continue
+		}
+
+		var pst pdfState
+
+		pst.interpret(content)
+		lines = append(lines, pst.lines()...)
+	}
+
+	if font := pdfFont(objStms); font != "" {
+		return nil, fmt.Errorf("%w: %v", errPDFFont, font)
+	}
+
+	if len(lines) == 0 {
+		return nil, errPDF
+	}
+
+	return lines, nil
+}
+
+/*
+PdfFont returns the name of the first font in the PDF document, or its object streams, whose text
+cannot be decoded as Windows-1252, or else empty string.
+Such fonts are composite (CID) or Type 3 fonts, and embedded fonts without a standard encoding,
+whose character codes are particular to the subset of glyphs embedded.
+Embedded fonts are only found where their descriptors are not in object streams.
+*/
+func pdfFont(objStms [][]byte) string {
+	matches := pdfObjPattern.FindAllSubmatch(objStms[0], -1)
+
+	objs := make(map[string][]byte, len(matches)) // the bodies of the objects, by number
+	for _, match := range matches {
+		objs[string(match[1])] = match[2]
+	}
+
+	// fontName returns the base font name in the font dictionary, or else "unnamed".
+	fontName := func(dict []byte) string {
+		if match := pdfBaseFontPattern.FindSubmatch(dict); match != nil {
+			return string(match[1])
+		}
+
+		return "unnamed"
+	}
+
+	for _, objStm := range objStms {
+		if loc := pdfCIDPattern.FindIndex(objStm); loc != nil {
+			start := max(0, bytes.LastIndex(objStm[:loc[0]], []byte("<<")))
+
+			return fontName(objStm[start:]) + " (composite or Type 3)"
+		}
+	}
+
+	for _, obj := range matches {
+		body := obj[2]
+		if !pdfFontPattern.Match(body) || pdfEncodingPattern.Match(body) {
+			continue
+		}
+
+		match := pdfDescriptorPattern.FindSubmatch(body)
+		if match != nil && pdfFontFilePattern.Match(objs[string(match[1])]) {
+			return fontName(body) + " (embedded)"
+		}
+	}
+
+	return ""
+}
+
+// PdfDecode returns the text of the bytes of a PDF string, decoded as Windows-1252.
+func pdfDecode(raw []byte) string {
+	const c1Min, c1Max = 0x80, 0x9f
+
+	runes := make([]rune, len(raw))
+	for inx, b := range raw {
+		runes[inx] = rune(b)
+		if c1Min <= b && b <= c1Max {
+			runes[inx] = cp1252[b-c1Min]
+		}
+	}
+
+	return string(runes)
+}
+
+// Multiply returns the product of the matrices.
+func (mtx pdfMatrix) multiply(by pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		mtx[0]*by[0] + mtx[1]*by[2], mtx[0]*by[1] + mtx[1]*by[3],
+		mtx[2]*by[0] + mtx[3]*by[2], mtx[2]*by[1] + mtx[3]*by[3],
+		mtx[4]*by[0] + mtx[5]*by[2] + by[4], mtx[4]*by[1] + mtx[5]*by[3] + by[5],
+	}
+}
+
+/*
+Interpret interprets the PDF content stream, collecting the text it shows as items.
+Operands are strings, numbers, names and arrays; dictionaries and inline images are skipped.
+*/
+func (pst *pdfState) interpret(content []byte) {
+	pst.ctm, pst.tlm, pst.tm = pdfIdentity, pdfIdentity, pdfIdentity
+
+	var (
+		operands []any
+		array    []any
+		inArray  bool
+	)
+
+	for inx := 0; inx < len(content); {
+		tok, raw, next := pdfToken(content, inx)
+		inx = next
+
+		switch {
+		case tok == "":
+			continue
+		case tok == "[":
+			inArray, array = true, nil
+		case tok == "]":
+			inArray = false
+			operands = append(operands, array)
+		case raw != nil:
+			if inArray {
+				array = append(array, raw)
+			} else {
+				operands = append(operands, raw)
+			}
+		case tok[0] == '/' || tok[0] == '-' || tok[0] == '+' || tok[0] == '.' || ('0' <= tok[0] && tok[0] <= '9'):
+			val, err := strconv.ParseFloat(tok, 64)
+
+			var opd any = tok
+			if err == nil {
+				opd = val
+			}
+
+			if inArray {
+				array = append(array, opd)
+			} else {
+				operands = append(operands, opd)
+			}
+		default:
+			if tok == "BI" { // inline image data is not text
+				end := bytes.Index(content[inx:], []byte("EI"))
+				if end < 0 {
+					return
+				}
+
+				inx += end + len("EI")
+			}
+
+			pst.operate(tok, operands)
+			operands = nil
+		}
+	}
+}
+
+// Lines returns the text items laid out in lines, from top to bottom and left to right.
+func (pst *pdfState) lines() []string {
+	items := slices.Clone(pst.items)
+	slices.SortStableFunc(items, func(a, b pdfItem) int {
+		return -cmpFloat(a.y, b.y)
+	})
+
+	var (
+		lines []string
+		line  []pdfItem
+	)
+
+	flush := func() {
+		if len(line) == 0 {
+			return
+		}
+
+		slices.SortStableFunc(line, func(a, b pdfItem) int { return cmpFloat(a.x, b.x) })
+
+		var sbr strings.Builder
+
+		for inx, item := range line {
+			if 0 < inx {
+				gap := item.x - line[inx-1].endX
+				charWidth := (item.endX - item.x) / math.Max(1, float64(len([]rune(item.text))))
+
+				switch {
+				case pdfColumnGap*charWidth < gap:
+					sbr.WriteString("  ")
+				case charWidth/2 < gap:
+					sbr.WriteString(" ")
+				}
+			}
+
+			sbr.WriteString(item.text)
+		}
+
+		lines = append(lines, strings.TrimSpace(sbr.String()))
+		line = line[:0]
+	}
+
+	for _, item := range items {
+		if 0 < len(line) && pdfLineDiff*pst.fontSizeOr(1) < math.Abs(line[0].y-item.y) {
+			flush()
+		}
+
+		line = append(line, item)
+	}
+
+	flush()
+
+	return lines
+}
+
+// FontSizeOr returns the font size, or the default if it is not set.
+func (pst *pdfState) fontSizeOr(dflt float64) float64 {
+	if pst.fontSize == 0 {
+		return dflt
+	}
+
+	return math.Abs(pst.fontSize)
+}
+
+// CmpFloat compares floats a and b.
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case b < a:
+		return 1
+	default:
+		return 0
+	}
+}
+
+/*
+Operate applies the PDF operator to the operands.
+Operators that do not position or show text are ignored.
+*/
+func (pst *pdfState) operate(opr string, opds []any) {
+	nums := make([]float64, 0, len(opds))
+	for _, opd := range opds {
+		if num, ok := opd.(float64); ok {
+			nums = append(nums, num)
+		}
+	}
+
+	const nMatrix = 6
+
+	switch opr {
+	case "q":
+		pst.ctms = append(pst.ctms, pst.ctm)
+	case "Q":
+		if 0 < len(pst.ctms) {
+			pst.ctm, pst.ctms = pst.ctms[len(pst.ctms)-1], pst.ctms[:len(pst.ctms)-1]
+		}
+	case "cm":
+		if len(nums) == nMatrix {
+			pst.ctm = pdfMatrix(nums).multiply(pst.ctm)
+		}
+	case "BT":
+		pst.tlm, pst.tm = pdfIdentity, pdfIdentity
+	case "Tf":
+		if 0 < len(nums) {
+			pst.fontSize = nums[len(nums)-1]
+		}
+	case "TL":
+		if len(nums) == 1 {
+			pst.leading = nums[0]
+		}
+	case "Tm":
+		if len(nums) == nMatrix {
+			pst.tlm = pdfMatrix(nums)
+			pst.tm = pst.tlm
+		}
+	case "Td", "TD":
+		if len(nums) == 2 {
+			if opr == "TD" {
+				pst.leading = -nums[1]
+			}
+
+			pst.moveLine(nums[0], nums[1])
+		}
+	case "T*":
+		pst.moveLine(0, -pst.leading)
+	case "Tj", "'", "\"":
+		if opr != "Tj" {
+			pst.moveLine(0, -pst.leading)
+		}
+
+		if 0 < len(opds) {
+			if raw, ok := opds[len(opds)-1].([]byte); ok {
+				pst.show(pdfDecode(raw), 0)
+			}
+		}
+	case "TJ":
+		if 0 < len(opds) {
+			pst.showArray(opds[len(opds)-1])
+		}
+	}
+}
+
+// MoveLine moves to the start of the next line, offset from the start of the current line.
+func (pst *pdfState) moveLine(tx, ty float64) {
+	pst.tlm = pdfMatrix{1, 0, 0, 1, tx, ty}.multiply(pst.tlm)
+	pst.tm = pst.tlm
+}
+
+// Show adds the text as an item at the current position, then advances the position past it and the adjustment.
+func (pst *pdfState) show(text string, adjust float64) {
+	pos := pst.tm.multiply(pst.ctm)
+	width := float64(len([]rune(text)))*pdfCharWidth*pst.fontSizeOr(1) - adjust
+
+	pst.tm = pdfMatrix{1, 0, 0, 1, width, 0}.multiply(pst.tm)
+	end := pst.tm.multiply(pst.ctm)
+
+	if strings.TrimSpace(text) != "" {
+		pst.items = append(pst.items, pdfItem{text: text, x: pos[4], y: pos[5], endX: end[4]})
+	}
+}
+
+/*
+ShowArray shows the strings in the TJ array as one item.
+Large negative adjustments between strings are spaces.
+*/
+func (pst *pdfState) showArray(opd any) {
+	arr, ok := opd.([]any)
+	if !ok {
+		return
+	}
+
+	const thousandths = 1000
+
+	var (
+		sbr    strings.Builder
+		adjust float64
+	)
+
+	for _, elm := range arr {
+		switch val := elm.(type) {
+		case []byte:
+			sbr.WriteString(pdfDecode(val))
+		case float64:
+			if val < pdfSpaceAdjust {
+				sbr.WriteString(" ")
+			}
+
+			adjust += val / thousandths * pst.fontSizeOr(1)
+		}
+	}
+
+	pst.show(sbr.String(), adjust)
+}
+
+/*
+TranslatePDF translates financial transactions in the text of the PDF statement and returns nil.
+Each line of text that matches the line pattern is a record, whose fields are the values of the pattern's groups.
+See translateStatement.
+If it fails to extract text from the statement, translatePDF returns an error.
+*/
+func (tlr *translator) translatePDF(data []byte) error {
+	if tlr.cfg.linePattern == nil {
+		return errLinePattern
+	}
+
+	lines, err := pdfLines(data)
+	if err != nil {
+		return err
+	}
+
+	return tlr.translateStatement(strings.NewReader(strings.Join(lines, "\n")))
+}
+
+/*
+PdfToken returns the PDF token starting at or after the index of the content, and the index after it.
+If the token is a string, its bytes are also returned.
+Delimiters of dictionaries are returned as empty string tokens.
+*/
+func pdfToken(content []byte, inx int) (string, []byte, int) {
+	for inx < len(content) && isPDFSpace(content[inx]) {
+		inx++
+	}
+
+	if len(content) <= inx {
+		return "", nil, inx
+	}
+
+	switch chr := content[inx]; {
+	case chr == '%':
+		end := bytes.IndexAny(content[inx:], "\r\n")
+		if end < 0 {
+			return "", nil, len(content)
+		}
+
+		return "", nil, inx + end
+	case chr == '(':
+		return pdfLiteral(content, inx+1)
+	case chr == '<' && inx+1 < len(content) && content[inx+1] == '<',
+		chr == '>' && inx+1 < len(content) && content[inx+1] == '>':
+		return "", nil, inx + 2
+	case chr == '<':
+		end := bytes.IndexByte(content[inx:], '>')
+		if end < 0 {
+			return "", nil, len(content)
+		}
+
+		hex := strings.Map(func(r rune) rune {
+			if isPDFSpace(byte(r)) {
+				return -1
+			}
+
+			return r
+		}, string(content[inx+1:inx+end]))
+		if len(hex)%2 == 1 {
+			hex += "0"
+		}
+
+		raw := make([]byte, 0, len(hex)/2)
+
+		for jnx := 0; jnx < len(hex); jnx += 2 {
+			val, err := strconv.ParseUint(hex[jnx:jnx+2], 16, 8)
+			if err == nil {
+				raw = append(raw, byte(val))
+			}
+		}
+
+		return "hex", raw, inx + end + 1
+	case chr == '[' || chr == ']' || chr == '{' || chr == '}':
+		return string(chr), nil, inx + 1
+	default:
+		end := inx + 1
+		for end < len(content) && !isPDFSpace(content[end]) && !strings.ContainsRune("()<>[]{}/%", rune(content[end])) {
+			end++
+		}
+
+		return string(content[inx:end]), nil, end
+	}
+}
+
+// IsPDFSpace returns true if the byte is PDF white space.
+func isPDFSpace(chr byte) bool {
+	return strings.IndexByte("\x00\t\n\f\r ", chr) != -1
+}
+
+/*
+PdfLiteral returns a PDF literal string token, from the index after its opening parenthesis,
+its bytes with escapes replaced, and the index after its closing parenthesis.
+*/
+func pdfLiteral(content []byte, inx int) (string, []byte, int) {
+	raw, depth := []byte{}, 1
+
+	escapes := map[byte]byte{'n': '\n', 'r': '\r', 't': '\t', 'b': '\b', 'f': '\f', '(': '(', ')': ')', '\\': '\\'}
+
+	for ; inx < len(content); inx++ {
+		chr := content[inx]
+
+		switch chr {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return "literal", raw, inx + 1
+			}
+		case '\\':
+			inx++
+			if len(content) <= inx {
+				return "literal", raw, inx
+			}
+
+			chr = content[inx]
+			if esc, ok := escapes[chr]; ok {
+				raw = append(raw, esc)
+
+				continue
+			}
+
+			if '0' <= chr && chr <= '7' {
+				end := inx
+				for end < len(content) && end < inx+3 && '0' <= content[end] && content[end] <= '7' {
+					end++
+				}
+
+				val, _ := strconv.ParseUint(string(content[inx:end]), 8, 8)
+				raw = append(raw, byte(val))
+				inx = end - 1
+
+				continue
+			}
+
+			if chr == '\r' || chr == '\n' {
+				continue // a line continuation
+			}
+		}
+
+		raw = append(raw, chr)
+	}
+
+	return "literal", raw, inx
+}
@@ -0,0 +1,254 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	presetFlag = "preset"
+	presetList = "list" // the preset that lists the presets
+)
+
+/*
+A preset is the configuration of the statement format of a bank, or a common one, selected by its name,
+so its field indexes need not be remembered.
+A preset with a line pattern is a template of a bank's PDF statements, see translatePDF.
+It is a configuration, which is checked as one, see presetFlags.
+*/
+type preset struct {
+	name, description string
+	cfg               config
+}
+
+// Presets are the built-in presets, by name.
+var presets = []preset{
+	{
+		name: "kiwibank-full", description: "Kiwibank full CSV export, with account numbers and running balances",
+		cfg: config{
+			nFields: 16, thisAcctI: 1, dateI: 2, memoI: 3, otherAcctI: 12, amountI: 15, balanceI: 16,
+			dateFormat: "02-01-2006", currency: "NZD", firstLine: 2,
+		},
+	},
+	{
+		name: "dmy-debit-credit", description: "date day/month/year, memo, debit, credit and balance, no header",
+		cfg: config{nFields: 5, dateI: 1, memoI: 2, debitI: 3, creditI: 4, balanceI: 5, dateFormat: "02/01/2006"},
+	},
+	{
+		name: "eu-semicolon", description: "date day.month.year, memo and amount, \";\" delimited with \",\" decimals",
+		cfg: config{
+			nFields: 3, dateI: 1, memoI: 2, amountI: 3, dateFormat: "02.01.2006", firstLine: 2,
+			dialect: dialect{decimal: ',', delimiter: ';'},
+		},
+	},
+	{
+		name: "apple-card-pdf", description: "Apple Card PDF statement, its payments, credits and purchases",
+		cfg: config{
+			nFields: 4, dateI: 1, memoI: 2, creditI: 3, debitI: 4, dateFormat: "01/02/2006", currency: "USD",
+			linePattern: regexp.MustCompile(`^(?P<date>\d\d/\d\d/\d{4})  (?P<memo>.+?)(?:  \d+%  \$[\d,]+\.\d\d)?  ` +
+				`(?:-\$(?P<credit>[\d,]+\.\d\d)|\$(?P<debit>[\d,]+\.\d\d))$`),
+		},
+	},
+	{
+		name: "dmy-balance-pdf", description: "PDF statement lines of date day/month/year, memo, amount and balance",
+		cfg: config{
+			nFields: 4, dateI: 1, memoI: 2, amountI: 3, balanceI: 4, dateFormat: "02/01/2006",
+			linePattern: regexp.MustCompile(`^(?P<date>\d\d/\d\d/\d{4})  (?P<memo>.+?)  ` +
+				`(?P<amount>-?[\d,]+\.\d\d)  (?P<balance>-?[\d,]+\.\d\d)$`),
+		},
+	},
+}
+
+// PresetExts are the extensions of user preset files: files of flags, see readProfile, or configuration files.
+var presetExts = []string{profileExt, ".toml", ".yaml", ".yml"}
+
+var (
+	errPreset         = errors.New("preset is not known, see \"-preset list\"")
+	errPresetConflict = errors.New("user preset is defined by more than one file")
+)
+
+/*
+UserPresetDir returns the directory of the user's presets, "presets" in the user's cas2trn configuration directory,
+or empty string if there is none.
+*/
+func userPresetDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, pgmName, "presets")
+}
+
+/*
+LoadUserPresets returns the names of the files of the user presets in the directory, by preset name, and nil.
+A preset is named by its file name without its extension, see presetExts.
+If the directory does not exist, there are no user presets.
+If loadUserPresets fails to read the directory, or two files define the same preset, it returns an error.
+*/
+func loadUserPresets(dir string) (map[string]string, error) {
+	users := make(map[string]string)
+	if dir == "" {
+		return users, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return users, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("os.ReadDir: %w", err)
+	}
+
+	for _, ent := range entries {
+		ext := filepath.Ext(ent.Name())
+		if ent.IsDir() || !slices.Contains(presetExts, ext) {
+			continue
+		}
+
+		name, file := strings.TrimSuffix(ent.Name(), ext), filepath.Join(dir, ent.Name())
+		if other, ok := users[name]; ok {
+			return nil, fmt.Errorf("%w: %v is defined by %v and %v", errPresetConflict, name, other, file)
+		}
+
+		users[name] = file
+	}
+
+	return users, nil
+}
+
+/*
+FindPreset returns the flags of the named preset, a user preset in the directory or else a built-in one, and nil.
+A user preset overrides a built-in one of the same name, with a warning to standard error.
+If there is no preset of that name, or findPreset fails to read a user preset, it returns an error.
+*/
+func findPreset(dir, name string) ([]string, error) {
+	users, err := loadUserPresets(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := slices.IndexFunc(presets, func(pst preset) bool { return pst.name == name })
+
+	if file, ok := users[name]; ok {
+		if idx >= 0 {
+			fmt.Fprintf(os.Stderr, "%v: warning: user preset %v in %v overrides the built-in preset\n",
+				pgmName, name, file)
+		}
+
+		if filepath.Ext(file) == profileExt {
+			return readProfile(file)
+		}
+
+		return readConfigFile(file)
+	}
+
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: %v", errPreset, name)
+	}
+
+	return presetFlags(presets[idx].cfg), nil
+}
+
+/*
+PresetFlags returns the flags that configure the statement format of the configuration,
+its number of fields, non-zero field indexes, date format, line pattern and so on, to be overridden by other flags.
+*/
+func presetFlags(cfg config) []string {
+	flags := []string{"-nfields=" + strconv.Itoa(int(cfg.nFields))}
+
+	for _, inx := range []struct {
+		name string
+		val  uint8
+	}{
+		{"amounti", cfg.amountI}, {"balancei", cfg.balanceI}, {"chequei", cfg.chequeI}, {"crediti", cfg.creditI},
+		{"currencyi", cfg.currencyI}, {"datei", cfg.dateI}, {"dci", cfg.dcI}, {"debiti", cfg.debitI},
+		{"memoi", cfg.memoI}, {"otheraccti", cfg.otherAcctI}, {"thisaccti", cfg.thisAcctI},
+	} {
+		if inx.val != 0 {
+			flags = append(flags, "-"+inx.name+"="+strconv.Itoa(int(inx.val)))
+		}
+	}
+
+	for _, str := range []struct{ name, val string }{
+		{"dateformat", cfg.dateFormat}, {"currency", cfg.currency}, {"thisacct", cfg.thisAcct},
+		{"decimal", runeString(cfg.dialect.decimal)}, {"delimiter", runeString(cfg.dialect.delimiter)},
+	} {
+		if str.val != "" {
+			flags = append(flags, "-"+str.name+"="+str.val)
+		}
+	}
+
+	if cfg.firstLine != 0 {
+		flags = append(flags, "-lines="+strconv.Itoa(int(cfg.firstLine))+"-")
+	}
+
+	if cfg.linePattern != nil {
+		flags = append(flags, "-linepattern="+cfg.linePattern.String())
+	}
+
+	return flags
+}
+
+// RuneString returns the rune as a string, or empty string if it is zero.
+func runeString(chr rune) string {
+	if chr == 0 {
+		return ""
+	}
+
+	return string(chr)
+}
+
+/*
+WritePresets writes the name and description of each built-in preset, then the name and file of each user preset
+in the directory, to the writer, and returns nil.
+User presets that override built-in ones are marked.
+If writePresets fails to read the user presets, it returns an error.
+*/
+func writePresets(writer io.Writer, dir string) error {
+	users, err := loadUserPresets(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, pst := range presets {
+		desc := pst.description
+		if file, ok := users[pst.name]; ok {
+			desc += ", overridden by user preset " + file
+		}
+
+		fmt.Fprintf(writer, "%-18v %v\n", pst.name, desc)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(users)) {
+		fmt.Fprintf(writer, "%-18v user preset %v\n", name, users[name])
+	}
+
+	return nil
+}
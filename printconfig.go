@@ -0,0 +1,117 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const saveConfigFlag = "saveconfig"
+
+// SecretFlags are the names of flags whose values are secrets, so they are not printed.
+var secretFlags = []string{"dropboxtoken", "gdrivetoken", "hmackey", "imappassword", "zippassword"}
+
+const redacted = "REDACTED"
+
+/*
+WriteConfig writes the effective value of each flag in the flag set, in TOML, to the writer.
+Values are those after defaults, environment variables, the keychain and flags are applied,
+so the output shows, and can be saved and shared as, exactly what a run uses.
+Secrets, and the password in a database data source name, are redacted.
+Flags that only modify others, such as set, config and preset, are not written as their effect is.
+*/
+func writeConfig(writer io.Writer, fset *flag.FlagSet) {
+	fmt.Fprintf(writer, "# effective configuration of %v\n", pgmName)
+
+	fset.VisitAll(func(flg *flag.Flag) {
+		getter, ok := flg.Value.(flag.Getter)
+		if !ok || slices.Contains([]string{configFlag, "help", presetFlag, "printconfig", profileFlag, saveConfigFlag,
+			"version"}, flg.Name) {
+			return
+		}
+
+		val := getter.Get()
+
+		if str, ok := val.(string); ok {
+			switch {
+			case str != "" && slices.Contains(secretFlags, flg.Name):
+				str = redacted
+			case flg.Name == "dbdsn":
+				if dsn, err := url.Parse(str); err == nil && dsn.User != nil {
+					str = dsn.Redacted()
+				}
+			}
+
+			val = tomlString(str)
+		}
+
+		fmt.Fprintf(writer, "%v = %v\n", flg.Name, val)
+	})
+}
+
+// TomlString returns the string as a TOML basic string, with quotes, backslashes and control characters escaped.
+func tomlString(str string) string {
+	var bldr strings.Builder
+
+	bldr.WriteByte('"')
+
+	for _, r := range str {
+		switch {
+		case r == '"' || r == '\\':
+			bldr.WriteRune('\\')
+			bldr.WriteRune(r)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&bldr, `\u%04x`, r)
+		default:
+			bldr.WriteRune(r)
+		}
+	}
+
+	bldr.WriteByte('"')
+
+	return bldr.String()
+}
+
+/*
+SaveConfig writes the effective value of each flag in the flag set, see writeConfig, to the named file
+and returns nil, so the configuration can be read again by the config flag.
+Redacted secrets are skipped when read, so they are set by environment variables or the keychain instead.
+If saveConfig fails to write the file, it returns an error.
+*/
+func saveConfig(name string, fset *flag.FlagSet) error {
+	var bldr strings.Builder
+
+	writeConfig(&bldr, fset)
+
+	const perm = 0o600 // as the data source name or other flags may be private
+
+	err := os.WriteFile(name, []byte(bldr.String()), perm)
+	if err != nil {
+		return fmt.Errorf("os.WriteFile: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,338 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	filePatternFlag   = "filepattern"
+	headerPatternFlag = "headerpattern"
+	profileFlag       = "profile"
+	setFlag           = "set"
+)
+
+/*
+A profileMatch is a profile in the profile directory with a file pattern,
+which configures the statement files whose names match it, or a header pattern,
+which configures the sections of standard input that start with a header row matching it.
+*/
+type profileMatch struct {
+	args                   []string // the flags in the profile
+	name                   string   // of the profile file
+	pattern, headerPattern *regexp.Regexp
+}
+
+var (
+	errNoHeader  = errors.New("no profile in profiledir has a headerpattern matching the first line of standard input")
+	errNoProfile = errors.New("no profile in profiledir matches statement file")
+	errSet       = errors.New("set must be a flag name and value e.g. \"thisacct=Assets:Joint\"")
+)
+
+/*
+PrependProfile returns the arguments with the flags of the configuration file, preset and profile named by
+the config, preset and profile flags, if any, before them, so flags in the arguments override those, and nil.
+The profile name can omit the profileExt extension.
+If prependProfile fails to read a file, it returns an error.
+*/
+func prependProfile(args []string) ([]string, error) {
+	var flags []string
+
+	for inx, arg := range args {
+		if arg == "--" || !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+
+		name, val, hasVal := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name != configFlag && name != presetFlag && name != profileFlag {
+			continue
+		}
+
+		if !hasVal {
+			if inx+1 >= len(args) {
+				break // leaves the missing value for the flag set to report
+			}
+
+			val = args[inx+1]
+		}
+
+		read := readConfigFile
+
+		switch {
+		case name == presetFlag && val == presetList:
+			continue // lists the presets, see parseConfig
+		case name == presetFlag:
+			read = func(name string) ([]string, error) { return findPreset(userPresetDir(), name) }
+		case name == profileFlag:
+			read = readProfile
+
+			if filepath.Ext(val) == "" {
+				val += profileExt
+			}
+		}
+
+		fileFlags, err := read(val)
+		if err != nil {
+			return nil, err
+		}
+
+		flags = append(flags, fileFlags...)
+	}
+
+	return append(flags, args...), nil
+}
+
+/*
+ApplySets sets the flags in the flag set to the values in the name=value pairs, see the set flag, and returns nil.
+Pairs are applied in order, after the other flags, so they override flags in a profile or the arguments.
+If a pair is malformed or names an unknown flag or a wrong value, applySets returns an error.
+*/
+func applySets(fset *flag.FlagSet, sets []string) error {
+	for _, set := range sets {
+		name, val, ok := strings.Cut(set, "=")
+		name = strings.TrimLeft(strings.TrimSpace(name), "-")
+
+		if !ok || name == "" || name == setFlag || name == profileFlag {
+			return fmt.Errorf("%w, got %q", errSet, set)
+		}
+
+		if current, ok := flagAliases[name]; ok {
+			name = current
+		}
+
+		err := fset.Set(name, val)
+		if err != nil {
+			return fmt.Errorf("flag.FlagSet.Set: %w", err)
+		}
+	}
+
+	return nil
+}
+
+/*
+LoadProfileMatches returns the profiles in the directory with a file or header pattern,
+see filePatternFlag and headerPatternFlag, in the order of their names, and nil.
+Profiles without either are ignored.
+If loadProfileMatches fails to read a profile or compile its pattern, it returns an error.
+*/
+func loadProfileMatches(dir string) ([]profileMatch, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+profileExt))
+	if err != nil {
+		return nil, fmt.Errorf("filepath.Glob: %w", err)
+	}
+
+	var matches []profileMatch
+
+	for _, name := range names {
+		flags, err := readProfile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		pfl := profileMatch{args: flags, name: name}
+
+		for _, flg := range flags {
+			var ptn **regexp.Regexp
+
+			key, val, _ := strings.Cut(strings.TrimLeft(flg, "-"), "=")
+
+			switch {
+			case val == "":
+				continue
+			case key == filePatternFlag:
+				ptn = &pfl.pattern
+			case key == headerPatternFlag:
+				ptn = &pfl.headerPattern
+			default:
+				continue
+			}
+
+			*ptn, err = regexp.Compile(val)
+			if err != nil {
+				return nil, fmt.Errorf("regexp.Compile: %w in %v", err, name)
+			}
+		}
+
+		if pfl.pattern != nil || pfl.headerPattern != nil {
+			matches = append(matches, pfl)
+		}
+	}
+
+	return matches, nil
+}
+
+/*
+MatchConfig returns the configuration of the statement file, from the first profile whose pattern matches
+the file's base name, overridden by the flags of this configuration, and nil.
+If no profile matches, or the configuration is not valid, matchConfig returns an error.
+*/
+func (cfg config) matchConfig(file string) (config, error) {
+	idx := slices.IndexFunc(cfg.profiles, func(pfl profileMatch) bool {
+		return pfl.pattern != nil && pfl.pattern.MatchString(filepath.Base(file))
+	})
+	if idx < 0 {
+		return config{}, fmt.Errorf("%w %v", errNoProfile, file)
+	}
+
+	return cfg.profileConfig(idx)
+}
+
+/*
+ProfileConfig returns the configuration of the indexed profile, overridden by the flags of this configuration,
+and nil.
+If the configuration is not valid, profileConfig returns an error.
+*/
+func (cfg config) profileConfig(idx int) (config, error) {
+	fset := flag.NewFlagSet(cfg.profiles[idx].name, flag.ContinueOnError)
+	fset.SetOutput(os.Stderr)
+
+	// clearing profiledir configures the file rather than matching again
+	args := slices.Concat(cfg.profiles[idx].args, cfg.args, []string{"-profiledir="})
+
+	return parseConfig(fset, args)
+}
+
+/*
+TranslateMatched translates financial transactions in the statement files, each configured by the profile
+that matches its name, see matchConfig, and returns nil.
+If no files are named, standard input is translated as statements concatenated, see translateSections.
+The transactions are written as configured by the first file's profile and the flags, see newTranslator,
+so output flags are best set as flags rather than in profiles.
+If it fails to configure, open or read a statement, translateMatched returns the first error.
+*/
+func translateMatched(cfg config, files []string) error {
+	if len(files) == 0 {
+		return translateSections(cfg, os.Stdin)
+	}
+
+	var (
+		err error
+		tlr *translator
+	)
+
+	for _, file := range expandGlobs(files) {
+		var fcfg config
+
+		fcfg, err = cfg.matchConfig(file)
+		if err != nil {
+			break
+		}
+
+		if tlr == nil {
+			tlr = newTranslator(fcfg)
+		}
+
+		tlr.cfg = fcfg
+
+		err = tlr.translateFiles([]string{file})
+		if err != nil {
+			break
+		}
+	}
+
+	if tlr == nil {
+		return err
+	}
+
+	return errors.Join(err, tlr.finish())
+}
+
+/*
+TranslateSections translates financial transactions in the statements concatenated in the reader,
+such as a download script writes to standard input, and returns nil.
+Each statement is a section that starts with a header row, a line that matches the header pattern of a profile,
+and is configured by the first profile, by name, that it matches, as if it were a file,
+so its line numbers start again at one.
+If the first line matches no profile, or it fails to configure or read a statement,
+translateSections returns the first error.
+*/
+func translateSections(cfg config, rdr io.Reader) error {
+	data, err := io.ReadAll(rdr)
+	if err != nil {
+		return fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	var (
+		tlr  *translator
+		bldr strings.Builder
+		idx  = -1 // of the profile configuring the section in bldr
+	)
+
+	// translate translates the section in bldr, if any.
+	translate := func() error {
+		if idx < 0 {
+			return nil
+		}
+
+		scfg, err := cfg.profileConfig(idx)
+		if err != nil {
+			return err
+		}
+
+		if tlr == nil {
+			tlr = newTranslator(scfg)
+		}
+
+		tlr.cfg = scfg
+
+		return tlr.translateStatement(strings.NewReader(bldr.String()))
+	}
+
+	for line := range strings.Lines(string(data)) {
+		hdx := slices.IndexFunc(cfg.profiles, func(pfl profileMatch) bool {
+			return pfl.headerPattern != nil && pfl.headerPattern.MatchString(strings.TrimRight(line, "\r\n"))
+		})
+
+		switch {
+		case 0 <= hdx:
+			err = translate()
+			idx = hdx
+
+			bldr.Reset()
+		case idx < 0:
+			err = errNoHeader
+		}
+
+		if err != nil {
+			break
+		}
+
+		bldr.WriteString(line)
+	}
+
+	if err == nil {
+		err = translate()
+	}
+
+	if tlr == nil {
+		return err
+	}
+
+	return errors.Join(err, tlr.finish())
+}
@@ -0,0 +1,371 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	reconcileCmd = "reconcile"
+	// MaxDateDiff is the largest number of days between matching transactions and journal entries.
+	maxDateDiff = 3
+)
+
+/*
+An entry represents a transaction in a ledger journal.
+Only the fields needed to reconcile it with an account statement are kept.
+*/
+type entry struct {
+	postings []posting // including one with an elided amount
+	date     time.Time
+	lineN    int // of the first line of the entry in the journal
+	matched  bool
+	payee    string
+}
+
+// A posting is the account and amount of a line of a journal entry.
+type posting struct {
+	account string
+	amount  float64
+}
+
+var (
+	errJournalAmount = errors.New("more than one posting amount is elided")
+	errJournalDate   = errors.New("date is not in a ledger format e.g. \"2006/01/02\"")
+	errReconcileArgs = errors.New("reconcile needs the names of a statement file and a journal file")
+)
+
+/*
+MatchEntries matches each transaction to an unmatched entry and returns the transactions that did not match.
+A transaction matches an entry if one of the entry's postings is to the transaction's this account,
+with the same signed amount, and their dates are at most maxDateDiff days apart.
+If more than one entry matches, the entry with the closest date then the most similar payee is chosen.
+MatchEntries assumes the dates of the transactions are in ISO 8601 format.
+*/
+func matchEntries(trns []transact, entries []entry) []transact {
+	var unmatched []transact
+
+	for _, trn := range trns {
+		date, _ := time.Parse(time.DateOnly, trn.date)
+		best, bestDiff, bestSim := -1, 0, 0.0
+
+		for inx := range entries {
+			ent := &entries[inx]
+			if ent.matched || !hasPosting(ent.postings, trn.thisAcct, trn.amount) {
+				continue
+			}
+
+			const hoursPerDay = 24
+
+			diff := int(math.Abs(date.Sub(ent.date).Hours() / hoursPerDay))
+			if maxDateDiff < diff {
+				continue
+			}
+
+			sim := similarity(trn.memo, ent.payee)
+			if best < 0 || diff < bestDiff || (diff == bestDiff && bestSim < sim) {
+				best, bestDiff, bestSim = inx, diff, sim
+			}
+		}
+
+		if best < 0 {
+			unmatched = append(unmatched, trn)
+		} else {
+			entries[best].matched = true
+		}
+	}
+
+	return unmatched
+}
+
+/*
+HasPosting returns true if one of the postings is to the account with the amount.
+Amounts are compared, with their signs, to the nearest cent.
+*/
+func hasPosting(postings []posting, account string, amount float64) bool {
+	const cents = 100
+
+	for _, pst := range postings {
+		if pst.account == account && math.Round(pst.amount*cents) == math.Round(amount*cents) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+ParseJournal returns the entries in a ledger journal and nil.
+It reads entries written in the plain text format used by ledger and hledger,
+and ignores comments, directives and periodic or automated entries.
+Amounts have the decimal separator of the dialect, see parsePosting.
+If parseJournal fails to read the journal or parse an entry, it returns an error.
+*/
+func parseJournal(reader io.Reader, dlc dialect) ([]entry, error) {
+	var (
+		entries []entry
+		ent     *entry
+		elided  []string // the accounts of the postings whose amounts are elided
+	)
+
+	scnr := bufio.NewScanner(reader)
+
+	for lineN := 1; scnr.Scan(); lineN++ {
+		line := scnr.Text()
+
+		switch {
+		case line == "" || (!strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t")):
+			err := endEntry(ent, elided)
+			if err != nil {
+				return nil, fmt.Errorf("%w on line %v", err, ent.lineN)
+			}
+
+			ent, elided = nil, nil
+
+			if line != "" && '0' <= line[0] && line[0] <= '9' {
+				entries = append(entries, entry{lineN: lineN})
+				ent = &entries[len(entries)-1]
+
+				err = ent.parseHeader(line)
+				if err != nil {
+					return nil, fmt.Errorf("%w on line %v", err, lineN)
+				}
+			}
+		case ent == nil:
+			// indented line outside of an entry
+		case isComment(line):
+			// comment in an entry
+		default:
+			pst, ok := parsePosting(line, dlc)
+			if ok {
+				ent.postings = append(ent.postings, pst)
+			} else {
+				elided = append(elided, pst.account)
+			}
+		}
+	}
+
+	err := scnr.Err()
+	if err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+
+	err = endEntry(ent, elided)
+	if err != nil {
+		return nil, fmt.Errorf("%w on line %v", err, ent.lineN)
+	}
+
+	return entries, nil
+}
+
+/*
+EndEntry completes the entry and returns nil.
+If the amount of a posting, to the elided account, is elided, endEntry adds it with the amount that balances the entry.
+If more than one posting amount is elided, endEntry returns an error.
+*/
+func endEntry(ent *entry, elided []string) error {
+	switch {
+	case ent == nil || len(elided) == 0:
+		return nil
+	case 1 < len(elided):
+		return errJournalAmount
+	}
+
+	sum := zero
+	for _, pst := range ent.postings {
+		sum += pst.amount
+	}
+
+	ent.postings = append(ent.postings, posting{account: elided[0], amount: -sum})
+
+	return nil
+}
+
+/*
+IsComment returns true if the indented journal line is a comment.
+*/
+func isComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+
+	return trimmed == "" || strings.ContainsRune(";#*", rune(trimmed[0]))
+}
+
+/*
+ParseHeader parses the date and payee of this entry from its first line and returns nil.
+If parseHeader fails to parse the date, it returns an error.
+*/
+func (ent *entry) parseHeader(line string) error {
+	date, rest, _ := strings.Cut(line, " ")
+	date, _, _ = strings.Cut(date, "=") // ignore auxiliary date
+
+	var err error
+
+	for _, layout := range []string{"2006/01/02", "2006-01-02", "2006.01.02"} {
+		ent.date, err = time.Parse(layout, date)
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return errJournalDate
+	}
+
+	rest = strings.TrimSpace(rest)
+	rest = strings.TrimLeft(rest, "*! ")
+
+	if strings.HasPrefix(rest, "(") {
+		_, rest, _ = strings.Cut(rest, ")")
+	}
+
+	rest, _, _ = strings.Cut(rest, ";")
+	ent.payee = strings.TrimSpace(rest)
+
+	return nil
+}
+
+/*
+ParsePosting returns the account and amount of a posting and true.
+The account and amount in a posting are separated by a tab or at least two spaces,
+and the brackets of a virtual account are dropped.
+The amount has the decimal separator of the dialect, and thousands separators, e.g. "1.234,56" or "1,234.56".
+If the posting has no amount or it cannot be parsed, parsePosting returns its account and false.
+*/
+func parsePosting(line string, dlc dialect) (posting, bool) {
+	line, _, _ = strings.Cut(strings.TrimSpace(line), ";")
+
+	inx := strings.IndexAny(line, "\t")
+	if sp := strings.Index(line, "  "); inx < 0 || (0 <= sp && sp < inx) {
+		inx = sp
+	}
+
+	if inx < 0 {
+		return posting{account: strings.Trim(line, "()[]")}, false
+	}
+
+	pst := posting{account: strings.Trim(line[:inx], "()[]")}
+
+	amt := line[inx:]
+	amt, _, _ = strings.Cut(amt, "@") // ignore price
+	amt, _, _ = strings.Cut(amt, "=") // ignore balance assertion
+
+	// Drop the commodity, keeping the sign and separators.
+	amt = strings.Map(func(r rune) rune {
+		if strings.ContainsRune("-.,0123456789", r) {
+			return r
+		}
+
+		return -1
+	}, amt)
+	if amt == "" {
+		return pst, false
+	}
+
+	val, err := parseFloat64(dlc.number(amt))
+	if err != nil {
+		return pst, false
+	}
+
+	pst.amount = val
+
+	return pst, true
+}
+
+/*
+ReconcileFiles reconciles the transactions in a statement file with the entries in a journal file and returns nil.
+It writes the transactions and entries that did not match on standard output.
+If reconcileFiles fails to read either file, it returns an error.
+*/
+func reconcileFiles(cfg config, files []string) error {
+	const nFiles = 2
+
+	if len(files) != nFiles {
+		return errReconcileArgs
+	}
+
+	var trns []transact
+
+	tlr := translator{cfg: cfg, write: func(trn *transact) {
+		trns = append(trns, *trn)
+	}}
+
+	err := tlr.translateFile(files[0])
+	if err != nil {
+		return err
+	}
+
+	jnl, err := os.Open(files[1])
+	if err != nil {
+		return err
+	}
+	defer jnl.Close()
+
+	entries, err := parseJournal(jnl, cfg.dialect)
+	if err != nil {
+		return fmt.Errorf("parseJournal: %w", err)
+	}
+
+	for _, trn := range matchEntries(trns, entries) {
+		fmt.Fprintf(os.Stdout, "unmatched transaction: %v\n", trn.string())
+	}
+
+	for _, ent := range entries {
+		if !ent.matched {
+			fmt.Fprintf(os.Stdout, "unmatched entry on line %v: %v %v\n",
+				ent.lineN, ent.date.Format(time.DateOnly), ent.payee)
+		}
+	}
+
+	return nil
+}
+
+/*
+Similarity returns the fraction of words shared by the memo and payee, from zero to one.
+Words are compared ignoring case.
+*/
+func similarity(memo, payee string) float64 {
+	memoWords := strings.Fields(strings.ToLower(memo))
+	payeeWords := strings.Fields(strings.ToLower(payee))
+
+	if len(memoWords) == 0 || len(payeeWords) == 0 {
+		return zero
+	}
+
+	shared := 0
+
+	for _, word := range memoWords {
+		for _, pw := range payeeWords {
+			if word == pw {
+				shared++
+
+				break
+			}
+		}
+	}
+
+	return float64(shared) / float64(max(len(memoWords), len(payeeWords)))
+}
@@ -0,0 +1,102 @@
+//go:build ignore
+
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+/*
+Release builds the release binary of cas2trn for this platform, with the public release key set,
+see releaseKey, and signs it, so the self-update command of the binaries it replaces can verify it, e.g.
+
+	RELEASE_SIGNING_KEY=... go run release.go
+
+The binary is written to the -out directory, named e.g. "cas2trn_linux_amd64", or "cas2trn_windows_amd64.exe",
+with its base64 Ed25519 signature next to it, e.g. "cas2trn_linux_amd64.sig".
+Each platform is built on that platform, so SQLite, which needs cgo, is included.
+The signing key is the base64 seed of an Ed25519 private key, which -keygen writes with its public key.
+*/
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+const keyEnv = "RELEASE_SIGNING_KEY" // environment variable of the signing key
+
+func main() {
+	keygen := flag.Bool("keygen", false, "write a new signing key and its public key, instead of building")
+	out := flag.String("out", "dist", "directory to write the binary and its signature to")
+	flag.Parse()
+
+	if *keygen {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("%v=%v\n", keyEnv, base64.StdEncoding.EncodeToString(priv.Seed()))
+		fmt.Printf("public key %v\n", base64.StdEncoding.EncodeToString(pub))
+
+		return
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(os.Getenv(keyEnv))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		log.Fatalf("%v must be the base64 seed of an Ed25519 private key, see -keygen", keyEnv)
+	}
+
+	priv := ed25519.NewKeyFromSeed(seed)
+	pub := base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey))
+
+	// The same name as selfUpdate looks for.
+	name := "cas2trn_" + runtime.GOOS + "_" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	bin := filepath.Join(*out, name)
+
+	cmd := exec.Command("go", "build", "-trimpath", "-ldflags", "-s -w -X main.releaseKey="+pub, "-o", bin, ".")
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+
+	err = cmd.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data, err := os.ReadFile(bin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data))
+
+	err = os.WriteFile(bin+".sig", []byte(sig+"\n"), 0o644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,286 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+/*
+A reviewer reviews transactions interactively before they are written.
+The user can fix the memo or other account of each transaction, or skip it,
+and each fix is learned as a rule that is applied to later transactions with the same memo,
+and appended to the rule file so later runs apply it too.
+Other accounts assigned to memos are remembered as categories, to suggest for similar memos.
+*/
+type reviewer struct {
+	categories []category          // assigned in this and earlier reviews, see loadCategories
+	in         *bufio.Reader       // of the user's choices, the terminal if nil when reviewing starts
+	learned    map[string][]assign // fixes, by the memo of the transactions they apply to
+	nLoaded    int                 // categories loaded, which are not saved again
+	out        io.Writer           // of prompts
+	quit       bool                // the user has stopped reviewing
+	rules      [][]string          // learned, in the format of the rule file, see loadRules
+}
+
+// A category is the other account assigned to a transaction with the memo when reviewing.
+type category struct {
+	memo, otherAcct string
+}
+
+/*
+MinSimilarity is the smallest similarity of a memo to one in the categories
+for the other account assigned to it to be suggested, see similarity.
+*/
+const minSimilarity = 0.5
+
+/*
+Review shows the transaction, applies the user's fixes to it and returns true,
+or returns false if the user skips it.
+If the user has stopped reviewing, or there is no terminal, the transaction is not shown.
+*/
+func (rvw *reviewer) review(trn *transact) bool {
+	if asgs, ok := rvw.learned[trn.memo]; ok {
+		for _, asg := range asgs {
+			*trn.field(asg.field) = asg.template
+		}
+
+		return true
+	}
+
+	if rvw.quit || !rvw.openTerminal() {
+		return true
+	}
+
+	memo := trn.memo
+	suggestion := rvw.suggest(memo)
+
+	var asgs []assign
+
+	for {
+		fmt.Fprintf(rvw.out, "%v\n", trn.string())
+
+		if suggestion != "" {
+			fmt.Fprintf(rvw.out, "suggested other account %v, (a)ccept\n", suggestion)
+		}
+
+		fmt.Fprint(rvw.out, "[enter] keep, (o)ther account, (m)emo, (s)kip, (q)uit reviewing: ")
+
+		switch rvw.ask() {
+		case "":
+			rvw.learn(memo, asgs)
+
+			return true
+		case "a":
+			if suggestion != "" {
+				trn.otherAcct = suggestion
+				asgs = append(asgs, assign{field: "otheracct", template: trn.otherAcct})
+			}
+		case "o":
+			fmt.Fprint(rvw.out, "other account: ")
+			trn.otherAcct = rvw.ask()
+			asgs = append(asgs, assign{field: "otheracct", template: trn.otherAcct})
+		case "m":
+			fmt.Fprint(rvw.out, "memo: ")
+			trn.memo = rvw.ask()
+			asgs = append(asgs, assign{field: "memo", template: trn.memo})
+		case "s":
+			return false
+		case "q":
+			rvw.quit = true
+			rvw.learn(memo, asgs)
+
+			return true
+		}
+	}
+}
+
+/*
+Ask returns the line the user enters, without surrounding white space.
+If there is no more input, reviewing stops and ask returns empty string.
+*/
+func (rvw *reviewer) ask() string {
+	line, err := rvw.in.ReadString('\n')
+	if err != nil && line == "" {
+		rvw.quit = true
+	}
+
+	return strings.TrimSpace(line)
+}
+
+// Learn learns the fixes to the transactions with the memo, if any, as a rule.
+func (rvw *reviewer) learn(memo string, asgs []assign) {
+	if len(asgs) == 0 {
+		return
+	}
+
+	if rvw.learned == nil {
+		rvw.learned = make(map[string][]assign)
+	}
+
+	rvw.learned[memo] = asgs
+
+	rle := []string{"memo", "^" + regexp.QuoteMeta(memo) + "$"}
+	for _, asg := range asgs {
+		rle = append(rle, asg.field+"="+strings.ReplaceAll(asg.template, "$", "$$"))
+
+		if asg.field == "otheracct" && asg.template != "" {
+			rvw.categories = append(rvw.categories, category{memo: memo, otherAcct: asg.template})
+		}
+	}
+
+	rvw.rules = append(rvw.rules, rle)
+}
+
+/*
+Suggest returns the other account assigned to the memo most similar to this one in the categories,
+the latest if there is a tie, or empty string if none is similar enough, see minSimilarity.
+*/
+func (rvw *reviewer) suggest(memo string) string {
+	best, bestSim := "", minSimilarity
+
+	for _, cat := range rvw.categories {
+		if sim := similarity(memo, cat.memo); bestSim <= sim {
+			best, bestSim = cat.otherAcct, sim
+		}
+	}
+
+	return best
+}
+
+/*
+OpenTerminal opens the terminal for the user's choices, if not already open, and returns true.
+The terminal is used rather than standard input, which may be the statement.
+If there is no terminal, openTerminal writes a warning, stops reviewing and returns false.
+*/
+func (rvw *reviewer) openTerminal() bool {
+	if rvw.in != nil {
+		return true
+	}
+
+	name := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		name = "CONIN$"
+	}
+
+	tty, err := os.Open(name)
+	if err != nil {
+		fmt.Fprintf(rvw.out, "%v: warning: review needs a terminal: %v\n", pgmName, err)
+		rvw.quit = true
+
+		return false
+	}
+
+	rvw.in = bufio.NewReader(tty) // the terminal is open until cas2trn exits
+
+	return true
+}
+
+/*
+SaveRules appends the rules learned to the named rule file and returns nil.
+If saveRules fails to write the file, it returns an error.
+*/
+func (rvw *reviewer) saveRules(name string) error {
+	if len(rvw.rules) == 0 {
+		return nil
+	}
+
+	const perm = 0o644
+
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile: %w", err)
+	}
+
+	wtr := csv.NewWriter(file)
+	_ = wtr.WriteAll(rvw.rules) // the error is also returned by Error
+
+	return errors.Join(wtr.Error(), file.Close())
+}
+
+/*
+LoadCategories returns the categories read from the named CSV file, of memos and other accounts, and nil.
+If the file does not exist, there are no categories yet.
+If loadCategories fails to read the file, it returns an error.
+*/
+func loadCategories(name string) ([]category, error) {
+	file, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rdr := csv.NewReader(file)
+	rdr.FieldsPerRecord = 2
+
+	recs, err := rdr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("csv.Reader.ReadAll: %w", err)
+	}
+
+	cats := make([]category, len(recs))
+	for inx, rec := range recs {
+		cats[inx] = category{memo: rec[0], otherAcct: rec[1]}
+	}
+
+	return cats, nil
+}
+
+/*
+SaveCategories appends the categories assigned in this review to the named CSV file and returns nil.
+The file's directory is created if need be.
+If saveCategories fails to write the file, it returns an error.
+*/
+func (rvw *reviewer) saveCategories(name string) error {
+	if len(rvw.categories) == rvw.nLoaded {
+		return nil
+	}
+
+	const dirPerm, perm = 0o755, 0o644
+
+	err := os.MkdirAll(filepath.Dir(name), dirPerm)
+	if err != nil {
+		return fmt.Errorf("os.MkdirAll: %w", err)
+	}
+
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile: %w", err)
+	}
+
+	wtr := csv.NewWriter(file)
+	for _, cat := range rvw.categories[rvw.nLoaded:] {
+		_ = wtr.Write([]string{cat.memo, cat.otherAcct}) // the error is also returned by Error
+	}
+
+	wtr.Flush()
+
+	return errors.Join(wtr.Error(), file.Close())
+}
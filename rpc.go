@@ -0,0 +1,182 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+/*
+The methods of the JSON-RPC service of the serve command, see serveRPC,
+and the notification that streams a transaction.
+*/
+const (
+	rpcTranslate   = "TranslationService.TranslateStatement"
+	rpcStream      = "TranslationService.TranslateStatementStream"
+	rpcTransaction = "TranslationService.Transaction"
+)
+
+var (
+	errRPCMethod = errors.New("method must be " + rpcTranslate + " or " + rpcStream)
+	errRPCParams = errors.New("params must be an array of one object, see TranslateArgs")
+)
+
+// TranslateArgs are the arguments of the TranslateStatement and TranslateStatementStream methods.
+type TranslateArgs struct {
+	Profile   string // name of the profile, mandatory
+	Name      string // file name of the statement, optional and if it ends with ".zip" it is a zip archive
+	Statement []byte // base64 encoded in JSON, mandatory
+}
+
+// TranslateReply is the result of the TranslateStatement method.
+type TranslateReply struct {
+	// Transactions are the fields of each transaction by their names, as in the serve command's JSON format.
+	Transactions []map[string]any
+}
+
+// StreamReply is the result of the TranslateStatementStream method, after its transactions are notified.
+type StreamReply struct {
+	Count int // of the transactions notified
+}
+
+// An rpcRequest is a JSON-RPC 1.0 request, or a notification if its ID is null.
+type rpcRequest struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	ID     json.RawMessage   `json:"id"`
+}
+
+// An rpcResponse is a JSON-RPC 1.0 response, whose error is a string or null.
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result any             `json:"result"`
+	Error  any             `json:"error"`
+}
+
+/*
+ServeRPC listens on the address, then serves the translation service over JSON-RPC 1.0 on each connection,
+until it fails to accept a connection and returns an error.
+*/
+func serveRPC(addr string, srv *server) error {
+	lnr, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("net.Listen: %w", err)
+	}
+
+	for {
+		conn, err := lnr.Accept()
+		if err != nil {
+			return fmt.Errorf("net.Listener.Accept: %w", err)
+		}
+
+		go func() {
+			defer conn.Close()
+
+			_ = srv.serveRPCConn(conn) // the connection is closed by the client or failed
+		}()
+	}
+}
+
+/*
+ServeRPCConn serves the requests on the connection in turn, until it fails to read or write one,
+and returns the error, io.EOF when the client closes the connection.
+TranslateStatement replies with the transactions, as net/rpc/jsonrpc clients expect.
+TranslateStatementStream streams them, sending a TranslationService.Transaction notification,
+whose params are the request's ID and the transaction, for each as it is translated,
+then replies with their count, or an error, after which the notified transactions must be discarded.
+*/
+func (srv *server) serveRPCConn(conn io.ReadWriter) error {
+	dec, enc := json.NewDecoder(conn), json.NewEncoder(conn)
+
+	for {
+		var req rpcRequest
+
+		err := dec.Decode(&req)
+		if err != nil {
+			return err
+		}
+
+		var args TranslateArgs
+		if len(req.Params) != 1 || json.Unmarshal(req.Params[0], &args) != nil {
+			err = errRPCParams
+		}
+
+		var (
+			result any
+			encErr error
+		)
+
+		switch {
+		case err != nil:
+			// replies with the error
+		case req.Method == rpcTranslate:
+			var trns []transact
+
+			trns, err = srv.translateStatement(args.Profile, args.Name, args.Statement)
+
+			reply := TranslateReply{Transactions: make([]map[string]any, len(trns))}
+			for inx, trn := range trns {
+				reply.Transactions[inx] = trn.object()
+			}
+
+			result = reply
+		case req.Method == rpcStream:
+			var reply StreamReply
+
+			err = srv.streamStatement(args.Profile, args.Name, args.Statement, func(trn *transact) {
+				if encErr == nil {
+					encErr = enc.Encode(rpcRequest{Method: rpcTransaction, ID: json.RawMessage("null"),
+						Params: rpcParams(req.ID, trn.object())})
+					reply.Count++
+				}
+			})
+			result = reply
+		default:
+			err = errRPCMethod
+		}
+
+		if encErr != nil {
+			return encErr
+		}
+
+		rsp := rpcResponse{ID: req.ID, Result: result}
+		if err != nil {
+			rsp.Result, rsp.Error = nil, err.Error()
+		}
+
+		err = enc.Encode(rsp)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// RPCParams returns the values as the raw params of a JSON-RPC notification.
+func rpcParams(vals ...any) []json.RawMessage {
+	params := make([]json.RawMessage, len(vals))
+	for inx, val := range vals {
+		params[inx], _ = json.Marshal(val) // IDs and transaction objects can always be marshalled
+	}
+
+	return params
+}
@@ -0,0 +1,216 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*
+A rule sets fields of a transaction when its pattern matches a field.
+The value set is a template, which can refer to capture groups in the pattern e.g. "$1".
+*/
+type rule struct {
+	field    string // name of the field matched
+	pattern  *regexp.Regexp
+	assigns  []assign
+	lineN    int  // in the rule file, zero if unknown
+	priority int  // rules with higher priorities are applied first
+	stop     bool // no more rules are applied after this one matches
+}
+
+// An assign sets a field of a transaction to the expansion of a template.
+type assign struct {
+	field    string
+	template string
+}
+
+// Rules are applied to each transaction in order.
+type rules []rule
+
+const minRuleFields = 3 // field name, pattern and at least one assignment
+
+// The options of a rule, which follow its pattern like assignments.
+const (
+	rulePriority = "!priority" // e.g. "!priority=10", default zero
+	ruleStop     = "!stop"
+)
+
+var (
+	errRuleAssign   = errors.New("assignment in rule must be field name=template e.g. \"reference=$1\"")
+	errRuleField    = errors.New("field name in rule must be an existing text field")
+	errRuleNFields  = errors.New("rule must have a field name, pattern and at least one assignment")
+	errRulePriority = errors.New("rule priority must be an integer e.g. \"!priority=10\"")
+	errRuleTarget   = errors.New("rule cannot assign to the amount or date fields")
+)
+
+/*
+LoadRules returns the rules read from the named CSV file and nil.
+Each record in the file is a rule of field name, pattern and one or more assignments,
+e.g. "memo,Ref: (\w+),reference=$1".
+An assignment to a field that a transaction does not have adds that field to the output.
+Options can follow the pattern too: "!priority=n" and "!stop", see apply.
+Rules are returned in the order they are applied, by descending priority then their order in the file.
+The extra field names are those already added by the configuration, which the rules can match.
+If loadRules fails to read or parse a rule, it returns an error.
+*/
+func loadRules(name string, extraNames []string) (rules, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rls rules
+
+	rdr := csv.NewReader(file)
+	rdr.FieldsPerRecord = -1
+
+	for {
+		flds, err := rdr.Read()
+		if errors.Is(err, io.EOF) {
+			slices.SortStableFunc(rls, func(a, b rule) int { return b.priority - a.priority })
+
+			return rls, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("reader.Read(): %w", err)
+		}
+
+		lineN, _ := rdr.FieldPos(0)
+
+		rle, err := parseRule(flds, append(slices.Clip(extraNames), rls.extraNames()...))
+		if err != nil {
+			return nil, fmt.Errorf("%w on line %v", err, lineN)
+		}
+
+		rle.lineN = lineN
+
+		rls = append(rls, rle)
+	}
+}
+
+/*
+ParseRule returns the rule parsed from the fields of a record and nil.
+The extra field names are those added by previous rules, which this rule can match.
+If parseRule fails to parse the rule, it returns the first error.
+*/
+func parseRule(fields []string, extraNames []string) (rule, error) {
+	if len(fields) < minRuleFields {
+		return rule{}, errRuleNFields
+	}
+
+	rle := rule{field: fields[0]}
+
+	var trn transact
+	if trn.field(rle.field) == nil && !slices.Contains(extraNames, rle.field) {
+		return rule{}, errRuleField
+	}
+
+	var err error
+
+	rle.pattern, err = regexp.Compile(fields[1])
+	if err != nil {
+		return rule{}, fmt.Errorf("regexp.Compile: %w", err)
+	}
+
+	for _, fld := range fields[2:] {
+		name, tmpl, ok := strings.Cut(fld, "=")
+
+		switch {
+		case fld == ruleStop:
+			rle.stop = true
+
+			continue
+		case name == rulePriority:
+			rle.priority, err = strconv.Atoi(tmpl)
+			if err != nil {
+				return rule{}, errRulePriority
+			}
+
+			continue
+		case !ok || name == "":
+			return rule{}, errRuleAssign
+		case name == "amount" || name == "date":
+			return rule{}, errRuleTarget
+		}
+
+		rle.assigns = append(rle.assigns, assign{field: name, template: tmpl})
+	}
+
+	return rle, nil
+}
+
+/*
+Apply applies each rule whose pattern matches to the transaction, and returns the indexes of those rules.
+Rules are applied in order, see loadRules, and a later rule can overwrite a field set by an earlier rule.
+After a stop rule matches, no more rules are applied.
+*/
+func (rls rules) apply(trn *transact) []int {
+	var applied []int
+
+	for inx, rle := range rls {
+		src := *trn.field(rle.field)
+
+		match := rle.pattern.FindStringSubmatchIndex(src)
+		if match == nil {
+			continue
+		}
+
+		applied = append(applied, inx)
+
+		for _, asn := range rle.assigns {
+			val := rle.pattern.ExpandString(nil, asn.template, src, match)
+			*trn.field(asn.field) = string(val)
+		}
+
+		if rle.stop {
+			break
+		}
+	}
+
+	return applied
+}
+
+/*
+ExtraNames returns the names of the fields the rules assign that a transaction does not have.
+The names are in order of first assignment, which is their order in the output.
+*/
+func (rls rules) extraNames() []string {
+	var names []string
+
+	var trn transact
+
+	for _, rle := range rls {
+		for _, asn := range rle.assigns {
+			if trn.field(asn.field) == nil && !slices.Contains(names, asn.field) {
+				names = append(names, asn.field)
+			}
+		}
+	}
+
+	return names
+}
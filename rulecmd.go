@@ -0,0 +1,233 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	rulesCmd     = "rules"
+	rulesDiffCmd = "diff"
+	rulesTestCmd = "test"
+)
+
+var (
+	errRulesCmd  = errors.New("rules needs a subcommand, diff or test")
+	errRulesDiff = errors.New("rules diff needs the names of the old and new rule files, and a file of " +
+		"transactions in the standard format")
+	errRulesTest = errors.New("rules test needs the names of a rule file and a file of transactions " +
+		"in the standard format")
+	errStdAmount = errors.New("amount is not a decimal number e.g. -6.5")
+	errStdDate   = errors.New("date is not an ISO 8601 calendar date e.g. 2019-12-24")
+)
+
+// StdAmountPattern matches the amounts of the standard format, decimal numbers without exponents or separators.
+var stdAmountPattern = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+
+/*
+A standardRecord is a transaction read from a file in the standard format, see readStandard.
+LineN is the line it starts on.
+*/
+type standardRecord struct {
+	trn   transact
+	lineN int
+}
+
+/*
+RunRules runs the rules subcommand in the arguments, writes its report to the writer and returns nil.
+If the subcommand is not valid or fails, runRules returns an error.
+*/
+func runRules(writer io.Writer, args []string) error {
+	switch {
+	case len(args) == 0 || (args[0] != rulesDiffCmd && args[0] != rulesTestCmd):
+		return errRulesCmd
+	case args[0] == rulesDiffCmd:
+		if len(args) != 4 {
+			return errRulesDiff
+		}
+
+		return diffRules(writer, args[1], args[2], args[3])
+	case len(args) != 3:
+		return errRulesTest
+	}
+
+	rls, err := loadRules(args[1], nil)
+	if err != nil {
+		return fmt.Errorf("loadRules: %w", err)
+	}
+
+	recs, err := readStandard(args[2], rls.extraNames())
+	if err != nil {
+		return fmt.Errorf("readStandard: %w", err)
+	}
+
+	testRules(writer, rls, recs)
+
+	return nil
+}
+
+/*
+TestRules applies the rules to each transaction and writes a report of them to the writer:
+how many transactions each rule matched, the transactions that no rule matched,
+and the rules that matched none, so they can be removed or fixed.
+*/
+func testRules(writer io.Writer, rls rules, recs []standardRecord) {
+	nMatched := make([]int, len(rls))
+
+	for _, rec := range recs {
+		applied := rls.apply(&rec.trn)
+		if len(applied) == 0 {
+			fmt.Fprintf(writer, "unmatched transaction on line %v: %v %v %v\n",
+				rec.lineN, rec.trn.date, rec.trn.memo, strconv.FormatFloat(rec.trn.amount, 'f', -1, 64))
+		}
+
+		for _, inx := range applied {
+			nMatched[inx]++
+		}
+	}
+
+	for inx, rle := range rls {
+		if nMatched[inx] == 0 {
+			fmt.Fprintf(writer, "rule on line %v never matched: %v %v\n", rle.lineN, rle.field, rle.pattern)
+		} else {
+			fmt.Fprintf(writer, "rule on line %v matched %v transactions: %v %v\n",
+				rle.lineN, nMatched[inx], rle.field, rle.pattern)
+		}
+	}
+}
+
+/*
+ReadStandard returns the transactions in the named file in the standard format, with the extra fields, and nil.
+The extra fields are empty string, as the file does not name its fields. Comments are ignored.
+Dates must be ISO 8601 calendar dates and amounts decimal numbers, as cas2trn writes them,
+so a corrupted file is detected rather than its transactions used.
+If readStandard fails to read or parse a transaction, it returns an error with its line and field.
+*/
+func readStandard(name string, extraNames []string) ([]standardRecord, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rdr := csv.NewReader(skipBOM(file))
+	rdr.Comment, rdr.FieldsPerRecord = '#', -1
+
+	var recs []standardRecord
+
+	for {
+		flds, err := rdr.Read()
+		if errors.Is(err, io.EOF) {
+			return recs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("reader.Read(): %w", err)
+		}
+
+		lineN, _ := rdr.FieldPos(0)
+
+		const nStdFields, amountI, currencyI = 6, 4, 5
+		if len(flds) < nStdFields {
+			return nil, fmt.Errorf("%w on line %v", errNFields, lineN)
+		}
+
+		_, err = time.Parse(time.DateOnly, flds[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w on line %v field 1: %q", errStdDate, lineN, flds[0])
+		}
+
+		if !stdAmountPattern.MatchString(flds[amountI]) {
+			return nil, fmt.Errorf("%w on line %v field %v: %q", errStdAmount, lineN, amountI+1, flds[amountI])
+		}
+
+		amt, err := strconv.ParseFloat(flds[amountI], 64)
+		if err != nil {
+			return nil, fmt.Errorf("strconv.ParseFloat: %w on line %v field %v", err, lineN, amountI+1)
+		}
+
+		trn := transact{amount: amt, currency: flds[currencyI], date: flds[0], extraNames: extraNames,
+			extras: make([]string, len(extraNames)), memo: flds[stdMemoI], otherAcct: flds[stdOtherAcctI],
+			thisAcct: flds[1]}
+		recs = append(recs, standardRecord{trn: trn, lineN: lineN})
+	}
+}
+
+/*
+DiffRules applies the rules in the old and new rule files to each transaction in the named standard format file,
+writes the changes the new rules make to its fields to the writer, and returns nil.
+This shows the effect of editing rules before they are used.
+If diffRules fails to read a file, it returns an error.
+*/
+func diffRules(writer io.Writer, oldName, newName, trnName string) error {
+	oldRules, err := loadRules(oldName, nil)
+	if err != nil {
+		return fmt.Errorf("loadRules: %w", err)
+	}
+
+	newRules, err := loadRules(newName, nil)
+	if err != nil {
+		return fmt.Errorf("loadRules: %w", err)
+	}
+
+	extraNames := oldRules.extraNames()
+	for _, name := range newRules.extraNames() {
+		if !slices.Contains(extraNames, name) {
+			extraNames = append(extraNames, name)
+		}
+	}
+
+	recs, err := readStandard(trnName, extraNames)
+	if err != nil {
+		return fmt.Errorf("readStandard: %w", err)
+	}
+
+	names := append([]string{"thisacct", "otheracct", "memo", "currency", "taxrate", "taxacct"}, extraNames...)
+
+	for _, rec := range recs {
+		oldTrn, newTrn := rec.trn, rec.trn
+		oldTrn.extras, newTrn.extras = slices.Clone(rec.trn.extras), slices.Clone(rec.trn.extras)
+
+		oldRules.apply(&oldTrn)
+		newRules.apply(&newTrn)
+
+		var changes []string
+
+		for _, name := range names {
+			if oldVal, newVal := *oldTrn.field(name), *newTrn.field(name); oldVal != newVal {
+				changes = append(changes, fmt.Sprintf("%v %q -> %q", name, oldVal, newVal))
+			}
+		}
+
+		if 0 < len(changes) {
+			fmt.Fprintf(writer, "line %v: %v %v %v: %v\n", rec.lineN, rec.trn.date, rec.trn.memo,
+				strconv.FormatFloat(rec.trn.amount, 'f', -1, 64), strings.Join(changes, ", "))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+/*
+An s3Config configures reading and writing objects in Amazon S3, or S3-compatible object storage,
+named by URIs such as "s3://bucket/statements/2025-01.csv".
+Requests are signed with AWS signature version 4, and use path-style URLs.
+*/
+type s3Config struct {
+	accessKey    string // mandatory
+	endpoint     string // URL, mandatory e.g. "https://s3.us-east-1.amazonaws.com"
+	region       string // mandatory e.g. "us-east-1"
+	secretKey    string // mandatory
+	sessionToken string // optional
+}
+
+const (
+	s3AccessKeyEnv    = "AWS_ACCESS_KEY_ID"
+	s3Region          = "us-east-1"
+	s3RegionEnv       = "AWS_REGION"
+	s3Scheme          = "s3://"
+	s3SecretKeyEnv    = "AWS_SECRET_ACCESS_KEY"
+	s3SessionTokenEnv = "AWS_SESSION_TOKEN"
+	s3TimeFormat      = "20060102T150405Z"
+)
+
+var errS3Config = errors.New("S3 URI needs an access key, secret key, region and endpoint, " +
+	"see s3region and s3endpoint")
+
+/*
+Get returns the contents of the object in S3 with the URI and nil.
+If get fails, it returns an error.
+*/
+func (s3c s3Config) get(uri string) ([]byte, error) {
+	return s3c.do(http.MethodGet, uri, nil)
+}
+
+// Put writes the data to the object in S3 with the URI and returns nil, or an error if put fails.
+func (s3c s3Config) put(uri string, data []byte) error {
+	_, err := s3c.do(http.MethodPut, uri, data)
+
+	return err
+}
+
+/*
+Do sends a signed request with the method and body for the object in S3 with the URI,
+and returns the body of the response and nil.
+If the request fails or the response is not OK, do returns an error.
+*/
+func (s3c s3Config) do(method, uri string, body []byte) ([]byte, error) {
+	if s3c.accessKey == "" || s3c.secretKey == "" || s3c.region == "" || s3c.endpoint == "" {
+		return nil, errS3Config
+	}
+
+	path := strings.TrimPrefix(uri, s3Scheme)
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(s3c.endpoint, "/")+"/"+s3Escape(path),
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequest: %w", err)
+	}
+
+	s3c.sign(req, body, time.Now())
+
+	return apiDo(req)
+}
+
+/*
+Sign signs the request, with the body, at the time with AWS signature version 4.
+All the headers of the request are signed, and the host.
+*/
+func (s3c s3Config) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.UTC().Format(s3TimeFormat)
+	payloadHash := sha256.Sum256(body)
+
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	if s3c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s3c.sessionToken)
+	}
+
+	hdrs := map[string]string{"host": req.URL.Host}
+	for name, vals := range req.Header {
+		hdrs[strings.ToLower(name)] = strings.TrimSpace(strings.Join(vals, ","))
+	}
+
+	names := slices.Sorted(func(yield func(string) bool) {
+		for name := range hdrs {
+			if !yield(name) {
+				return
+			}
+		}
+	})
+
+	var canonHdrs strings.Builder
+	for _, name := range names {
+		canonHdrs.WriteString(name + ":" + hdrs[name] + "\n")
+	}
+
+	signedHdrs := strings.Join(names, ";")
+	canonReq := strings.Join([]string{req.Method, s3Escape(strings.TrimPrefix(req.URL.Path, "/")),
+		req.URL.Query().Encode(), canonHdrs.String(), signedHdrs, hex.EncodeToString(payloadHash[:])}, "\n")
+	canonHash := sha256.Sum256([]byte(canonReq))
+
+	const service, terminator = "s3", "aws4_request"
+
+	scope := amzDate[:8] + "/" + s3c.region + "/" + service + "/" + terminator
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonHash[:])
+
+	key := []byte("AWS4" + s3c.secretKey)
+	for _, part := range []string{amzDate[:8], s3c.region, service, terminator, toSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s3c.accessKey+"/"+scope+
+		", SignedHeaders="+signedHdrs+", Signature="+hex.EncodeToString(key))
+}
+
+// S3Escape returns the path of an S3 object with each segment escaped as AWS signature version 4 requires.
+func s3Escape(path string) string {
+	segs := strings.Split(path, "/")
+	for inx, seg := range segs {
+		segs[inx] = strings.ReplaceAll(url.PathEscape(seg), "+", "%2B")
+	}
+
+	return "/" + strings.Join(segs, "/")
+}
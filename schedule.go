@@ -0,0 +1,234 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+A schedule is a cron schedule, the set of minutes that a periodic run starts at.
+Each field is a bit set of the values it matches.
+*/
+type schedule struct {
+	minutes, hours, days, months, weekdays uint64
+	anyDay, anyWeekday                     bool // the day or weekday field is "*"
+}
+
+// A cronField describes one field of a cron expression.
+type cronField struct {
+	min, max int
+	names    []string // of values from min, optional
+}
+
+// The fields of a cron expression, in order.
+var cronFields = [...]cronField{
+	{0, 59, nil},
+	{0, 23, nil},
+	{1, 31, nil},
+	{1, 12, []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+	{0, 7, []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT", "SUN"}},
+}
+
+var (
+	errSchedule      = errors.New("schedule must be a cron expression with five fields e.g. \"0 7 * * MON\"")
+	errScheduleNever = errors.New("schedule never matches a time")
+	errScheduleValue = errors.New("schedule value is not valid")
+)
+
+/*
+ParseSchedule returns the schedule parsed from the cron expression and nil.
+The expression has five fields: minute, hour, day of month, month and day of week.
+Each field is "*", or a list of values or ranges with optional steps e.g. "1-5", "0-59/15" or "MON,WED".
+If the expression is not valid, parseSchedule returns an error.
+*/
+func parseSchedule(expr string) (schedule, error) {
+	flds := strings.Fields(expr)
+	if len(flds) != len(cronFields) {
+		return schedule{}, errSchedule
+	}
+
+	var (
+		sets [len(cronFields)]uint64
+		err  error
+	)
+
+	for inx, fld := range flds {
+		sets[inx], err = cronFields[inx].parse(fld)
+		if err != nil {
+			return schedule{}, fmt.Errorf("%w: %v", err, fld)
+		}
+	}
+
+	const sunday = 7
+	if sets[4]&(1<<sunday) != 0 {
+		sets[4] |= 1
+	}
+
+	return schedule{
+		minutes: sets[0], hours: sets[1], days: sets[2], months: sets[3], weekdays: sets[4],
+		anyDay: flds[2] == "*", anyWeekday: flds[4] == "*",
+	}, nil
+}
+
+// Parse returns the bit set of values matched by the field of a cron expression and nil.
+func (cf cronField) parse(fld string) (uint64, error) {
+	var set uint64
+
+	for item := range strings.SplitSeq(fld, ",") {
+		rng, stepStr, hasStep := strings.Cut(item, "/")
+
+		step := 1
+
+		if hasStep {
+			var err error
+
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step < 1 {
+				return 0, errScheduleValue
+			}
+		}
+
+		first, last := cf.min, cf.max
+
+		if rng != "*" {
+			firstStr, lastStr, isRange := strings.Cut(rng, "-")
+
+			var ok bool
+
+			first, ok = cf.value(firstStr)
+			if !ok {
+				return 0, errScheduleValue
+			}
+
+			last = first
+			if isRange {
+				last, ok = cf.value(lastStr)
+			} else if hasStep {
+				last = cf.max
+			}
+
+			if !ok || last < first {
+				return 0, errScheduleValue
+			}
+		}
+
+		for val := first; val <= last; val += step {
+			set |= 1 << val
+		}
+	}
+
+	return set, nil
+}
+
+// Value returns the value of the number or name in the field of a cron expression and true.
+func (cf cronField) value(str string) (int, bool) {
+	for inx, name := range cf.names {
+		if strings.EqualFold(str, name) {
+			return cf.min + inx, true
+		}
+	}
+
+	val, err := strconv.Atoi(str)
+	if err != nil || val < cf.min || cf.max < val {
+		return 0, false
+	}
+
+	return val, true
+}
+
+/*
+IsDay returns true if the schedule matches the day of the time.
+As in cron, if both day of month and day of week are restricted, matching either is enough.
+*/
+func (sch schedule) isDay(tim time.Time) bool {
+	day := sch.days&(1<<tim.Day()) != 0
+	weekday := sch.weekdays&(1<<int(tim.Weekday())) != 0
+
+	if sch.anyDay || sch.anyWeekday {
+		return day && weekday
+	}
+
+	return day || weekday
+}
+
+/*
+Next returns the first time after the given time that the schedule matches, and true.
+If the schedule never matches, such as on 31 February, next returns false.
+*/
+func (sch schedule) next(after time.Time) (time.Time, bool) {
+	tim := after.Truncate(time.Minute).Add(time.Minute)
+
+	const maxYears = 8 // long enough to find 29 February
+
+	for end := tim.AddDate(maxYears, 0, 0); tim.Before(end); {
+		switch {
+		case sch.months&(1<<int(tim.Month())) == 0:
+			tim = time.Date(tim.Year(), tim.Month()+1, 1, 0, 0, 0, 0, tim.Location())
+		case !sch.isDay(tim):
+			tim = time.Date(tim.Year(), tim.Month(), tim.Day()+1, 0, 0, 0, 0, tim.Location())
+		case sch.hours&(1<<tim.Hour()) == 0:
+			tim = time.Date(tim.Year(), tim.Month(), tim.Day(), tim.Hour()+1, 0, 0, 0, tim.Location())
+		case sch.minutes&(1<<tim.Minute()) == 0:
+			tim = tim.Add(time.Minute)
+		default:
+			return tim, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+/*
+RunScheduled runs the function each time the schedule matches, and never returns unless the schedule never matches.
+Each run is logged to standard error as a structured record, with the statistics of the run.
+A failed run is logged, and does not stop later runs.
+*/
+func runScheduled(sch schedule, run func() (stats, error)) error {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil)).With("program", pgmName)
+
+	for {
+		next, ok := sch.next(time.Now())
+		if !ok {
+			return errScheduleNever
+		}
+
+		logger.Info("waiting", "next", next.Format(time.RFC3339))
+		time.Sleep(time.Until(next))
+
+		start := time.Now()
+		sts, err := run()
+		attrs := []any{
+			"records", sts.nRecords, "transactions", sts.nTransacts(), "failed", sts.nFailed,
+			"duration", time.Since(start).Round(time.Millisecond),
+		}
+
+		if err != nil {
+			logger.Error("run failed", append(attrs, "error", err)...)
+		} else {
+			logger.Info("run succeeded", attrs...)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	serveCmd      = "serve"
+	profileExt    = ".flags"
+	maxUploadSize = 32 << 20 // bytes
+)
+
+// A server translates statements uploaded over HTTP, according to profiles in its profile directory.
+type server struct {
+	profileDir string
+}
+
+var (
+	errListen      = errors.New("serve needs an address to listen on, see listen and rpclisten")
+	errProfileDir  = errors.New("serve needs a profile directory, see profiledir")
+	errProfileName = errors.New("profile name must be letters, digits, underscores or hyphens")
+	errFormat      = errors.New("output format must be csv or json")
+	errServedFlag  = errors.New("flag cannot be set in a served profile")
+)
+
+/*
+ServedRejects are the flags that served profiles cannot set,
+as they are interactive, write other than the response or write instead of translating.
+*/
+var servedRejects = []string{
+	"backups", "clipboard", "dbdsn", "encryptto", "explain", "force", "help", "hmackey", "metrics", "outfile",
+	"printconfig", "profiledir", "review", "schedule", "sheetid", "statefile", "stats", "toclipboard", "version",
+	"whatsnew",
+}
+
+// ProfileNamePattern matches valid profile names, which cannot refer outside the profile directory.
+var profileNamePattern = regexp.MustCompile(`^[\w-]+$`)
+
+/*
+Serve serves translation of uploaded statements over HTTP, JSON-RPC or both until it fails, and returns an error.
+The server is configured by the flags in the arguments.
+*/
+func serve(args []string) error {
+	fset := flag.NewFlagSet(pgmName+" "+serveCmd, flag.ExitOnError)
+	listen := fset.String("listen", ":8080", "address to listen on for HTTP requests, "+
+		"optional and if empty string HTTP is not served")
+	rpcListen := fset.String("rpclisten", "", "address to listen on for JSON-RPC connections, "+
+		"optional e.g. \":8081\"")
+	profileDir := fset.String("profiledir", "", "directory of profiles, mandatory and each profile "+
+		"is a file named \"<profile>"+profileExt+"\" of cas2trn flags, one per line")
+
+	_ = fset.Parse(args) // exits on error
+
+	if *profileDir == "" {
+		return errProfileDir
+	}
+
+	if *listen == "" && *rpcListen == "" {
+		return errListen
+	}
+
+	srv := &server{profileDir: *profileDir}
+	errs := make(chan error)
+
+	if *listen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("POST /translate", srv)
+
+		hsrv := &http.Server{Addr: *listen, Handler: mux, ReadHeaderTimeout: time.Minute}
+
+		go func() { errs <- hsrv.ListenAndServe() }()
+	}
+
+	if *rpcListen != "" {
+		go func() { errs <- serveRPC(*rpcListen, srv) }()
+	}
+
+	return <-errs
+}
+
+/*
+LoadProfile returns the configuration in the named profile file and nil.
+The file contains cas2trn flags, one per line, e.g. "-dateformat=02/01/2006".
+Blank lines and lines starting with "#" are ignored.
+If loadProfile fails to read the file or parse its flags, or they set a flag in servedRejects, it returns an error.
+*/
+func loadProfile(name string) (config, error) {
+	args, err := readProfile(name)
+	if err != nil {
+		return config{}, err
+	}
+
+	fset := flag.NewFlagSet(name, flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+
+	cfg, err := parseConfig(fset, args)
+
+	return cfg, cmp.Or(checkServedFlags(fset), err)
+}
+
+/*
+CheckServedFlags returns nil if the flag set has no flag in servedRejects set,
+including by a config file, preset or set flag, else an error naming the first.
+*/
+func checkServedFlags(fset *flag.FlagSet) error {
+	var err error
+
+	fset.Visit(func(flg *flag.Flag) {
+		if err == nil && slices.Contains(servedRejects, flg.Name) {
+			err = fmt.Errorf("%w: -%v", errServedFlag, flg.Name)
+		}
+	})
+
+	return err
+}
+
+/*
+ReadProfile returns the flags in the named profile file, see loadProfile, and nil.
+If readProfile fails to read the file, it returns an error.
+*/
+func readProfile(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var args []string
+
+	scnr := bufio.NewScanner(file)
+	for scnr.Scan() {
+		line := strings.TrimSpace(scnr.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			args = append(args, line)
+		}
+	}
+
+	err = scnr.Err()
+	if err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+
+	return args, nil
+}
+
+/*
+ServeHTTP translates the statement uploaded in the request according to its profile,
+and responds with the transactions in the standard format.
+The request is a multipart form of at most maxUploadSize bytes with fields statement, the CSV or zip file,
+profile, the name of the profile, and format, csv (the default) or json.
+If the request is not valid or translation fails, ServeHTTP responds with an error.
+*/
+func (srv *server) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	req.Body = http.MaxBytesReader(writer, req.Body, maxUploadSize)
+
+	trns, err := srv.translate(req)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	switch req.FormValue("format") {
+	case "", "csv":
+		writer.Header().Set("Content-Type", "text/csv")
+
+		for _, trn := range trns {
+			fmt.Fprintln(writer, trn.string())
+		}
+	case "json":
+		objs := make([]map[string]any, len(trns))
+		for inx, trn := range trns {
+			objs[inx] = trn.object()
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(writer).Encode(objs) // the response has started so cannot be an error
+	}
+}
+
+/*
+Translate returns the transactions translated from the statement uploaded in the request and nil.
+If the request is not valid or translation fails, translate returns an error.
+*/
+func (srv *server) translate(req *http.Request) ([]transact, error) {
+	err := req.ParseMultipartForm(maxUploadSize)
+	if err != nil {
+		return nil, fmt.Errorf("http.Request.ParseMultipartForm: %w", err)
+	}
+
+	if format := req.FormValue("format"); format != "" && format != "csv" && format != "json" {
+		return nil, errFormat
+	}
+
+	file, header, err := req.FormFile("statement")
+	if err != nil {
+		return nil, fmt.Errorf("http.Request.FormFile: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	return srv.translateStatement(req.FormValue("profile"), header.Filename, data)
+}
+
+/*
+TranslateStatement returns the transactions translated from the statement data according to the profile, and nil.
+The statement is a zip archive if its file name ends with ".zip", else CSV.
+If the profile is not valid or translation fails, translateStatement returns an error.
+*/
+func (srv *server) translateStatement(profile, name string, data []byte) ([]transact, error) {
+	var trns []transact
+
+	err := srv.streamStatement(profile, name, data, func(trn *transact) {
+		trns = append(trns, *trn)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return trns, nil
+}
+
+/*
+StreamStatement translates the statement data according to the profile, calling write with each transaction
+as it is translated, and returns nil.
+If the profile is not valid or translation fails, streamStatement returns an error,
+which may be after some transactions are written.
+*/
+func (srv *server) streamStatement(profile, name string, data []byte, write func(*transact)) error {
+	if !profileNamePattern.MatchString(profile) {
+		return errProfileName
+	}
+
+	cfg, err := loadProfile(filepath.Join(srv.profileDir, profile+profileExt))
+	if err != nil {
+		return fmt.Errorf("loadProfile: %w", err)
+	}
+
+	tlr := translator{cfg: cfg, write: write}
+
+	return tlr.translateAttachment(attachment{data: data, name: cmp.Or(name, "statement.csv")})
+}
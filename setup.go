@@ -0,0 +1,353 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	setupCmd    = "setup"
+	setupNShown = 5 // records of the statement shown, and transactions previewed
+)
+
+var (
+	errSetupArgs  = errors.New("setup needs the name of a sample statement file")
+	errSetupInput = errors.New("setup needs an answer, but its input ended")
+)
+
+// A wizard asks questions on its writer and reads the answers from its scanner, see runSetup.
+type wizard struct {
+	scanner *bufio.Scanner
+	writer  io.Writer
+}
+
+/*
+A setting is a flag name and value that setup writes to the configuration file.
+Numbers are written bare, and other values as TOML strings.
+*/
+type setting struct {
+	name, val string
+}
+
+/*
+RunSetup configures the format of the statement file named in the arguments interactively, and returns nil.
+It shows the statement's columns, with its header row and first records, asks which column is which
+and the date format, which is checked against the dates, defaulting to those inferConfig suggests,
+previews the transactions, and writes the configuration, in TOML for the config flag, to a file.
+Questions are written to the writer and answers read from the reader.
+If it fails to read the statement or the answers, or to write the file, runSetup returns an error.
+*/
+func runSetup(rdr io.Reader, writer io.Writer, args []string) error {
+	if len(args) != 1 {
+		return errSetupArgs
+	}
+
+	name := args[0]
+
+	smp, err := readSample(name, maxSampled)
+	if err != nil {
+		return err
+	}
+
+	if smp.nFields == 0 {
+		return errInferDates
+	}
+
+	smp.show(writer)
+
+	inf, _ := smp.infer() // the defaults of questions it fails to infer are none
+	wzd := wizard{scanner: bufio.NewScanner(rdr), writer: writer}
+
+	stgs, err := wzd.askSettings(&inf)
+	if err != nil {
+		return err
+	}
+
+	flags := make([]string, len(stgs))
+	for inx, stg := range stgs {
+		flags[inx] = "-" + stg.name + "=" + stg.val
+	}
+
+	cfg, err := parseConfig(flag.NewFlagSet(setupCmd, flag.ContinueOnError), flags)
+	if err != nil {
+		return fmt.Errorf("parseConfig: %w", err)
+	}
+
+	err = previewTransacts(writer, cfg, name)
+	if err != nil {
+		return err
+	}
+
+	return wzd.saveSettings(stgs, strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))+".toml")
+}
+
+// Show writes the columns of this sample, with their header names and first values, to the writer.
+func (smp *sample) show(writer io.Writer) {
+	for inx := 1; inx <= smp.nFields; inx++ {
+		vals := smp.column(inx)[:min(setupNShown, len(smp.recs))]
+
+		name := ""
+		if smp.header != nil {
+			name = strings.TrimSpace(smp.header[inx-1])
+		}
+
+		fmt.Fprintf(writer, "column %v %q: %v\n", inx, name, strings.Join(vals, " | "))
+	}
+}
+
+/*
+AskSettings returns the settings of the statement format from the answers to questions,
+whose defaults are the inference from the sample, and nil.
+If it fails to read an answer, askSettings returns an error.
+*/
+func (wzd *wizard) askSettings(inf *inference) ([]setting, error) {
+	stgs := []setting{{"nfields", strconv.Itoa(inf.nFields)}}
+
+	dateI, err := wzd.askColumn("date column", inf.dateI, inf.nFields, false)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := wzd.askDateFormat(inf.dateFormat, inf.column(dateI))
+	if err != nil {
+		return nil, err
+	}
+
+	memoI, err := wzd.askColumn("memo or description column", inf.memoI, inf.nFields, false)
+	if err != nil {
+		return nil, err
+	}
+
+	stgs = append(stgs, setting{"datei", strconv.Itoa(dateI)}, setting{"dateformat", format},
+		setting{"memoi", strconv.Itoa(memoI)})
+
+	amountI, err := wzd.askColumn("amount column, or 0 if there are debit and credit columns",
+		inf.amountI, inf.nFields, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if amountI != 0 {
+		stgs = append(stgs, setting{"amounti", strconv.Itoa(amountI)})
+	} else {
+		for _, col := range []struct {
+			name string
+			def  int
+		}{{"debit", inf.debitI}, {"credit", inf.creditI}} {
+			inx, err := wzd.askColumn(col.name+" column", col.def, inf.nFields, false)
+			if err != nil {
+				return nil, err
+			}
+
+			stgs = append(stgs, setting{col.name + "i", strconv.Itoa(inx)})
+		}
+	}
+
+	acct, err := wzd.ask("this account, e.g. \"Assets:Current:Bank\"", "")
+	for err == nil && acct == "" {
+		acct, err = wzd.ask("this account is needed", "")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	stgs = append(stgs, setting{"thisacct", acct})
+
+	if inf.header != nil {
+		stgs = append(stgs, setting{"lines", "2-"})
+	}
+
+	for _, chr := range []struct {
+		name   string
+		val, d rune
+	}{{"delimiter", inf.dlc.delimiter, ','}, {"quote", inf.dlc.quote, '"'}, {"decimal", inf.dlc.decimal, '.'}} {
+		if chr.val != chr.d {
+			stgs = append(stgs, setting{chr.name, string(chr.val)})
+		}
+	}
+
+	return stgs, nil
+}
+
+/*
+Ask writes the question, with its default if any, and returns the answer, or the default if it is empty, and nil.
+If the reader ends or fails, ask returns an error.
+*/
+func (wzd *wizard) ask(question, def string) (string, error) {
+	if def == "" {
+		fmt.Fprintf(wzd.writer, "%v: ", question)
+	} else {
+		fmt.Fprintf(wzd.writer, "%v [%v]: ", question, def)
+	}
+
+	if !wzd.scanner.Scan() {
+		return "", errors.Join(errSetupInput, wzd.scanner.Err())
+	}
+
+	answer := strings.TrimSpace(wzd.scanner.Text())
+	if answer == "" {
+		return def, nil
+	}
+
+	return answer, nil
+}
+
+/*
+AskColumn asks the question until the answer is a column from 1 to the number of fields,
+or 0 if it is optional, and returns it and nil.
+If it fails to read an answer, askColumn returns an error.
+*/
+func (wzd *wizard) askColumn(question string, def, nFlds int, optional bool) (int, error) {
+	defStr := ""
+	if def != 0 || optional {
+		defStr = strconv.Itoa(def)
+	}
+
+	for {
+		answer, err := wzd.ask(question, defStr)
+		if err != nil {
+			return 0, err
+		}
+
+		inx, err := strconv.Atoi(answer)
+		if err == nil && ((optional && inx == 0) || (1 <= inx && inx <= nFlds)) {
+			return inx, nil
+		}
+
+		fmt.Fprintf(wzd.writer, "%q is not a column from 1 to %v\n", answer, nFlds)
+	}
+}
+
+/*
+AskDateFormat asks for the date format until it parses the dates, and returns it and nil.
+If it fails to read an answer, askDateFormat returns an error.
+*/
+func (wzd *wizard) askDateFormat(def string, dates []string) (string, error) {
+	for {
+		format, err := wzd.ask("date format, Go style e.g. \"02/01/2006\"", def)
+		if err != nil {
+			return "", err
+		}
+
+		inx := len(dates)
+
+		for jnx, date := range dates {
+			if _, err := time.Parse(format, date); err != nil {
+				inx = jnx
+
+				break
+			}
+		}
+
+		if format != "" && inx == len(dates) {
+			return format, nil
+		}
+
+		if format != "" {
+			fmt.Fprintf(wzd.writer, "date %q does not parse as %v\n", dates[inx], format)
+		}
+	}
+}
+
+/*
+PreviewTransacts writes the first transactions of the named statement file, translated according to
+the configuration, to the writer, so the configuration can be checked, and returns nil.
+If previewTransacts fails to read the statement, it returns an error.
+*/
+func previewTransacts(writer io.Writer, cfg config, name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	cfg.limit = setupNShown
+
+	fmt.Fprintln(writer, "first transactions:")
+
+	tlr := translator{cfg: cfg, write: func(trn *transact) { fmt.Fprintln(writer, trn.record(outputStandard)) }}
+
+	err = tlr.translateStatement(file)
+	if err != nil {
+		return fmt.Errorf("translator.translateStatement: %w", err)
+	}
+
+	return nil
+}
+
+/*
+SaveSettings asks for the name of the configuration file, with the default name, writes the settings to it
+in TOML, and returns nil.
+An existing file is only overwritten if the answer allows it.
+If it fails to read an answer or write the file, saveSettings returns an error.
+*/
+func (wzd *wizard) saveSettings(stgs []setting, def string) error {
+	name, err := wzd.ask("configuration file", def)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(name); err == nil {
+		answer, err := wzd.ask(name+" exists, overwrite it? (y/n)", "n")
+		if err != nil {
+			return err
+		}
+
+		if !strings.EqualFold(answer, "y") {
+			return nil
+		}
+	}
+
+	var bldr strings.Builder
+
+	fmt.Fprintf(&bldr, "# configuration of %v written by %v %v\n", pgmName, pgmName, setupCmd)
+
+	for _, stg := range stgs {
+		val := stg.val
+		if _, err := strconv.Atoi(val); err != nil {
+			val = tomlString(val)
+		}
+
+		fmt.Fprintf(&bldr, "%v = %v\n", stg.name, val)
+	}
+
+	const perm = 0o644
+
+	err = os.WriteFile(name, []byte(bldr.String()), perm)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(wzd.writer, "wrote %v, translate statements with: %v -%v %v statement.csv\n",
+		name, pgmName, configFlag, name)
+
+	return nil
+}
@@ -0,0 +1,211 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// A sheetConfig configures appending transactions to a Google Sheet.
+type sheetConfig struct {
+	apiURL      string // of the Sheets API, mandatory
+	credentials string // file of the service account key, mandatory e.g. "service-account.json"
+	id          string // of the spreadsheet, in its URL, mandatory
+	rng         string // of the sheet that transactions are appended to, mandatory e.g. "Sheet1"
+}
+
+// SheetCredentials are the fields used of a Google service account key file.
+type sheetCredentials struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+const (
+	apiTimeout   = 30 * time.Second
+	sheetsAPIURL = "https://sheets.googleapis.com/v4/spreadsheets/"
+	sheetsScope  = "https://www.googleapis.com/auth/spreadsheets"
+)
+
+var (
+	errAPIResponse = errors.New("web API response is not OK")
+	errSheetConfig = errors.New("sheet needs service account credentials and a range")
+	errSheetKey    = errors.New("service account private key is not an RSA key in PEM format")
+)
+
+/*
+AppendSheet appends the transactions to the Google Sheet as rows and returns nil.
+Each row has the fields of the standard format, then the extra fields, and amounts are numbers.
+It authorises as the service account, which the spreadsheet must be shared with.
+If appendSheet fails to authorise or append, it returns an error.
+*/
+func appendSheet(sheet sheetConfig, trns []transact) error {
+	if sheet.credentials == "" || sheet.rng == "" {
+		return errSheetConfig
+	}
+
+	token, err := sheetToken(sheet.credentials)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]any, len(trns))
+	for inx, trn := range trns {
+		rows[inx] = []any{trn.date, trn.thisAcct, trn.otherAcct, trn.memo, trn.amount, trn.currency}
+		for _, val := range trn.extras {
+			rows[inx] = append(rows[inx], val)
+		}
+	}
+
+	body, err := json.Marshal(map[string]any{"values": rows})
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sheet.apiURL+url.PathEscape(sheet.id)+"/values/"+
+		url.PathEscape(sheet.rng)+":append?valueInputOption=USER_ENTERED&insertDataOption=INSERT_ROWS",
+		bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("http.NewRequest: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = apiDo(req)
+
+	return err
+}
+
+/*
+SheetDo sends the request to a Google API and returns the body of the response and nil.
+If the request fails or the response is not OK, apiDo returns an error.
+*/
+func apiDo(req *http.Request) ([]byte, error) {
+	client := http.Client{Timeout: apiTimeout}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http.Client.Do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %v %s", errAPIResponse, resp.Status, bytes.TrimSpace(body))
+	}
+
+	return body, nil
+}
+
+/*
+SheetToken returns an access token for the Sheets API and nil.
+It is exchanged for a JSON web token signed with the private key of the service account in the named file.
+If sheetToken fails to read the file or exchange the token, it returns an error.
+*/
+func sheetToken(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	var creds sheetCredentials
+
+	err = json.Unmarshal(data, &creds)
+	if err != nil {
+		return "", fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", errSheetKey
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("x509.ParsePKCS8PrivateKey: %w", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return "", errSheetKey
+	}
+
+	now := time.Now()
+	claims, _ := json.Marshal(map[string]any{ // a map of strings and numbers cannot fail to marshal
+		"iss": creds.ClientEmail, "scope": sheetsScope, "aud": creds.TokenURI,
+		"iat": now.Unix(), "exp": now.Add(time.Hour).Unix(),
+	})
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("rsa.SignPKCS1v15: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + enc.EncodeToString(sig)},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, creds.TokenURI, bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("http.NewRequest: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	body, err := apiDo(req)
+	if err != nil {
+		return "", err
+	}
+
+	var tkn struct {
+		AccessToken string `json:"access_token"`
+	}
+
+	err = json.Unmarshal(body, &tkn)
+	if err != nil {
+		return "", fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	return tkn.AccessToken, nil
+}
@@ -0,0 +1,114 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	signExt   = ".hmac" // of the file of the signature of an output file
+	verifyCmd = "verify"
+)
+
+var (
+	errHMACKey    = errors.New("verify needs the key that signed the file, see hmackey")
+	errHMACOutput = errors.New("only an output file can be signed, see hmackey and outfile")
+	errTampered   = errors.New("file does not match its signature, it has been modified or the key is wrong")
+	errVerifyArgs = errors.New("verify needs the name of a signed output file")
+)
+
+// Sign returns the HMAC-SHA256 of the data keyed by the key, in hexadecimal.
+func sign(data []byte, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(data) // a hash never fails to write
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+WriteOutFile writes the data to the output file, and if there is a key, see hmackey,
+its signature to a file named for it with the signExt extension, and returns nil.
+If writeOutFile fails to write a file, it returns an error.
+*/
+func (tlr *translator) writeOutFile(data []byte) error {
+	err := tlr.cfg.cloud.writeFile(tlr.cfg.outFile, data)
+	if err != nil || tlr.cfg.hmacKey == "" {
+		return err
+	}
+
+	return tlr.cfg.cloud.writeFile(tlr.cfg.outFile+signExt, []byte(sign(data, tlr.cfg.hmacKey)+"\n"))
+}
+
+/*
+VerifyFile verifies that the output file named in the arguments matches its signature, see writeOutFile,
+writes that it does to the writer and returns nil.
+The arguments are flag hmackey then the name of the file.
+If the file does not match, or verifyFile fails to read it or its signature, it returns an error.
+*/
+func verifyFile(writer io.Writer, args []string) error {
+	fset := flag.NewFlagSet(verifyCmd, flag.ContinueOnError)
+	key := fset.String("hmackey", "", "key that signed the file, mandatory and can be in the OS keychain "+
+		"e.g. \"keychain:hmac\"")
+
+	err := fset.Parse(args)
+	if err != nil {
+		return fmt.Errorf("flag.FlagSet.Parse: %w", err)
+	}
+
+	if fset.NArg() != 1 {
+		return errVerifyArgs
+	}
+
+	*key, err = resolveSecret(*key)
+	if err != nil {
+		return err
+	} else if *key == "" {
+		return errHMACKey
+	}
+
+	name := fset.Arg(0)
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	sig, err := os.ReadFile(name + signExt)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(bytes.TrimSpace(sig), []byte(sign(data, *key))) {
+		return fmt.Errorf("%w: %v", errTampered, name)
+	}
+
+	fmt.Fprintf(writer, "%v: %v matches its signature\n", pgmName, name)
+
+	return nil
+}
@@ -0,0 +1,35 @@
+//go:build cgo
+
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"database/sql"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+/*
+The SQLite driver is linked into cas2trn where cgo is available, as it is built from the SQLite C source.
+The driver registers itself as "sqlite3", and is also registered as "sqlite", the default, see dbDriver.
+*/
+func init() {
+	sql.Register(dbDriver, &sqlite3.SQLiteDriver{})
+}
@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+/*
+A progress records how far each statement has been translated, by the number of its last line translated.
+Statements are named by their file names, and those in zip archives by the archive and entry names.
+It is kept in a state file, so an interrupted translation can resume where it left off.
+*/
+type progress map[string]int
+
+const (
+	doneLine  = -1   // of a statement that has been translated
+	saveEvery = 1000 // records translated between saves of the state file
+)
+
+var errStateFile = errors.New("state file line must be a line number and a file name separated by a tab")
+
+/*
+LoadProgress returns the progress read from the named state file and nil.
+If the file does not exist, the progress is empty.
+If loadProgress fails to read or parse the file, it returns an error.
+*/
+func loadProgress(name string) (progress, error) {
+	prg := make(progress)
+
+	file, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return prg, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scnr := bufio.NewScanner(file)
+	lineN := 0
+
+	for scnr.Scan() {
+		lineN++
+
+		num, stmt, ok := strings.Cut(scnr.Text(), "\t")
+
+		val, err := strconv.Atoi(num)
+		if !ok || err != nil || stmt == "" {
+			return nil, fmt.Errorf("%w on line %v", errStateFile, lineN)
+		}
+
+		prg[stmt] = val
+	}
+
+	err = scnr.Err()
+	if err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+
+	return prg, nil
+}
+
+/*
+Save writes the progress to the named state file and returns nil.
+The file is replaced by renaming a temporary file, so it is not left half written if cas2trn is interrupted.
+If save fails to write the file, it returns an error.
+*/
+func (prg progress) save(name string) error {
+	var bld strings.Builder
+
+	for _, stmt := range slices.Sorted(maps.Keys(prg)) {
+		fmt.Fprintf(&bld, "%v\t%v\n", prg[stmt], stmt)
+	}
+
+	tmp := name + ".tmp"
+
+	const perm = 0o600
+
+	err := os.WriteFile(tmp, []byte(bld.String()), perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
@@ -0,0 +1,175 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// Stats are statistics about the records read and transactions translated by cas2trn.
+type stats struct {
+	files       []*fileStats // of each statement read, in order
+	nDuplicates int          // transactions not written as they duplicate an earlier one
+	nFailed     int          // records that failed to parse as a transaction
+	nFiles      int          // statements read
+	nPending    int          // records of pending transactions not written, see config.statusI
+	nRecords    int
+	totals      map[string]*total // by currency
+	/*
+		The period covered by the statements, in ISO 8601 format.
+		It is that stated by the statements, if they do, else from the first to the last transaction date.
+	*/
+	firstDate, lastDate string
+	stmtFirst, stmtLast string
+}
+
+/*
+FileStats are statistics about the records read from one statement,
+so the one with problems can be found among many translated together.
+*/
+type fileStats struct {
+	name                string // of the statement, empty string for standard input
+	nFailed, nRecords   int
+	nTransacts          uint   // emitted
+	firstDate, lastDate string // of the transactions, in ISO 8601 format
+}
+
+// A total sums the amounts of transactions in one currency.
+type total struct {
+	credits, debits float64
+	nTransacts      int
+}
+
+// Add adds the transaction to the statistics.
+func (sts *stats) add(trn *transact) {
+	sts.firstDate, sts.lastDate = widen(sts.firstDate, sts.lastDate, trn.date, trn.date)
+
+	if sts.totals == nil {
+		sts.totals = make(map[string]*total)
+	}
+
+	tot := sts.totals[trn.currency]
+	if tot == nil {
+		tot = &total{}
+		sts.totals[trn.currency] = tot
+	}
+
+	tot.nTransacts++
+
+	if zero < trn.amount {
+		tot.credits += trn.amount
+	} else {
+		tot.debits += trn.amount
+	}
+}
+
+// AddPeriod adds the period, from first to last date in ISO 8601 format, stated by a statement.
+func (sts *stats) addPeriod(first, last string) {
+	sts.stmtFirst, sts.stmtLast = widen(sts.stmtFirst, sts.stmtLast, first, last)
+}
+
+/*
+Period returns the first and last dates of the period covered by the statements, and true.
+If there are no dates, period returns false.
+*/
+func (sts *stats) period() (string, string, bool) {
+	if sts.stmtFirst != "" {
+		return sts.stmtFirst, sts.stmtLast, true
+	}
+
+	return sts.firstDate, sts.lastDate, sts.firstDate != ""
+}
+
+/*
+Widen returns the period from first to last widened to include that from addFirst to addLast.
+The dates are in ISO 8601 format, which is sortable, and empty string for no period.
+*/
+func widen(first, last, addFirst, addLast string) (string, string) {
+	if first == "" {
+		return addFirst, addLast
+	}
+
+	return min(first, addFirst), max(last, addLast)
+}
+
+// NTransacts returns the number of transactions in all currencies.
+func (sts *stats) nTransacts() int {
+	nTransacts := 0
+	for _, tot := range sts.totals {
+		nTransacts += tot.nTransacts
+	}
+
+	return nTransacts
+}
+
+/*
+Write writes the statistics to the writer.
+Totals are written for each currency, as summing amounts in different currencies is meaningless.
+If several statements were read, the statistics of each are written too.
+*/
+func (sts *stats) write(writer io.Writer) {
+	fmt.Fprintf(writer, "%v: %v records, %v transactions, %v failed\n",
+		pgmName, sts.nRecords, sts.nTransacts(), sts.nFailed)
+
+	if first, last, ok := sts.period(); ok {
+		fmt.Fprintf(writer, "%v: period %v to %v\n", pgmName, first, last)
+	}
+
+	if sts.nDuplicates != 0 {
+		fmt.Fprintf(writer, "%v: %v duplicates\n", pgmName, sts.nDuplicates)
+	}
+
+	if sts.nPending != 0 {
+		fmt.Fprintf(writer, "%v: %v pending skipped\n", pgmName, sts.nPending)
+	}
+
+	for _, cur := range slices.Sorted(maps.Keys(sts.totals)) {
+		tot := sts.totals[cur]
+
+		if cur == "" {
+			cur = "no currency"
+		}
+
+		fmt.Fprintf(writer, "%v: %v: %v transactions, credits %.2f, debits %.2f, net %.2f\n",
+			pgmName, cur, tot.nTransacts, tot.credits, tot.debits, tot.credits+tot.debits)
+	}
+
+	if len(sts.files) < 2 {
+		return
+	}
+
+	for _, fst := range sts.files {
+		name := fst.name
+		if name == "" {
+			name = "standard input"
+		}
+
+		fmt.Fprintf(writer, "%v: %v: %v records, %v transactions, %v failed", pgmName, name, fst.nRecords,
+			fst.nTransacts, fst.nFailed)
+
+		if fst.firstDate != "" {
+			fmt.Fprintf(writer, ", period %v to %v", fst.firstDate, fst.lastDate)
+		}
+
+		fmt.Fprintln(writer)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// MaxSampled is the maximum number of failed records kept to suggest configuration fixes from.
+const maxSampled = 100
+
+// DateFormats are the date formats that suggestions try, in Go style and most common first.
+var dateFormats = []string{
+	"02/01/2006", "01/02/2006", "2006-01-02", "02-01-2006", "01-02-2006", "2006/01/02", "02.01.2006",
+	"2/1/2006", "1/2/2006", "02/01/06", "01/02/06", "20060102", "02 Jan 2006", "2 Jan 2006", "Jan 2, 2006",
+	"2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02T15:04:05",
+}
+
+/*
+GuessDateFormat returns the first of dateFormats that parses most of the values, and true.
+If none does, guessDateFormat returns false.
+*/
+func guessDateFormat(vals []string) (string, bool) {
+	for _, format := range dateFormats {
+		if isMostly(vals, func(val string) bool {
+			_, err := time.Parse(format, val)
+
+			return err == nil
+		}) {
+			return format, true
+		}
+	}
+
+	return "", false
+}
+
+// IsMostly returns true if the test is true for more than half of the values.
+func isMostly(vals []string, test func(val string) bool) bool {
+	nTrue := 0
+
+	for _, val := range vals {
+		if test(val) {
+			nTrue++
+		}
+	}
+
+	return len(vals) < 2*nTrue
+}
+
+// IsNumber returns true if the value parses as an amount.
+func isNumber(val string) bool {
+	_, err := parseFloat64(val)
+
+	return err == nil
+}
+
+/*
+Suggest returns suggested fixes to the configuration, from the records that failed to parse as transactions.
+Field values are analysed to find a date format that parses the dates,
+or fields that look like dates or amounts when the configured ones do not.
+*/
+func (cfg *config) suggest(failed [][]string) []string {
+	recs := slices.DeleteFunc(slices.Clone(failed), func(rec []string) bool {
+		return len(rec) != int(cfg.nFields)
+	})
+	if len(recs) == 0 {
+		nRecs := make(map[int]int) // by number of fields
+		for _, rec := range failed {
+			nRecs[len(rec)]++
+		}
+
+		nFlds := slices.MaxFunc(slices.Collect(maps.Keys(nRecs)), func(a, b int) int { return nRecs[a] - nRecs[b] })
+
+		return []string{fmt.Sprintf("records have %v fields, not %v, see nfields", nFlds, cfg.nFields)}
+	}
+
+	column := func(inx uint8) []string {
+		vals := make([]string, len(recs))
+		for jnx, rec := range recs {
+			vals[jnx] = rec[inx-1]
+		}
+
+		return vals
+	}
+
+	// otherColumn returns the index of the first field, other than those given, whose values mostly pass the test.
+	otherColumn := func(test func(vals []string) bool, not ...uint8) (uint8, bool) {
+		for inx := uint8(1); inx <= cfg.nFields; inx++ {
+			if !slices.Contains(not, inx) && test(column(inx)) {
+				return inx, true
+			}
+		}
+
+		return 0, false
+	}
+
+	isDates := func(vals []string) bool {
+		_, ok := guessDateFormat(vals)
+
+		return ok
+	}
+
+	isNumbers := func(vals []string) bool { return isMostly(vals, isNumber) }
+
+	var sgns []string
+
+	dates := column(cfg.dateI)
+	if !isMostly(dates, func(val string) bool {
+		_, err := time.Parse(cfg.dateFormat, val)
+
+		return err == nil
+	}) {
+		if format, ok := guessDateFormat(dates); ok {
+			sgns = append(sgns, fmt.Sprintf("date field %v looks like %v, not %v, see dateformat",
+				cfg.dateI, format, cfg.dateFormat))
+		} else if inx, ok := otherColumn(isDates, cfg.dateI); ok {
+			sgns = append(sgns, fmt.Sprintf("field %v looks like dates but date field %v does not, see datei",
+				inx, cfg.dateI))
+		}
+	}
+
+	if cfg.amountI != 0 {
+		if !isNumbers(column(cfg.amountI)) {
+			if inx, ok := otherColumn(isNumbers, cfg.amountI); ok {
+				sgns = append(sgns, fmt.Sprintf("field %v looks like amounts but amount field %v does not, see amounti",
+					inx, cfg.amountI))
+			}
+		}
+
+		return sgns
+	}
+
+	credits, debits := column(cfg.creditI), column(cfg.debitI)
+	bothEmpty, bothSet := 0, 0
+
+	for inx := range recs {
+		switch {
+		case credits[inx] == "" && debits[inx] == "":
+			bothEmpty++
+		case credits[inx] != "" && debits[inx] != "":
+			bothSet++
+		}
+	}
+
+	switch {
+	case len(recs) < 2*bothEmpty:
+		if inx, ok := otherColumn(isNumbers, cfg.creditI, cfg.debitI); ok {
+			sgns = append(sgns, fmt.Sprintf("credit field %v and debit field %v are empty "+
+				"but field %v looks like amounts, see amounti", cfg.creditI, cfg.debitI, inx))
+		}
+	case len(recs) < 2*bothSet:
+		sgns = append(sgns, fmt.Sprintf("credit field %v and debit field %v are both set, "+
+			"is one of them a balance? see crediti and debiti", cfg.creditI, cfg.debitI))
+	}
+
+	return sgns
+}
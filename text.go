@@ -0,0 +1,408 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:generate go run normtables_gen.go
+
+// A combiningClass is the canonical combining class of a range of characters, see normtables.go.
+type combiningClass struct {
+	first, last rune
+	class       uint8
+}
+
+// The constants of the algorithm for composing and decomposing Hangul syllables.
+const (
+	hangulSBase  = 0xac00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11a7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+/*
+The Windows-1252 characters for bytes 0x80 to 0x9f.
+Bytes undefined in Windows-1252 map to the C1 control character of the same value.
+The other bytes from 0xa0 to 0xff are the same in Windows-1252 and Unicode.
+*/
+var cp1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\u008d', 'Ž', '\u008f',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\u009d', 'ž', 'Ÿ',
+}
+
+// PlainReplacer replaces typographic characters with their plain ASCII equivalents.
+var plainReplacer = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'",
+	"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`,
+	"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-", "―", "-", "−", "-",
+	"…", "...", "•", "*", "\u00a0", " ", "\u200b", "", "\ufeff", "",
+)
+
+/*
+LatinLetters maps Cyrillic and Greek letters, and Latin letters without a canonical decomposition,
+to Latin letters, following the BGN/PCGN romanisations simplified to ASCII.
+*/
+var latinLetters = map[rune]string{
+	'А': "A", 'Б': "B", 'В': "V", 'Г': "G", 'Ґ': "G", 'Д': "D", 'Е': "E", 'Є': "Ye", 'Ё': "Yo", 'Ж': "Zh",
+	'З': "Z", 'И': "I", 'І': "I", 'Ї': "Yi", 'Й': "Y", 'К': "K", 'Л': "L", 'М': "M", 'Н': "N", 'О': "O",
+	'П': "P", 'Р': "R", 'С': "S", 'Т': "T", 'У': "U", 'Ф': "F", 'Х': "Kh", 'Ц': "Ts", 'Ч': "Ch", 'Ш': "Sh",
+	'Щ': "Shch", 'Ъ': "", 'Ы': "Y", 'Ь': "", 'Э': "E", 'Ю': "Yu", 'Я': "Ya",
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'ґ': "g", 'д': "d", 'е': "e", 'є': "ye", 'ё': "yo", 'ж': "zh",
+	'з': "z", 'и': "i", 'і': "i", 'ї': "yi", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh",
+	'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'Α': "A", 'Β': "V", 'Γ': "G", 'Δ': "D", 'Ε': "E", 'Ζ': "Z", 'Η': "I", 'Θ': "Th", 'Ι': "I", 'Κ': "K",
+	'Λ': "L", 'Μ': "M", 'Ν': "N", 'Ξ': "X", 'Ο': "O", 'Π': "P", 'Ρ': "R", 'Σ': "S", 'Τ': "T", 'Υ': "Y",
+	'Φ': "F", 'Χ': "Ch", 'Ψ': "Ps", 'Ω': "O",
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i", 'κ': "k",
+	'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t",
+	'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'Æ': "AE", 'æ': "ae", 'Đ': "D", 'đ': "d", 'Ł': "L", 'ł': "l", 'Ø': "O", 'ø': "o", 'Œ': "OE", 'œ': "oe",
+	'ß': "ss", 'Þ': "Th", 'þ': "th",
+}
+
+// Compositions maps pairs of characters to the character they canonically compose.
+var compositions = func() map[[2]rune]rune {
+	comps := make(map[[2]rune]rune, len(decompositions))
+
+	for r, dec := range decompositions {
+		if dec[1] != 0 && !slices.Contains(compositionExclusions, r) && combining(dec[0]) == 0 {
+			comps[dec] = r
+		}
+	}
+
+	return comps
+}()
+
+/*
+CanonicalOrder sorts each run of combining characters by their canonical combining class.
+Characters with the same class keep their order.
+*/
+func canonicalOrder(runes []rune) {
+	for inx := 1; inx < len(runes); inx++ {
+		cls := combining(runes[inx])
+		if cls == 0 {
+			continue
+		}
+
+		for jnx := inx; 0 < jnx && cls < combining(runes[jnx-1]); jnx-- {
+			runes[jnx-1], runes[jnx] = runes[jnx], runes[jnx-1]
+		}
+	}
+}
+
+// Combining returns the canonical combining class of the character.
+func combining(r rune) uint8 {
+	inx := sort.Search(len(combiningClasses), func(inx int) bool {
+		return r <= combiningClasses[inx].last
+	})
+	if inx < len(combiningClasses) && combiningClasses[inx].first <= r {
+		return combiningClasses[inx].class
+	}
+
+	return 0
+}
+
+/*
+Compose canonically composes the characters, which must be decomposed and in canonical order.
+A character composes with the last starter before it, unless a character between them blocks it.
+*/
+func compose(runes []rune) []rune {
+	out := make([]rune, 0, len(runes))
+	starter, lastCls := -1, uint8(0)
+
+	for _, r := range runes {
+		cls := combining(r)
+
+		if 0 <= starter && ((lastCls == 0 && starter == len(out)-1) || (lastCls != 0 && lastCls < cls)) {
+			comp, ok := composePair(out[starter], r)
+			if ok {
+				out[starter] = comp
+
+				continue
+			}
+		}
+
+		if cls == 0 {
+			starter = len(out)
+		}
+
+		out = append(out, r)
+		lastCls = cls
+	}
+
+	return out
+}
+
+// ComposePair returns the character canonically composed of the pair of characters and true.
+func composePair(first, second rune) (rune, bool) {
+	lInx, vInx := first-hangulLBase, second-hangulVBase
+	if 0 <= lInx && lInx < hangulLCount && 0 <= vInx && vInx < hangulVCount {
+		return hangulSBase + (lInx*hangulVCount+vInx)*hangulTCount, true
+	}
+
+	sInx, tInx := first-hangulSBase, second-hangulTBase
+	if 0 <= sInx && sInx < hangulSCount && sInx%hangulTCount == 0 && 0 < tInx && tInx < hangulTCount {
+		return first + tInx, true
+	}
+
+	comp, ok := compositions[[2]rune{first, second}]
+
+	return comp, ok
+}
+
+// Decompose appends the full canonical decomposition of the character to the buffer.
+func decompose(buf []rune, r rune) []rune {
+	sInx := r - hangulSBase
+	if 0 <= sInx && sInx < hangulSCount {
+		buf = append(buf, hangulLBase+sInx/hangulNCount, hangulVBase+(sInx%hangulNCount)/hangulTCount)
+		if tInx := sInx % hangulTCount; tInx != 0 {
+			buf = append(buf, hangulTBase+tInx)
+		}
+
+		return buf
+	}
+
+	dec, ok := decompositions[r]
+	if !ok {
+		return append(buf, r)
+	}
+
+	buf = decompose(buf, dec[0])
+	if dec[1] != 0 {
+		buf = decompose(buf, dec[1])
+	}
+
+	return buf
+}
+
+/*
+ExtendsGrapheme returns true if the character extends the grapheme cluster of the previous character.
+It is a simplification of the Unicode rules that covers combining marks, joined emoji and modifiers.
+*/
+func extendsGrapheme(prev, r rune) bool {
+	const (
+		zwj            = '\u200d'
+		emojiModFirst  = 0x1f3fb
+		emojiModLast   = 0x1f3ff
+		tagFirst       = 0xe0020
+		tagLast        = 0xe007f
+		varSelFirst    = 0xfe00
+		varSelLast     = 0xfe0f
+		varSelSupFirst = 0xe0100
+		varSelSupLast  = 0xe01ef
+	)
+
+	switch {
+	case prev == zwj, r == zwj:
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case varSelFirst <= r && r <= varSelLast, varSelSupFirst <= r && r <= varSelSupLast:
+		return true
+	case emojiModFirst <= r && r <= emojiModLast, tagFirst <= r && r <= tagLast:
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+FixMojibake returns the text with mojibake undone.
+Mojibake is UTF-8 text that was wrongly decoded as Windows-1252 (or Latin-1), such as "â€™" for "’".
+Each run of non-ASCII characters is undone separately.
+If a run is not mojibake, fixMojibake leaves it unchanged.
+*/
+func fixMojibake(text string) string {
+	var sbr strings.Builder
+
+	for text != "" {
+		inx := strings.IndexFunc(text, func(r rune) bool { return utf8.RuneSelf <= r })
+		if inx < 0 {
+			inx = len(text)
+		}
+
+		sbr.WriteString(text[:inx])
+		text = text[inx:]
+
+		inx = strings.IndexFunc(text, func(r rune) bool { return r < utf8.RuneSelf })
+		if inx < 0 {
+			inx = len(text)
+		}
+
+		sbr.WriteString(undoMojibake(text[:inx]))
+		text = text[inx:]
+	}
+
+	return sbr.String()
+}
+
+/*
+UndoMojibake returns the run of non-ASCII characters with mojibake undone.
+The characters are encoded as Windows-1252 bytes, and if the bytes are valid UTF-8 they are decoded.
+If not, undoMojibake returns the run unchanged.
+*/
+func undoMojibake(run string) string {
+	const (
+		c1Min   = 0x80
+		byteMax = 0xff
+	)
+
+	buf := make([]byte, 0, len(run))
+
+	for _, r := range run {
+		if r <= byteMax {
+			buf = append(buf, byte(r))
+
+			continue
+		}
+
+		inx := slices.Index(cp1252[:], r)
+		if inx < 0 {
+			return run
+		}
+
+		buf = append(buf, byte(c1Min+inx))
+	}
+
+	if !utf8.Valid(buf) {
+		return run
+	}
+
+	return string(buf)
+}
+
+/*
+PlainText returns the text with mojibake undone,
+and typographic quotes, dashes and other characters replaced by plain ASCII equivalents.
+Stray C1 control characters are treated as the Windows-1252 characters of the same value.
+*/
+func plainText(text string) string {
+	text = strings.Map(func(r rune) rune {
+		const c1Min, c1Max = 0x80, 0x9f
+
+		if c1Min <= r && r <= c1Max {
+			return cp1252[r-c1Min]
+		}
+
+		return r
+	}, fixMojibake(text))
+
+	return plainReplacer.Replace(text)
+}
+
+/*
+Latin returns the text transliterated to the Latin alphabet, so memos from foreign banks match and dedupe consistently.
+Cyrillic and Greek letters are romanised, see latinLetters, and accents are removed, e.g. "Café" becomes "Cafe".
+Characters of other scripts are kept.
+*/
+func latin(text string) string {
+	if !strings.ContainsFunc(text, func(r rune) bool { return utf8.RuneSelf <= r }) {
+		return text // ASCII is already Latin
+	}
+
+	var bldr strings.Builder
+
+	for _, r := range text {
+		if str, ok := latinLetters[r]; ok {
+			bldr.WriteString(str)
+
+			continue
+		} else if hangulSBase <= r && r < hangulSBase+hangulSCount {
+			bldr.WriteRune(r)
+
+			continue
+		}
+
+		for _, dec := range decompose(nil, r) {
+			if str, ok := latinLetters[dec]; ok {
+				bldr.WriteString(str)
+			} else if combining(dec) == 0 {
+				bldr.WriteRune(dec)
+			}
+		}
+	}
+
+	return bldr.String()
+}
+
+/*
+Nfc returns the text in Unicode normalisation form C (NFC).
+Characters are canonically decomposed, ordered, then composed,
+so text that mixes composed and decomposed characters compares equal.
+*/
+func nfc(text string) string {
+	if !strings.ContainsFunc(text, func(r rune) bool { return utf8.RuneSelf <= r }) {
+		return text // ASCII is already normalised
+	}
+
+	runes := make([]rune, 0, len(text))
+	for _, r := range text {
+		runes = decompose(runes, r)
+	}
+
+	canonicalOrder(runes)
+
+	return string(compose(runes))
+}
+
+/*
+Truncate returns the text truncated to at most maxLen characters.
+If the text is longer, it is cut at a grapheme cluster boundary and the ellipsis appended,
+so a letter is never separated from its accents.
+*/
+func truncate(text string, maxLen uint, ellipsis string) string {
+	if uint(utf8.RuneCountInString(text)) <= maxLen {
+		return text
+	}
+
+	var (
+		cut, nRunes uint
+		prev        rune
+	)
+
+	ellLen := uint(utf8.RuneCountInString(ellipsis))
+
+	// Find the last boundary that leaves room for the ellipsis.
+	for inx, r := range text {
+		if nRunes+ellLen > maxLen {
+			break
+		}
+
+		if !extendsGrapheme(prev, r) {
+			cut = uint(inx)
+		}
+
+		prev = r
+		nRunes++
+	}
+
+	return text[:cut] + ellipsis
+}
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
@@ -33,56 +34,196 @@ A transact represents a financial transaction in CSV format.
 Most of the fields are mandatory so must be non-zero or not empty string.
 */
 type transact struct {
+	amount     float64
+	currency   string // optional, can be empty string
+	date       string
+	memo       string
+	otherAcct  string // optional, can be empty string
+	thisAcct   string
+	extraNames []string // of the extra fields, in output order
+	extras     []string // optional fields added by configuration, can be empty string
+	/*
+		TaxRate is the percentage of tax included in amount, and taxAcct is the other account for the tax.
+		They are optional, not output, and set by rules.
+		If they are not empty string, the transaction is split into net and tax transactions.
+	*/
+	taxRate, taxAcct string
+	/*
+		SplitCredit is the credit of a record with both credit and debit, when the both policy is bothSplit.
+		It is optional, not output, and if non-zero the transaction is split into debit and credit transactions.
+	*/
+	splitCredit float64
+	/*
+		Fee is the fee of a record, when the fee policy is feeSplit, and feeAcct is the other account for it.
+		They are optional, not output, and if fee is non-zero the transaction is split into it and a fee transaction.
+	*/
+	fee     float64
+	feeAcct string
+	/*
+		Parts are the parts of the amount by other account, such as the principal and interest of a loan payment.
+		They are optional, not output, and if there are any the transaction is split into a transaction for each.
+	*/
+	parts []part
+}
+
+// A part is a part of the amount of a transaction, with its own other account.
+type part struct {
 	amount    float64
-	currency  string // optional, can be empty string
-	date      string
-	memo      string
-	otherAcct string // optional, can be empty string
-	thisAcct  string
+	otherAcct string
+}
+
+// The policies for records with both credit and debit, besides policyError.
+const (
+	bothCredit = "credit" // take the credit and ignore the debit
+	bothDebit  = "debit"  // take the debit and ignore the credit
+	bothNet    = "net"    // take the credit less the debit
+	bothSplit  = "split"  // emit debit and credit transactions
+)
+
+// The policies for fees, see config.feeI.
+const (
+	feeAdd   = "add"   // add the fee to the amount
+	feeSplit = "split" // emit a fee transaction to the fee account
+)
+
+// The policies for the script of memos, see config.memoScript.
+const (
+	memoLatin    = "latin"    // transliterate memos to the Latin alphabet, see latin
+	memoPreserve = "preserve" // keep memos in their own script
+)
+
+// The policies for pending transactions, see config.statusI.
+const (
+	pendingInclude = "include" // output them with field status
+	pendingSkip    = "skip"    // do not output them, as they are output again once posted
+)
+
+const (
+	chequeName        = "cheque"       // of the extra field for cheque numbers
+	confidenceName    = "confidence"   // of the extra field for the probability a suggested other account is right
+	daySequenceName   = "daysequence"  // of the extra field for sequence numbers within a date
+	documentName      = "document"     // of the extra field for document references
+	outputArrow       = "arrow"        // output format, see writeArrow
+	outputDebitCredit = "debitcredit"  // output format with debit and credit fields instead of amount
+	outputExcelCSV    = "excel-csv"    // output format, see transact.excelRecord
+	outputParquet     = "parquet"      // output format, see writeParquet
+	outputStandard    = "standard"     // output format, see transact.string
+	origAmountName    = "origamount"   // of the extra field for the amount before currency conversion
+	origCurrencyName  = "origcurrency" // of the extra field for the currency before conversion
+	outputXLSX        = "xlsx"         // output format, see writeXLSX
+	roundHalfEven     = "halfeven"     // rounding mode, also known as banker's rounding
+	roundHalfUp       = "halfup"       // rounding mode, rounding halves away from zero
+	sequenceName      = "sequence"     // of the extra field for sequence numbers
+	statusName        = "status"       // of the extra field for pending or posted status
+	zero              = 0.00
+)
+
+/*
+CurrencyExponents are the numbers of decimal places of the minor units of ISO 4217 currencies, by their codes,
+where that is not two.
+*/
+var currencyExponents = map[string]int{
+	"BHD": 3, "BIF": 0, "CLF": 4, "CLP": 0, "DJF": 0, "GNF": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0,
+	"KMF": 0, "KRW": 0, "KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0, "RWF": 0, "TND": 3, "UGX": 0, "UYI": 0,
+	"UYW": 4, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
 }
 
-const zero = 0.00
+// StrictNumberPattern matches amounts that are digits, with an optional sign and decimal point.
+var strictNumberPattern = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)$`)
+
+// TemplatePattern matches the field names in braces in a template e.g. "{date}".
+var templatePattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// NumberLikePattern matches account numbers that Excel would mangle into a number, date or formula.
+var numberLikePattern = regexp.MustCompile(`^[\d\s./+-]+$`)
 
 var (
 	errAmount      = errors.New("amount cannot be zero")
 	errCreditDebit = errors.New("credit and debit cannot both be empty string or non-empty string")
+	errDCMark      = errors.New("debit credit indicator must be the debit or credit mark")
 	errMemo        = errors.New("memo cannot be empty string")
+	errMaxAmount   = errors.New("amount must be a finite number within the maximum amount, see maxamount")
+	errMinorUnits  = errors.New("amount in minor units must be a whole number e.g. \"16250\"")
 	errNFields     = errors.New("wrong number of fields")
+	errNumber      = errors.New("amount must be digits with an optional sign and decimal separator")
+	errTaxAcct     = errors.New("tax account cannot be empty string when tax rate is set")
+	errTemplate    = errors.New("template refers to a field the transaction does not have")
+	errTaxRate     = errors.New("tax rate must be a positive percentage e.g. \"15\"")
 	errThisAcct    = errors.New("this account cannot be empty string")
 )
 
 /*
-ParseAmount returns the amount of this transaction and nil.
-It looks for an amount in the amount, credit or debit fields.
+ParseAmount returns the amount of this transaction, the split credit and nil.
+It looks for an amount in the amount, credit or debit fields, with the decimal separator of the dialect.
+If there is an amount pattern, the amount is extracted from each field.
+If there is a debit credit indicator field, it gives the sign of the amount.
+If both credit and debit are not empty string, the both policy decides the amount,
+and the split credit is non-zero only if the policy is bothSplit and both are non-zero.
 ParseAmount assumes the configuration is valid.
 If it fails to find or parse an amount, parseAmount returns an error.
 */
-func parseAmount(fields []string, cfg config) (float64, error) {
+func parseAmount(fields []string, cfg config) (float64, float64, error) {
 	amt, crt, dbt := fields[cfg.amountI], fields[cfg.creditI], fields[cfg.debitI]
+	amt, crt, dbt = extract(cfg.amountPattern, amt), extract(cfg.amountPattern, crt), extract(cfg.amountPattern, dbt)
+	amt, crt, dbt = cfg.dialect.number(amt), cfg.dialect.number(crt), cfg.dialect.number(dbt)
+
+	const minus1 = -1.00
 
 	switch {
+	case amt != "" && cfg.dcI != 0:
+		val, err := parseMoney(amt, cfg.laxNumbers)
+		mark := strings.TrimSpace(fields[cfg.dcI])
+
+		switch {
+		case strings.EqualFold(mark, cfg.debitMark):
+			return math.Abs(val) * minus1, zero, err
+		case strings.EqualFold(mark, cfg.creditMark):
+			return math.Abs(val), zero, err
+		default:
+			return zero, zero, errDCMark
+		}
 	case amt != "":
-		return parseFloat64(amt)
-	case crt != "" && dbt == "":
-		return parseFloat64(crt)
-	case dbt != "" && crt == "":
-		val, err := parseFloat64(dbt)
+		val, err := parseMoney(amt, cfg.laxNumbers)
+
+		return val, zero, err
+	case crt != "" && dbt == "", crt != "" && cfg.bothPolicy == bothCredit:
+		val, err := parseMoney(crt, cfg.laxNumbers)
 
-		const minus1 = -1.00
+		return val, zero, err
+	case dbt != "" && crt == "", dbt != "" && cfg.bothPolicy == bothDebit:
+		val, err := parseMoney(dbt, cfg.laxNumbers)
 
-		return math.Abs(val) * minus1, err
+		return math.Abs(val) * minus1, zero, err
+	case crt != "" && dbt != "" && (cfg.bothPolicy == bothNet || cfg.bothPolicy == bothSplit):
+		crtVal, err := parseMoney(crt, cfg.laxNumbers)
+		if err != nil {
+			return zero, zero, err
+		}
+
+		dbtVal, err := parseMoney(dbt, cfg.laxNumbers)
+		if err != nil {
+			return zero, zero, err
+		}
+
+		crtVal, dbtVal = math.Abs(crtVal), math.Abs(dbtVal)*minus1
+		if cfg.bothPolicy == bothNet || crtVal == zero || dbtVal == zero {
+			return crtVal + dbtVal, zero, nil
+		}
+
+		return dbtVal, crtVal, nil
 	default:
-		return zero, errCreditDebit
+		return zero, zero, errCreditDebit
 	}
 }
 
 /*
 ParseDate returns the date of this transaction and nil.
+If there is a date pattern, the date is extracted from the date field.
 It assumes the configuration is valid.
 If it fails to parse a date, parseDate returns an error.
 */
 func parseDate(fields []string, cfg config) (string, error) {
-	val, err := time.Parse(cfg.dateFormat, fields[cfg.dateI])
+	val, err := time.Parse(cfg.dateFormat, extract(cfg.datePattern, fields[cfg.dateI]))
 	if err != nil {
 		return "", fmt.Errorf("parseDate: %w", err)
 	}
@@ -90,6 +231,66 @@ func parseDate(fields []string, cfg config) (string, error) {
 	return val.Format(time.DateOnly), nil
 }
 
+/*
+ParsePeriod returns the first and last dates of the statement period in the record, and true.
+The period pattern matches them in the fields joined by spaces, by its first two groups,
+and they are parsed with the date format.
+If the record does not state a period, parsePeriod returns false.
+*/
+func parsePeriod(fields []string, cfg config) (string, string, bool) {
+	match := cfg.periodPattern.FindStringSubmatch(strings.Join(fields, " "))
+	if len(match) < 3 {
+		return "", "", false
+	}
+
+	first, err := time.Parse(cfg.dateFormat, strings.TrimSpace(match[1]))
+	if err != nil {
+		return "", "", false
+	}
+
+	last, err := time.Parse(cfg.dateFormat, strings.TrimSpace(match[2]))
+	if err != nil {
+		return "", "", false
+	}
+
+	return first.Format(time.DateOnly), last.Format(time.DateOnly), true
+}
+
+/*
+Extract returns the part of the value matched by the pattern's first group, or by the whole pattern if it has none.
+If the pattern is nil, or does not match, extract returns the value unchanged.
+*/
+func extract(pattern *regexp.Regexp, val string) string {
+	if pattern == nil || val == "" {
+		return val
+	}
+
+	match := pattern.FindStringSubmatch(val)
+	if match == nil {
+		return val
+	}
+
+	return match[min(1, len(match)-1)]
+}
+
+/*
+MatchAcct returns the account matched by the pattern in the name,
+by the group named acct, the first group, or the whole pattern.
+If the pattern does not match, matchAcct returns empty string.
+*/
+func matchAcct(pattern *regexp.Regexp, name string) string {
+	match := pattern.FindStringSubmatch(name)
+	if match == nil {
+		return ""
+	}
+
+	if inx := pattern.SubexpIndex("acct"); 0 < inx {
+		return match[inx]
+	}
+
+	return match[min(1, len(match)-1)]
+}
+
 /*
 ParseFloat64 returns the float64 value parsed from the string and nil.
 If it fails to parse a value, parseFloat64 returns an error.
@@ -103,16 +304,468 @@ func parseFloat64(float string) (float64, error) {
 	return val, nil
 }
 
-// String returns the transaction in the standard CSV format.
+// CurrencyExponent returns the number of decimal places of the minor units of the currency, two if it is unknown.
+func currencyExponent(currency string) int {
+	if exp, ok := currencyExponents[strings.ToUpper(currency)]; ok {
+		return exp
+	}
+
+	const defaultExponent = 2
+
+	return defaultExponent
+}
+
+/*
+SetFee folds the fee into this transaction according to the fee policy, and returns nil.
+A fee is a debit whatever its sign, and is added to the amount unless the policy is feeSplit.
+If the fee is empty string, setFee does nothing.
+If it fails to parse the fee, setFee returns an error.
+*/
+func (trn *transact) setFee(fee string, cfg config) error {
+	fee = cfg.dialect.number(extract(cfg.amountPattern, fee))
+	if fee == "" {
+		return nil
+	}
+
+	val, err := parseMoney(fee, cfg.laxNumbers)
+	if err != nil {
+		return err
+	}
+
+	if cfg.minorUnits {
+		val /= math.Pow10(currencyExponent(trn.currency))
+	}
+
+	const minus1 = -1.00
+
+	val = math.Abs(val) * minus1
+	if cfg.feePolicy == feeSplit {
+		trn.fee, trn.feeAcct = val, cfg.feeAcct
+	} else {
+		trn.amount += val
+	}
+
+	return nil
+}
+
+/*
+SetLoanParts sets the principal, interest and escrow parts of this loan payment, from the fields, and returns nil.
+The parts have the sign of the amount, as statements often show them unsigned.
+Parts whose field index is zero, or whose field is empty string, are left out.
+If it fails to parse a part, setLoanParts returns an error.
+*/
+func (trn *transact) setLoanParts(fields []string, cfg config) error {
+	for _, prt := range []struct {
+		inx  uint8
+		acct string
+	}{{cfg.principalI, cfg.principalAcct}, {cfg.interestI, cfg.interestAcct}, {cfg.escrowI, cfg.escrowAcct}} {
+		amt := cfg.dialect.number(extract(cfg.amountPattern, fields[prt.inx]))
+		if prt.inx == 0 || amt == "" {
+			continue
+		}
+
+		val, err := parseMoney(amt, cfg.laxNumbers)
+		if err != nil {
+			return err
+		}
+
+		if cfg.minorUnits {
+			val /= math.Pow10(currencyExponent(trn.currency))
+		}
+
+		trn.parts = append(trn.parts, part{amount: math.Copysign(math.Abs(val), trn.amount), otherAcct: prt.acct})
+	}
+
+	return nil
+}
+
+/*
+SetOriginal sets the original amount and currency fields of this transaction, from before currency conversion,
+and returns nil.
+The original amount has the sign of the amount, as statements often show it unsigned,
+and is in minor units of the original currency, or else of the currency, if the amount is.
+If the original amount is empty string, such as for purchases in the account's currency, both fields are left empty.
+If it fails to parse the original amount, setOriginal returns an error.
+*/
+func (trn *transact) setOriginal(amt, cur string, cfg config) error {
+	amt = cfg.dialect.number(extract(cfg.amountPattern, amt))
+	if amt == "" {
+		return nil
+	}
+
+	val, err := parseMoney(amt, cfg.laxNumbers)
+	if err != nil {
+		return err
+	}
+
+	cur = strings.TrimSpace(cur)
+
+	if cfg.minorUnits {
+		if val != math.Trunc(val) {
+			return errMinorUnits
+		}
+
+		exp := currencyExponent(trn.currency)
+		if cur != "" {
+			exp = currencyExponent(cur)
+		}
+
+		val /= math.Pow10(exp)
+	}
+
+	*trn.field(origAmountName) = strconv.FormatFloat(math.Copysign(math.Abs(val), trn.amount), 'f', -1, 64)
+	*trn.field(origCurrencyName) = cur
+
+	return nil
+}
+
+/*
+IsPending returns true if the record, without a prepended empty field, is of a pending transaction.
+*/
+func (cfg *config) isPending(fields []string) bool {
+	return cfg.statusI != 0 && int(cfg.statusI) <= len(fields) &&
+		strings.EqualFold(strings.TrimSpace(fields[cfg.statusI-1]), cfg.pendingMark)
+}
+
+/*
+ParseMoney returns the amount of money parsed from the string, with a point decimal separator, and nil.
+Unless lax, the string must be digits with an optional sign and decimal point,
+so corrupted amounts such as "1e3", "Inf" or "0x10" fail instead of becoming absurd amounts.
+If it fails to parse an amount, parseMoney returns an error.
+*/
+func parseMoney(str string, lax bool) (float64, error) {
+	if !lax && !strictNumberPattern.MatchString(str) {
+		return zero, fmt.Errorf("%w: %q", errNumber, str)
+	}
+
+	return parseFloat64(str)
+}
+
+/*
+Expand returns the template with each field name in braces replaced by the value of that field,
+e.g. "receipts/{date}_{amount}.pdf".
+Expand assumes the template is valid.
+*/
+func (trn *transact) expand(template string) string {
+	return templatePattern.ReplaceAllStringFunc(template, func(name string) string {
+		val, _ := trn.value(name[1 : len(name)-1])
+
+		return val
+	})
+}
+
+/*
+Label returns the memo with the prefix and suffix templates expanded and added to it.
+Besides the fields of the transaction, the templates can refer to "{file}", the name of the statement file,
+"{period}", the year and month the statement period starts, or else of the date, e.g. "2025-05",
+and "{type}", which is "credit" or "debit".
+Label assumes the templates are valid, see labelNames.
+*/
+func (trn *transact) label(cfg config) string {
+	const yearMonth = len("2006-01")
+
+	period := cfg.stmtPeriod
+	if period == "" && yearMonth <= len(trn.date) {
+		period = trn.date[:yearMonth]
+	}
+
+	typ := "credit"
+	if trn.amount < zero {
+		typ = "debit"
+	}
+
+	names := strings.NewReplacer("{file}", cfg.fileName, "{period}", period, "{type}", typ)
+
+	return trn.expand(names.Replace(cfg.memoPrefix)) + trn.memo + trn.expand(names.Replace(cfg.memoSuffix))
+}
+
+/*
+LabelMemo adds the label to the memo of this transaction, if there are label templates, see label,
+then truncates the labelled memo to the maximum memo length, if there is one.
+It is applied to each transaction split emits, so "{type}" is that of the part's amount.
+*/
+func (trn *transact) labelMemo(cfg config) {
+	if cfg.memoPrefix != "" || cfg.memoSuffix != "" {
+		trn.memo = trn.label(cfg)
+	}
+
+	if cfg.maxMemo != 0 {
+		trn.memo = truncate(trn.memo, cfg.maxMemo, cfg.ellipsis())
+	}
+}
+
+// LabelNames removes the names that only memo label templates can refer to, see transact.label.
+var labelNames = strings.NewReplacer("{file}", "", "{period}", "", "{type}", "")
+
+/*
+Field returns a pointer to the text field of the transaction with the name.
+The names are those of the matching flags e.g. "otheracct", or of an extra field.
+If the transaction has no text field with the name, field returns nil.
+*/
+func (trn *transact) field(name string) *string {
+	switch name {
+	case "currency":
+		return &trn.currency
+	case "memo":
+		return &trn.memo
+	case "otheracct":
+		return &trn.otherAcct
+	case "taxacct":
+		return &trn.taxAcct
+	case "taxrate":
+		return &trn.taxRate
+	case "thisacct":
+		return &trn.thisAcct
+	default:
+		inx := slices.Index(trn.extraNames, name)
+		if inx < 0 {
+			return nil
+		}
+
+		return &trn.extras[inx]
+	}
+}
+
+/*
+ParseTaxRate returns the tax rate of this transaction as a fraction and nil.
+If the tax rate is not a positive percentage or the tax account is empty string, parseTaxRate returns an error.
+*/
+func (trn *transact) parseTaxRate() (float64, error) {
+	if trn.taxAcct == "" {
+		return zero, errTaxAcct
+	}
+
+	rate, err := parseFloat64(strings.TrimSuffix(trn.taxRate, "%"))
+	if err != nil || rate <= zero {
+		return zero, errTaxRate
+	}
+
+	const percent = 100
+
+	return rate / percent, nil
+}
+
+/*
+Round returns the value rounded to an integer with the rounding mode.
+Half even rounds halves to the even integer, and half up rounds them away from zero.
+*/
+func round(val float64, mode string) float64 {
+	if mode == roundHalfEven {
+		return math.RoundToEven(val)
+	}
+
+	return math.Round(val)
+}
+
+/*
+Split returns the transaction split into debit and credit transactions, if it has a split credit,
+then each of those split from a fee transaction, if it has a fee, then split into its parts, see splitParts,
+then split into net and tax transactions.
+The tax transaction has the tax account as its other account,
+and an amount of the tax included in the amount, rounded to the nearest cent with the rounding mode.
+The net amount is the rest of the amount, so the amounts of the parts sum exactly to it.
+If the transaction has no split credit, fee, parts or tax rate, split returns just the transaction.
+Split assumes the tax rate is valid.
+*/
+func (trn *transact) split(rounding string) []transact {
+	if trn.splitCredit != zero {
+		dbt, crt := *trn, *trn
+		crt.extras = slices.Clone(trn.extras)
+		crt.amount = trn.splitCredit
+		dbt.splitCredit, crt.splitCredit, crt.fee = zero, zero, zero
+
+		return append(dbt.split(rounding), crt.split(rounding)...)
+	}
+
+	if trn.fee != zero {
+		rest, fee := *trn, *trn
+		fee.extras = slices.Clone(trn.extras)
+		fee.amount, fee.otherAcct, fee.taxRate, fee.taxAcct = trn.fee, trn.feeAcct, "", ""
+		rest.fee, fee.fee = zero, zero
+
+		return append(rest.split(rounding), fee)
+	}
+
+	if len(trn.parts) != 0 {
+		return trn.splitParts(rounding)
+	}
+
+	if trn.taxRate == "" {
+		return []transact{*trn}
+	}
+
+	rate, _ := trn.parseTaxRate()
+
+	const perCent = 100
+
+	// Split in whole cents, so no part is lost to rounding.
+	cents := math.Round(trn.amount * perCent)
+	taxCents := round(cents*rate/(1+rate), rounding)
+
+	net, tax := *trn, *trn
+	tax.extras = slices.Clone(trn.extras)
+	tax.amount = taxCents / perCent
+	net.amount = (cents - taxCents) / perCent
+	tax.otherAcct = trn.taxAcct
+
+	return []transact{net, tax}
+}
+
+/*
+SplitParts returns the transaction split into a transaction for each part, with the part's other account,
+followed by the rest of the amount, if any is left, with the transaction's other account.
+*/
+func (trn *transact) splitParts(rounding string) []transact {
+	const perCent = 100
+
+	rest := *trn
+	rest.parts = nil
+
+	trns := make([]transact, 0, len(trn.parts)+1)
+
+	for _, prt := range trn.parts {
+		whole := rest
+		whole.extras = slices.Clone(trn.extras)
+		whole.amount, whole.otherAcct, whole.taxRate, whole.taxAcct = prt.amount, prt.otherAcct, "", ""
+		trns = append(trns, whole)
+		rest.amount -= prt.amount
+	}
+
+	// Sum in whole cents, so rounding errors do not leave a rest.
+	rest.amount = math.Round(rest.amount*perCent) / perCent
+	if rest.amount != zero {
+		trns = append(trns, rest.split(rounding)...)
+	}
+
+	return trns
+}
+
+/*
+IsValidTemplate returns nil if each field name in braces in the template is a field of the transaction.
+If not, isValidTemplate returns an error.
+*/
+func (trn *transact) isValidTemplate(template string) error {
+	for _, match := range templatePattern.FindAllStringSubmatch(template, -1) {
+		_, ok := trn.value(match[1])
+		if !ok {
+			return fmt.Errorf("%w: %q", errTemplate, match[1])
+		}
+	}
+
+	return nil
+}
+
+// String returns the transaction in the standard CSV format, followed by any extra fields.
 func (trn *transact) string() string {
 	amt := strconv.FormatFloat(trn.amount, 'f', -1, 64)
 	flds := []string{trn.date, trn.thisAcct, trn.otherAcct, trn.memo, amt, trn.currency}
+	flds = append(flds, trn.extras...)
 
 	const sep = ","
 
 	return strings.Join(flds, sep)
 }
 
+/*
+Record returns the transaction in the CSV output format.
+The debitcredit format replaces the signed amount of the standard format with
+debit and credit fields, one of which is empty string and the other a positive amount.
+The excel-csv format is the standard format for Excel, see excelRecord,
+and the ledger format is a ledger journal entry, see ledgerEntry.
+*/
+func (trn *transact) record(output string) string {
+	switch output {
+	case outputExcelCSV:
+		return trn.excelRecord()
+	case outputLedger:
+		return trn.ledgerEntry()
+	}
+
+	if output != outputDebitCredit {
+		return trn.string()
+	}
+
+	dbt, crt := "", strconv.FormatFloat(math.Abs(trn.amount), 'f', -1, 64)
+	if trn.amount < zero {
+		dbt, crt = crt, dbt
+	}
+
+	flds := []string{trn.date, trn.thisAcct, trn.otherAcct, trn.memo, dbt, crt, trn.currency}
+	flds = append(flds, trn.extras...)
+
+	const sep = ","
+
+	return strings.Join(flds, sep)
+}
+
+/*
+ExcelRecord returns the transaction in the standard CSV format, quoted for Excel to read it unchanged.
+Fields are quoted if they need to be, and accounts that look like numbers, e.g. "12-3456-7890123-00",
+are text formulas so Excel does not convert them into numbers or dates.
+*/
+func (trn *transact) excelRecord() string {
+	amt := strconv.FormatFloat(trn.amount, 'f', -1, 64)
+	flds := []string{trn.date, excelText(trn.thisAcct), excelText(trn.otherAcct), trn.memo, amt, trn.currency}
+	flds = append(flds, trn.extras...)
+
+	for inx, fld := range flds {
+		if strings.ContainsAny(fld, ",\"\r\n") || strings.TrimSpace(fld) != fld {
+			flds[inx] = `"` + strings.ReplaceAll(fld, `"`, `""`) + `"`
+		}
+	}
+
+	const sep = ","
+
+	return strings.Join(flds, sep)
+}
+
+// ExcelText returns the account as a text formula, e.g. `="0123"`, if it looks like a number, else unchanged.
+func excelText(acct string) string {
+	if numberLikePattern.MatchString(acct) {
+		return `="` + acct + `"`
+	}
+
+	return acct
+}
+
+/*
+Object returns the fields of this transaction by their names, for encoding as a JSON object.
+The amount is a number, and the other fields are strings.
+*/
+func (trn *transact) object() map[string]any {
+	obj := map[string]any{
+		"date": trn.date, "thisacct": trn.thisAcct, "otheracct": trn.otherAcct,
+		"memo": trn.memo, "amount": trn.amount, "currency": trn.currency,
+	}
+
+	for inx, name := range trn.extraNames {
+		obj[name] = trn.extras[inx]
+	}
+
+	return obj
+}
+
+/*
+Value returns the value of the field of this transaction with the name, as in the standard format, and true.
+The names are those of field, and "amount" and "date".
+If the transaction has no field with the name, value returns false.
+*/
+func (trn *transact) value(name string) (string, bool) {
+	switch name {
+	case "amount":
+		return strconv.FormatFloat(trn.amount, 'f', -1, 64), true
+	case "date":
+		return trn.date, true
+	}
+
+	fld := trn.field(name)
+	if fld == nil {
+		return "", false
+	}
+
+	return *fld, true
+}
+
 /*
 Transact parses the transaction from the fields, according to the configuration, and returns nil.
 It assumes the configuration is valid.
@@ -123,6 +776,8 @@ func (trn *transact) transact(fields []string, cfg config) error {
 		return errNFields
 	}
 
+	*trn = transact{extraNames: cfg.extraNames, extras: make([]string, len(cfg.extraNames))}
+
 	/*
 		Prepend fields with an empty string.
 		The value of an optional field, whose field index is zero,
@@ -137,19 +792,30 @@ func (trn *transact) transact(fields []string, cfg config) error {
 		return err
 	}
 
-	trn.amount, err = parseAmount(flds, cfg)
+	switch {
+	case cfg.isPayOnly():
+		// the amount is the sum of the pay parts, see setPayParts
+	case cfg.amountI == 0 && cfg.creditI == 0:
+		trn.amount, err = tradeAmount(flds, cfg)
+	default:
+		trn.amount, trn.splitCredit, err = parseAmount(flds, cfg)
+	}
+
 	if err != nil {
 		return err
-	} else if trn.amount == zero {
+	} else if trn.amount == zero && !cfg.isPayOnly() {
 		return errAmount
 	}
 
 	trn.memo = flds[cfg.memoI]
+	if cfg.plainMemo {
+		trn.memo = plainText(trn.memo)
+	}
+
 	if trn.memo == "" {
 		return errMemo
 	}
 
-	trn.currency = cfg.currency
 	trn.otherAcct = flds[cfg.otherAcctI]
 
 	switch {
@@ -157,9 +823,107 @@ func (trn *transact) transact(fields []string, cfg config) error {
 		trn.thisAcct = cfg.thisAcct
 	case flds[cfg.thisAcctI] != "":
 		trn.thisAcct = flds[cfg.thisAcctI]
+	case cfg.fileAcct != "":
+		trn.thisAcct = cfg.fileAcct
 	default:
 		return errThisAcct
 	}
 
+	// Normalise text fields so they compare equal however their characters were composed.
+	trn.memo, trn.otherAcct, trn.thisAcct = nfc(trn.memo), nfc(trn.otherAcct), nfc(trn.thisAcct)
+	if cfg.memoScript == memoLatin {
+		trn.memo = latin(trn.memo)
+	}
+
+	trn.currency = cfg.currency
+	if cur, ok := cfg.acctCurrencies[trn.thisAcct]; ok {
+		trn.currency = cur
+	}
+
+	if flds[cfg.currencyI] != "" {
+		trn.currency = flds[cfg.currencyI]
+	}
+
+	if cfg.minorUnits {
+		if trn.amount != math.Trunc(trn.amount) {
+			return errMinorUnits
+		}
+
+		trn.amount /= math.Pow10(currencyExponent(trn.currency))
+	}
+
+	if cfg.feeI != 0 {
+		err = trn.setFee(flds[cfg.feeI], cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = trn.setLoanParts(flds, cfg)
+	if err != nil {
+		return err
+	}
+
+	err = trn.setPayParts(flds, cfg)
+	if err != nil {
+		return err
+	} else if trn.amount == zero {
+		return errAmount
+	}
+
+	// Guard downstream ledgers from corrupted exports, whose amounts are often absurd.
+	if math.IsNaN(trn.amount) || math.IsInf(trn.amount, 0) ||
+		(cfg.maxAmount != 0 && math.Abs(trn.amount) > cfg.maxAmount) {
+		return fmt.Errorf("%w: %v", errMaxAmount, trn.amount)
+	}
+
+	if cfg.chequeI != 0 {
+		*trn.field(chequeName) = flds[cfg.chequeI]
+	}
+
+	if cfg.statusI != 0 && cfg.pendingPolicy == pendingInclude {
+		*trn.field(statusName) = flds[cfg.statusI]
+	}
+
+	for inx, name := range map[uint8]string{cfg.quantityI: quantityName, cfg.symbolI: symbolName, cfg.priceI: priceName} {
+		if inx != 0 {
+			*trn.field(name) = strings.TrimSpace(flds[inx])
+		}
+	}
+
+	if cfg.origAmtI != 0 {
+		err = trn.setOriginal(flds[cfg.origAmtI], flds[cfg.origCurI], cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	cfg.mappings.apply(trn)
+	cfg.rules.apply(trn)
+
+	if cfg.classifier != nil {
+		cfg.classifier.suggest(trn)
+	}
+
+	if trn.otherAcct == "" {
+		trn.otherAcct = cfg.otherAcct
+	}
+
+	err = cfg.applyUnknownPolicy(trn)
+	if err != nil {
+		return err
+	}
+
+	if cfg.docRef != "" {
+		*trn.field(documentName) = trn.expand(cfg.docRef)
+	}
+
+	if trn.taxRate != "" || trn.taxAcct != "" {
+		_, err = trn.parseTaxRate()
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
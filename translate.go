@@ -0,0 +1,772 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A recordReader reads the records of a statement, and knows the line number of each.
+type recordReader interface {
+	Read() ([]string, error)
+	FieldPos(field int) (line, column int)
+}
+
+var (
+	errExpectCount = errors.New("number of transactions is not that expected, see expectcount")
+	errExpectSum   = errors.New("sum of the transaction amounts is not that expected, see expectsum")
+	errNoTransacts = errors.New("no transactions found")
+)
+
+/*
+A lineReader reads the records of a text statement, one per line matching its pattern.
+The fields of a record are the values of the pattern's groups.
+Lines that do not match are skipped.
+*/
+type lineReader struct {
+	scanner *bufio.Scanner
+	lineN   int // of the last line read
+	pattern *regexp.Regexp
+}
+
+/*
+A translator translates financial transactions from account statements according to its configuration.
+It writes each transaction it translates, and keeps statistics about them.
+*/
+type translator struct {
+	buffer   bytes.Buffer // of transactions written to the clipboard or output file by finish
+	cfg      config
+	comment  func(str string)    // writes a comment after the transactions, may be nil
+	dates    map[string][]string // of the transactions written by this account, see config.maxGap
+	dayN     int                 // number of the last transaction written within its date
+	failed   [][]string          // sample of records that failed to parse, see maxSampled
+	fileName string              // of the statement being translated, empty string for standard input
+	held     []transact          // transactions emitted but held back to be sorted or sampled, written by finish
+	lastDate string              // of the last transaction written
+	nEmitted uint                // transactions emitted, see emit
+	nEmpty   int                 // statements in which no transactions were found, see endStatement
+	kept     []transact          // transactions written to a Parquet or Arrow file, workbook or Google Sheet by finish
+	out      io.Writer           // of the Parquet or Arrow file or xlsx workbook, see kept
+	history  history             // of the amounts of each account, see config.outlierFactor
+	progress progress            // of the statements translated, see config.stateFile
+	reviewer *reviewer           // of transactions before they are written, see config.review
+	sink     *dbSink             // of transactions written to a database, closed by finish
+	seen     map[string]bool     // keys of the transactions emitted, see config.dedupeKey
+	seqN     int                 // sequence number of the last transaction emitted
+	started  progress            // of the statements before this run, see saveRun
+	stats    stats
+	write    func(trn *transact)
+}
+
+/*
+NewTranslator returns a translator that writes transactions in the output format to standard output,
+or the clipboard, an output file, a database or a Google Sheet if the configuration says so.
+*/
+func newTranslator(cfg config) *translator {
+	tlr := &translator{cfg: cfg}
+
+	var writer io.Writer = os.Stdout
+	if cfg.toClipboard || cfg.outFile != "" || cfg.encryptTo != "" {
+		writer = &tlr.buffer
+	}
+
+	eol := "\n"
+	if cfg.output == outputExcelCSV {
+		eol = "\r\n"
+	}
+
+	tlr.write = func(trn *transact) {
+		fmt.Fprint(writer, trn.record(cfg.output)+eol)
+	}
+	tlr.comment = func(str string) {
+		fmt.Fprint(writer, "# "+str+eol)
+	}
+
+	if cfg.review {
+		tlr.reviewer = &reviewer{categories: slices.Clip(cfg.categories), nLoaded: len(cfg.categories), out: os.Stderr}
+	}
+
+	if cfg.output == outputArrow || cfg.output == outputParquet || cfg.output == outputXLSX {
+		tlr.write, tlr.comment = tlr.keep, nil
+		tlr.out = writer
+	} else if cfg.bom || cfg.output == outputExcelCSV {
+		fmt.Fprint(writer, bom)
+	}
+
+	if cfg.sheet.id != "" {
+		tlr.write, tlr.comment = tlr.keep, nil
+	}
+
+	if cfg.dbDSN != "" {
+		tlr.sink = &dbSink{driver: cfg.dbDriver, dsn: cfg.dbDSN, size: int(cfg.dbBatch)}
+		tlr.write, tlr.comment = tlr.sink.add, nil
+	}
+
+	return tlr
+}
+
+/*
+Finish finishes translating and returns nil.
+It writes the transactions held back, if sorting or sampling is configured,
+then the period they cover as a comment if it is configured.
+Sorting by date is stable, so transactions on the same date keep their input order.
+If most records failed to parse, it writes suggested fixes to the configuration to standard error.
+It writes warnings about gaps in the dates of the transactions to standard error if they are configured.
+It writes the statistics to standard error if they are configured,
+and the transactions to the clipboard or output file, which can be in S3, if it is configured.
+Transactions are encrypted before they are written if it is configured.
+It writes the transactions as a Parquet file or xlsx workbook, appends them to a Google Sheet, or closes the database
+they are written to, if it is configured.
+It appends the rules learned, and categories assigned, by reviewing to their files if they are configured.
+If the transactions were held, see holdsOutput, it saves their progress to the state file once they are written.
+If the transactions are not those expected, see checkExpected, or finish fails to write the clipboard,
+output file, database, rule or category file, it returns an error.
+*/
+func (tlr *translator) finish() error {
+	if tlr.cfg.sortDate {
+		slices.SortStableFunc(tlr.held, func(a, b transact) int {
+			return strings.Compare(a.date, b.date)
+		})
+	}
+
+	for _, trn := range tlr.held {
+		tlr.output(&trn)
+	}
+
+	tlr.held = nil
+
+	if tlr.stats.nRecords < 2*tlr.stats.nFailed {
+		for _, sgn := range tlr.cfg.suggest(tlr.failed) {
+			fmt.Fprintf(os.Stderr, "%v: suggestion: %v\n", pgmName, sgn)
+		}
+	}
+
+	if first, last, ok := tlr.stats.period(); ok && tlr.cfg.periodComment && tlr.comment != nil {
+		tlr.comment(fmt.Sprintf("period %v %v", first, last))
+	}
+
+	if tlr.cfg.maxGap != 0 {
+		writeGaps(os.Stderr, tlr.dates, tlr.cfg.maxGap, tlr.cfg.holidays)
+	}
+
+	if tlr.cfg.stats {
+		tlr.stats.write(os.Stderr)
+	}
+
+	err := tlr.checkExpected()
+	if err == nil && tlr.nEmpty != 0 {
+		err = fmt.Errorf("%w in %v statements", errNoTransacts, tlr.nEmpty)
+	}
+
+	var outErr error // of writing the transactions, after which their progress is not saved
+
+	if tlr.out != nil {
+		switch tlr.cfg.output {
+		case outputArrow:
+			outErr = writeArrow(tlr.out, tlr.kept, tlr.cfg.extraNames)
+		case outputXLSX:
+			outErr = writeXLSX(tlr.out, tlr.kept, tlr.cfg.extraNames)
+		default:
+			outErr = writeParquet(tlr.out, tlr.kept, tlr.cfg.extraNames)
+		}
+	}
+
+	if tlr.cfg.sheet.id != "" {
+		outErr = errors.Join(outErr, appendSheet(tlr.cfg.sheet, tlr.kept))
+	}
+
+	if tlr.sink != nil {
+		outErr = errors.Join(outErr, tlr.sink.close())
+	}
+
+	err = errors.Join(err, tlr.saveRun(time.Now()))
+
+	if tlr.reviewer != nil && tlr.cfg.ruleFile != "" {
+		err = errors.Join(err, tlr.reviewer.saveRules(tlr.cfg.ruleFile))
+	}
+
+	if tlr.reviewer != nil && tlr.cfg.categoryFile != "" {
+		err = errors.Join(err, tlr.reviewer.saveCategories(tlr.cfg.categoryFile))
+	}
+
+	if tlr.cfg.encryptTo != "" {
+		data, encErr := encrypt(tlr.buffer.Bytes(), tlr.cfg.encryptTo)
+
+		switch {
+		case encErr != nil:
+			outErr = errors.Join(outErr, encErr)
+		case tlr.cfg.outFile == "":
+			_, encErr = os.Stdout.Write(data)
+			outErr = errors.Join(outErr, encErr)
+		default:
+			outErr = errors.Join(outErr, tlr.writeOutFile(data))
+		}
+	} else {
+		if tlr.cfg.outFile != "" {
+			outErr = errors.Join(outErr, tlr.writeOutFile(tlr.buffer.Bytes()))
+		}
+
+		if tlr.cfg.toClipboard {
+			outErr = errors.Join(outErr, writeClipboard(tlr.buffer.Bytes()))
+		}
+	}
+
+	if outErr == nil && tlr.holdsOutput() && tlr.progress != nil && tlr.cfg.stateFile != "" {
+		outErr = tlr.progress.save(tlr.cfg.stateFile)
+	}
+
+	return errors.Join(err, outErr)
+}
+
+/*
+HoldsOutput returns true if the transactions emitted are held, to be written or committed by finish,
+rather than written to standard output as they are emitted, see saveProgress.
+*/
+func (tlr *translator) holdsOutput() bool {
+	cfg := tlr.cfg
+
+	return cfg.toClipboard || cfg.outFile != "" || cfg.encryptTo != "" || cfg.sheet.id != "" || cfg.sortDate ||
+		cfg.sample != 0 || tlr.out != nil || tlr.sink != nil
+}
+
+/*
+CheckExpected returns nil if the number of transactions written, and the sum of their amounts,
+are those expected, such as printed on the statement.
+Expectations are optional, and the sum is compared to the nearest cent.
+If an expectation is not met, checkExpected returns the first error.
+*/
+func (tlr *translator) checkExpected() error {
+	if tlr.cfg.expectCount != nil && tlr.stats.nTransacts() != int(*tlr.cfg.expectCount) {
+		return fmt.Errorf("%w: expected==%v, got==%v", errExpectCount, *tlr.cfg.expectCount, tlr.stats.nTransacts())
+	}
+
+	if tlr.cfg.expectSum == nil {
+		return nil
+	}
+
+	sum := zero
+	for _, tot := range tlr.stats.totals {
+		sum += tot.credits + tot.debits
+	}
+
+	const halfCent = 0.005
+	if halfCent <= math.Abs(sum-*tlr.cfg.expectSum) {
+		return fmt.Errorf("%w: expected==%.2f, got==%.2f", errExpectSum, *tlr.cfg.expectSum, sum)
+	}
+
+	return nil
+}
+
+/*
+TranslateFiles translates financial transactions in the account statements named by files and returns nil.
+If no files are named, translateFiles reads a statement from standard input, or the clipboard if configured.
+If there is a state file, statements resume from the progress recorded in it.
+If it fails to open or read a statement, translateFiles returns the first error.
+*/
+func (tlr *translator) translateFiles(files []string) error {
+	if tlr.cfg.stateFile != "" && tlr.progress == nil {
+		var err error
+
+		tlr.progress, err = loadProgress(tlr.cfg.stateFile)
+		if err != nil {
+			return fmt.Errorf("loadProgress: %w", err)
+		}
+
+		if tlr.started == nil {
+			tlr.started = maps.Clone(tlr.progress)
+		}
+	}
+
+	if tlr.cfg.outlierFactor != 0 && tlr.cfg.dbDSN != "" {
+		var err error
+
+		tlr.history, err = loadHistory(tlr.cfg.dbDriver, tlr.cfg.dbDSN)
+		if err != nil {
+			return fmt.Errorf("loadHistory: %w", err)
+		}
+	}
+
+	if tlr.cfg.fromClipboard {
+		if len(files) != 0 {
+			return errClipboardFiles
+		}
+
+		data, err := readClipboard()
+		if err != nil {
+			return err
+		}
+
+		return tlr.translateStatement(bytes.NewReader(data))
+	}
+
+	if len(files) == 0 {
+		return tlr.translateStatement(os.Stdin)
+	}
+
+	for _, stmt := range expandGlobs(files) {
+		err := tlr.translateFile(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ExpandGlobs returns the names of files with glob patterns, e.g. "statements\*.csv", replaced by the files they match.
+Shells on Windows do not expand them as Unix ones do.
+Names of files that exist, or in cloud storage, and patterns that match nothing are returned unchanged.
+*/
+func expandGlobs(files []string) []string {
+	var out []string
+
+	for _, file := range files {
+		if !isCloudPath(file) && strings.ContainsAny(file, "*?[") {
+			if _, err := os.Stat(file); err != nil {
+				matches, _ := filepath.Glob(file) // a bad pattern matches nothing
+				if 0 < len(matches) {
+					out = append(out, matches...)
+
+					continue
+				}
+			}
+		}
+
+		out = append(out, file)
+	}
+
+	return out
+}
+
+// Keep keeps the transaction, to be written by finish.
+func (tlr *translator) keep(trn *transact) {
+	tlr.kept = append(tlr.kept, *trn)
+}
+
+/*
+Emit numbers the transaction if configured, and outputs it.
+If deduplicating is configured and the transaction has the same key as one emitted earlier, it is dropped.
+If sorting is configured, the transaction is held back instead, and output when finishing.
+If sampling is configured, the transaction may be kept in the sample instead, which is output when finishing.
+The sample is chosen at random by reservoir sampling, and keeps the order of the transactions.
+*/
+func (tlr *translator) emit(trn *transact) {
+	if tlr.cfg.dedupeKey != nil {
+		if tlr.seen == nil {
+			tlr.seen = make(map[string]bool)
+		}
+
+		vals := make([]string, len(tlr.cfg.dedupeKey))
+		for inx, name := range tlr.cfg.dedupeKey {
+			vals[inx], _ = trn.value(name)
+		}
+
+		key := strings.Join(vals, "\x00")
+		if tlr.seen[key] {
+			tlr.stats.nDuplicates++
+
+			return
+		}
+
+		tlr.seen[key] = true
+	}
+
+	tlr.nEmitted++
+
+	if tlr.cfg.sequence != "" {
+		tlr.seqN++
+		*trn.field(sequenceName) = strconv.Itoa(tlr.seqN)
+	}
+
+	switch {
+	case tlr.cfg.sample == 0 && tlr.cfg.sortDate:
+		tlr.held = append(tlr.held, *trn)
+	case tlr.cfg.sample == 0:
+		tlr.output(trn)
+	case uint(len(tlr.held)) < tlr.cfg.sample:
+		tlr.held = append(tlr.held, *trn)
+	default:
+		inx := rand.N(tlr.nEmitted)
+		if inx < tlr.cfg.sample {
+			// Replace the chosen transaction while keeping order, by removing it and appending this one.
+			tlr.held = append(slices.Delete(tlr.held, int(inx), int(inx)+1), *trn)
+		}
+	}
+}
+
+/*
+Output numbers the transaction within its date if configured, writes it and adds it to the statistics.
+Transactions on the same date are numbered in the order they are written, restarting at one with each date.
+If reviewing is configured, the transaction is reviewed first, and not output if the user skips it.
+*/
+func (tlr *translator) output(trn *transact) {
+	if tlr.reviewer != nil && !tlr.reviewer.review(trn) {
+		return
+	}
+
+	if tlr.cfg.daySequence {
+		if trn.date != tlr.lastDate {
+			tlr.dayN = 0
+		}
+
+		tlr.dayN++
+		tlr.lastDate = trn.date
+		*trn.field(daySequenceName) = strconv.Itoa(tlr.dayN)
+	}
+
+	if tlr.cfg.maxGap != 0 {
+		if tlr.dates == nil {
+			tlr.dates = make(map[string][]string)
+		}
+
+		tlr.dates[trn.thisAcct] = append(tlr.dates[trn.thisAcct], trn.date)
+	}
+
+	tlr.stats.add(trn)
+	tlr.write(trn)
+}
+
+/*
+TranslateFile translates financial transactions in the account statement named by file and returns nil.
+If the file is a zip archive, each statement in it is translated.
+If the file is PDF, the transactions in its text are translated, see translatePDF.
+If the file is in cloud storage, it is downloaded first, see cloudConfig.
+See translateStatement.
+If it fails to open or read the statement, translateFile returns an error.
+*/
+func (tlr *translator) translateFile(file string) error {
+	tlr.fileName = file
+
+	if isCloudPath(file) {
+		data, err := tlr.cfg.cloud.download(file)
+		if err != nil {
+			return fmt.Errorf("%w: %v", err, file)
+		}
+
+		return tlr.translateAttachment(attachment{data: data, name: file})
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".pdf":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		return tlr.translatePDF(data)
+	case ".zip":
+		arc, err := zip.OpenReader(file)
+		if err != nil {
+			return fmt.Errorf("zip.OpenReader: %w", err)
+		}
+		defer arc.Close()
+
+		return tlr.translateZip(&arc.Reader)
+	}
+
+	stmt, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	return tlr.translateStatement(stmt)
+}
+
+/*
+TranslateStatement translates financial transactions in an account statement read from the reader
+from an arbitrary CSV format to the standard format and returns nil.
+The CSV dialect is that configured, or detected.
+If there is a line pattern, the statement is text instead, see lineReader.
+If there is a this account pattern, this account can be matched in the file name of the statement.
+It reads each transaction, and parses it according to the cas2trn ration.
+If it fails to read the statement, translateStatement returns an error.
+If it fails to parse a transaction,
+translateStatement writes an error to standard error and continues.
+If it successfully parses a transaction,
+translateStatement writes it and continues,
+after writing a warning to standard error if an account is not known.
+TranslateStatement counts the statement, the records read and failed, and the transactions written,
+in the statistics.
+*/
+func (tlr *translator) translateStatement(rdr io.Reader) error {
+	cfg := tlr.cfg
+	rdr = &lineEndReader{rdr: bufio.NewReader(skipBOM(rdr)), recordSep: cfg.dialect.recordSep}
+
+	if cfg.thisAcctPattern != nil {
+		cfg.fileAcct = matchAcct(cfg.thisAcctPattern, filepath.Base(tlr.fileName))
+	}
+
+	cfg.fileName, cfg.stmtPeriod = filepath.Base(tlr.fileName), ""
+	if tlr.fileName == "" {
+		cfg.fileName = "stdin"
+	}
+
+	if cfg.linePattern != nil {
+		return tlr.translateRecords(&lineReader{scanner: bufio.NewScanner(rdr), pattern: cfg.linePattern}, cfg)
+	}
+
+	var reader dialectReader
+
+	reader, cfg.dialect = cfg.dialect.newReader(rdr, cfg.detectDialect)
+
+	// Disable number of fields per record check; it is done in transact.transact() instead.
+	reader.FieldsPerRecord = -1
+
+	return tlr.translateRecords(reader, cfg)
+}
+
+/*
+TranslateRecords translates financial transactions in the records read from the reader
+according to the configuration and returns nil.
+Only records starting in the configured range of lines are translated.
+If there is a state file, records up to the line recorded in it are skipped,
+and the progress is saved periodically and when the statement ends.
+If there is a running balance, the amounts are checked against it.
+It stops reading once the limit of transactions, if any, is emitted.
+See translateStatement.
+*/
+func (tlr *translator) translateRecords(reader recordReader, cfg config) error {
+	tlr.stats.nFiles++
+
+	if cfg.sequence == seqFile {
+		tlr.seqN = 0
+	}
+
+	var balChk balanceCheck
+
+	nFound := 0 // records that are transactions, pending or not, so not headers or those that failed to parse
+
+	stmt, resumeLine := tlr.fileName, 0
+	if tlr.progress != nil && stmt != "" {
+		resumeLine = tlr.progress[stmt]
+		if resumeLine == doneLine {
+			return nil
+		}
+	}
+
+	fst, nEmitted := &fileStats{name: stmt}, tlr.nEmitted
+	tlr.stats.files = append(tlr.stats.files, fst)
+
+	defer func() { fst.nTransacts = tlr.nEmitted - nEmitted }()
+
+	for nRead := 1; ; nRead++ {
+		if tlr.cfg.limit != 0 && tlr.cfg.limit <= tlr.nEmitted {
+			return tlr.saveProgress(stmt, 0)
+		}
+
+		flds, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return tlr.endStatement(stmt, nFound, resumeLine)
+		} else if err != nil {
+			return fmt.Errorf("reader.Read(): %w", err)
+		}
+
+		lineN, _ := reader.FieldPos(0)
+		if lineN < int(cfg.firstLine) {
+			continue
+		}
+
+		if cfg.columns != nil {
+			// the first record of the statement may be its header row, see config.applyHeader
+			var isHeader bool
+
+			cfg, isHeader, err = cfg.applyHeader(flds)
+			if err != nil {
+				return fmt.Errorf("config.applyHeader: %w on line %v", err, lineN)
+			} else if isHeader {
+				continue
+			}
+		}
+
+		if lineN <= resumeLine {
+			continue
+		} else if cfg.lastLine != 0 && int(cfg.lastLine) < lineN {
+			return tlr.endStatement(stmt, nFound, resumeLine)
+		}
+
+		if tlr.progress != nil && stmt != "" {
+			tlr.progress[stmt] = lineN - 1
+
+			if nRead%saveEvery == 0 {
+				err = tlr.saveProgress(stmt, 0)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		tlr.stats.nRecords++
+		fst.nRecords++
+
+		if cfg.periodPattern != nil {
+			if first, last, ok := parsePeriod(flds, cfg); ok {
+				tlr.stats.addPeriod(first, last)
+
+				const yearMonth = len("2006-01")
+
+				cfg.stmtPeriod = first[:yearMonth]
+
+				continue
+			}
+		}
+
+		if cfg.pendingPolicy != pendingInclude && cfg.isPending(flds) {
+			nFound++
+			tlr.stats.nPending++
+
+			continue
+		}
+
+		var trn transact
+
+		err = trn.transact(flds, cfg)
+		if err != nil {
+			tlr.stats.nFailed++
+			fst.nFailed++
+			if len(tlr.failed) < maxSampled {
+				tlr.failed = append(tlr.failed, flds)
+			}
+
+			fmt.Fprintln(os.Stderr,
+				fmt.Errorf("%v: transact.transact: %w on line %v", pgmName, err, lineN))
+
+			continue
+		}
+
+		nFound++
+
+		fst.firstDate, fst.lastDate = widen(fst.firstDate, fst.lastDate, trn.date, trn.date)
+
+		if cfg.balanceI != 0 {
+			balChk.check(os.Stderr, flds, trn.amount+trn.splitCredit+trn.fee, lineN, cfg)
+		}
+
+		if cfg.outlierFactor != 0 {
+			if tlr.history == nil {
+				tlr.history = make(history)
+			}
+
+			tlr.history.check(os.Stderr, &trn, cfg.outlierFactor, lineN)
+		}
+
+		err = cfg.checkAccounts(&trn)
+		if err != nil {
+			fmt.Fprintln(os.Stderr,
+				fmt.Errorf("%v: warning: config.checkAccounts: %w on line %v", pgmName, err, lineN))
+		}
+
+		for _, part := range trn.split(cfg.rounding) {
+			part.labelMemo(cfg)
+			tlr.emit(&part)
+		}
+	}
+}
+
+/*
+EndStatement ends translating the statement, in which nFound transactions were found, and returns nil, see saveProgress.
+If none were found, in a statement not resumed from the state file, such as a broken download or one of only
+headers, it writes a diagnostic to standard error and counts the statement as empty, which finish fails on.
+*/
+func (tlr *translator) endStatement(stmt string, nFound, resumeLine int) error {
+	if nFound == 0 && resumeLine == 0 {
+		name := stmt
+		if name == "" {
+			name = "standard input"
+		}
+
+		fmt.Fprintf(os.Stderr, "%v: %v: %v\n", pgmName, name, errNoTransacts)
+
+		tlr.nEmpty++
+	}
+
+	return tlr.saveProgress(stmt, doneLine)
+}
+
+/*
+SaveProgress records that the statement has been translated, if the line is doneLine,
+then saves the progress to the state file and returns nil.
+If there is no state file, or the statement has no name, saveProgress does nothing.
+If the output is held, see holdsOutput, the progress is only saved by finish once the transactions are written,
+so a statement whose transactions failed to be written is translated again.
+If it fails to save the progress, saveProgress returns an error.
+*/
+func (tlr *translator) saveProgress(stmt string, line int) error {
+	if tlr.progress == nil || stmt == "" || tlr.cfg.stateFile == "" {
+		return nil
+	}
+
+	if line == doneLine {
+		tlr.progress[stmt] = doneLine
+	}
+
+	if tlr.holdsOutput() {
+		return nil
+	}
+
+	err := tlr.progress.save(tlr.cfg.stateFile)
+	if err != nil {
+		return fmt.Errorf("progress.save: %w", err)
+	}
+
+	return nil
+}
+
+// FieldPos returns the number of the line of the last record read.
+func (lrdr *lineReader) FieldPos(_ int) (int, int) {
+	return lrdr.lineN, 1
+}
+
+/*
+Read returns the record of the next line that matches the pattern and nil, or io.EOF after the last line.
+If it fails to read a line, Read returns an error.
+*/
+func (lrdr *lineReader) Read() ([]string, error) {
+	for lrdr.scanner.Scan() {
+		lrdr.lineN++
+
+		match := lrdr.pattern.FindStringSubmatch(strings.TrimSuffix(lrdr.scanner.Text(), "\r"))
+		if match != nil {
+			return match[1:], nil
+		}
+	}
+
+	err := lrdr.scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+
+	return nil, io.EOF
+}
@@ -0,0 +1,283 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+A runReport records what a run changed in the stores that transactions accumulate in across runs,
+so the run can be undone: the transactions it inserted into the database, and the progress in the state file,
+before the run, of the statements it translated.
+Reports are kept in a runs file, named for the state file or else the SQLite database, see config.runsName.
+*/
+type runReport struct {
+	ids   []string // of the transactions inserted, see dbSink.added
+	lines progress // of the statements translated, before the run, where zero means none
+	time  string   // of the run, in backupLayout
+}
+
+const (
+	runsExt = ".runs" // of the file of reports of the runs, see runReport
+	undoCmd = "undo"
+)
+
+var (
+	errRunsFile  = errors.New("runs file line must be run, line or id, then its values separated by tabs")
+	errUndoDB    = errors.New("run inserted transactions into a database, set dbdsn to undo it")
+	errUndoFiles = errors.New("undo needs a statefile or SQLite dbdsn, whose runs are reported")
+	errUndoNone  = errors.New("no run to undo")
+)
+
+/*
+RunsName returns the name of the file of reports of the runs of this configuration, see runReport,
+named for the state file, or else the SQLite database, e.g. "state.txt.runs".
+If there is neither, runsName returns empty string.
+*/
+func (cfg *config) runsName() string {
+	if cfg.stateFile != "" {
+		return cfg.stateFile + runsExt
+	}
+
+	if name, ok := sqliteFile(cfg.dbDriver, cfg.dbDSN); ok {
+		return name + runsExt
+	}
+
+	return ""
+}
+
+/*
+SaveRun appends the report of this run, at the time, to the runs file of the configuration, and returns nil.
+A run that inserted no transactions into the database, and translated no more of any statement, is not reported.
+If there is no runs file, saveRun does nothing.
+If saveRun fails to write the runs file, it returns an error.
+*/
+func (tlr *translator) saveRun(now time.Time) error {
+	name := tlr.cfg.runsName()
+	if name == "" {
+		return nil
+	}
+
+	rpt := runReport{lines: make(progress), time: now.Format(backupLayout)}
+
+	if tlr.sink != nil {
+		rpt.ids = tlr.sink.added
+	}
+
+	for stmt, line := range tlr.progress {
+		if tlr.started[stmt] != line {
+			rpt.lines[stmt] = tlr.started[stmt]
+		}
+	}
+
+	if len(rpt.ids) == 0 && len(rpt.lines) == 0 {
+		return nil
+	}
+
+	const perm = 0o600
+
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(file, rpt.String())
+
+	return errors.Join(err, file.Close())
+}
+
+// String returns the report as lines of the runs file: the time of the run, then its progress and IDs.
+func (rpt runReport) String() string {
+	var bld strings.Builder
+
+	fmt.Fprintf(&bld, "run\t%v\n", rpt.time)
+
+	for _, stmt := range slices.Sorted(maps.Keys(rpt.lines)) {
+		fmt.Fprintf(&bld, "line\t%v\t%v\n", rpt.lines[stmt], stmt)
+	}
+
+	for _, id := range rpt.ids {
+		fmt.Fprintf(&bld, "id\t%v\n", id)
+	}
+
+	return bld.String()
+}
+
+/*
+LoadRuns returns the reports of the runs in the named runs file, oldest first, and nil.
+If the file does not exist, there are none.
+If loadRuns fails to read or parse the file, it returns an error.
+*/
+func loadRuns(name string) ([]runReport, error) {
+	file, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var runs []runReport
+
+	scnr := bufio.NewScanner(file)
+
+	for lineN := 1; scnr.Scan(); lineN++ {
+		kind, vals, _ := strings.Cut(scnr.Text(), "\t")
+		last := len(runs) - 1
+
+		switch {
+		case kind == "run" && vals != "":
+			runs = append(runs, runReport{lines: make(progress), time: vals})
+		case kind == "line" && 0 <= last:
+			num, stmt, ok := strings.Cut(vals, "\t")
+
+			val, err := strconv.Atoi(num)
+			if !ok || err != nil || stmt == "" {
+				return nil, fmt.Errorf("%w on line %v", errRunsFile, lineN)
+			}
+
+			runs[last].lines[stmt] = val
+		case kind == "id" && 0 <= last && vals != "":
+			runs[last].ids = append(runs[last].ids, vals)
+		default:
+			return nil, fmt.Errorf("%w on line %v", errRunsFile, lineN)
+		}
+	}
+
+	err = scnr.Err()
+	if err != nil {
+		return nil, fmt.Errorf("scanner.Scan: %w", err)
+	}
+
+	return runs, nil
+}
+
+/*
+UndoRun undoes the most recent run reported with this configuration, see runReport, and writes what it undid
+to the writer and returns nil.
+It deletes the transactions the run inserted from the database, restores the progress of the statements
+the run translated in the state file, then removes the run's report, so undoing again undoes the run before.
+An output file is written afresh by each run, so it is not changed, but can be restored from its backup.
+If there is no run to undo, or undoRun fails to lock or change the database or a file, it returns an error.
+*/
+func undoRun(writer io.Writer, cfg config) error {
+	name := cfg.runsName()
+	if name == "" {
+		return errUndoFiles
+	}
+
+	unlock, err := lockFiles(cfg.lockNames())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	runs, err := loadRuns(name)
+	if err != nil {
+		return fmt.Errorf("loadRuns: %w", err)
+	} else if len(runs) == 0 {
+		return errUndoNone
+	}
+
+	rpt := runs[len(runs)-1]
+
+	if len(rpt.ids) != 0 {
+		if cfg.dbDSN == "" {
+			return errUndoDB
+		}
+
+		snk := dbSink{driver: cfg.dbDriver, dsn: cfg.dbDSN}
+
+		nRemoved, err := snk.remove(rpt.ids)
+		if err != nil {
+			return fmt.Errorf("dbSink.remove: %w", err)
+		}
+
+		fmt.Fprintf(writer, "%v: removed %v transactions of the run at %v from the database\n",
+			pgmName, nRemoved, rpt.time)
+	}
+
+	if len(rpt.lines) != 0 && cfg.stateFile != "" {
+		prg, err := loadProgress(cfg.stateFile)
+		if err != nil {
+			return fmt.Errorf("loadProgress: %w", err)
+		}
+
+		for stmt, line := range rpt.lines {
+			if line == 0 {
+				delete(prg, stmt)
+			} else {
+				prg[stmt] = line
+			}
+		}
+
+		err = prg.save(cfg.stateFile)
+		if err != nil {
+			return fmt.Errorf("progress.save: %w", err)
+		}
+
+		fmt.Fprintf(writer, "%v: restored the progress of %v statements of the run at %v in %v\n",
+			pgmName, len(rpt.lines), rpt.time, cfg.stateFile)
+	}
+
+	return saveRuns(name, runs[:len(runs)-1])
+}
+
+/*
+SaveRuns writes the reports of the runs to the named runs file, or removes it if there are none, and returns nil.
+The file is replaced by renaming a temporary file, so it is not left half written if cas2trn is interrupted.
+If saveRuns fails to write or remove the file, it returns an error.
+*/
+func saveRuns(name string, runs []runReport) error {
+	if len(runs) == 0 {
+		err := os.Remove(name)
+		if err != nil {
+			return fmt.Errorf("os.Remove: %w", err)
+		}
+
+		return nil
+	}
+
+	var bld strings.Builder
+
+	for _, rpt := range runs {
+		bld.WriteString(rpt.String())
+	}
+
+	tmp := name + ".tmp"
+
+	const perm = 0o600
+
+	err := os.WriteFile(tmp, []byte(bld.String()), perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, name)
+}
@@ -0,0 +1,293 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"cmp"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	releasesURL = "https://api.github.com/repos/arnhemcr/cas2trn/releases/latest"
+	sigExt      = ".sig" // of the asset of the base64 Ed25519 signature of a release binary
+	updateCmd   = "self-update"
+)
+
+/*
+ReleaseKey is the base64 Ed25519 public key that release binaries are signed with.
+It is set when a release is built, by -ldflags "-X main.releaseKey=...", see release.go,
+so a binary built from source cannot update itself with an unverified binary.
+*/
+var releaseKey string
+
+var (
+	errReleaseAsset = errors.New("latest release has no binary, or no signature, for this platform")
+	errReleaseKey   = errors.New("this binary has no release signing key, so it cannot verify updates, " +
+		"install a release binary or rebuild from source")
+	errReleaseSig = errors.New("signature of the release binary is not valid, it is not updated")
+	errReleaseTag = errors.New("latest release tag is not a semantic version e.g. \"v1.2.3\"")
+)
+
+// A release is the latest release of cas2trn, as described by the GitHub releases API.
+type release struct {
+	Tag    string `json:"tag_name"`
+	Assets []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// A semver is a semantic version, its major, minor and patch numbers and its pre-release identifiers, if any.
+type semver struct {
+	core [3]int
+	pre  []string
+}
+
+// PgmVersion returns the version of cas2trn, that of its module, or "(devel)" if it is built from source.
+func pgmVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok || info.Main.Version == "" {
+		return "(devel)"
+	}
+
+	return info.Main.Version
+}
+
+/*
+SelfUpdate replaces the running binary with that of the latest release, from the releases API at the URL,
+if it is a later version, see isNewer, and its signature is verified with the release key,
+writes what it did to the writer and returns nil.
+The binary for this platform is named e.g. "cas2trn_linux_amd64", or "cas2trn_windows_amd64.exe".
+If selfUpdate fails to fetch, verify or install the release, it returns an error and the binary is unchanged.
+*/
+func selfUpdate(writer io.Writer, apiURL, exe string) error {
+	key, err := base64.StdEncoding.DecodeString(releaseKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errReleaseKey
+	}
+
+	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+	if err != nil {
+		return fmt.Errorf("http.NewRequest: %w", err)
+	}
+
+	body, err := apiDo(req)
+	if err != nil {
+		return err
+	}
+
+	var rel release
+
+	err = json.Unmarshal(body, &rel)
+	if err != nil {
+		return fmt.Errorf("json.Unmarshal: %w", err)
+	}
+
+	newer, err := isNewer(rel.Tag, pgmVersion())
+	if err != nil {
+		return err
+	} else if !newer {
+		fmt.Fprintf(writer, "%v: %v is up to date, the latest release is %v\n", pgmName, pgmVersion(), rel.Tag)
+
+		return nil
+	}
+
+	name := pgmName + "_" + runtime.GOOS + "_" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	urls := make(map[string]string)
+
+	for _, asset := range rel.Assets {
+		urls[asset.Name] = asset.URL
+	}
+
+	if urls[name] == "" || urls[name+sigExt] == "" {
+		return fmt.Errorf("%w: %v", errReleaseAsset, name)
+	}
+
+	bin, err := fetchAsset(urls[name])
+	if err != nil {
+		return err
+	}
+
+	sig, err := fetchAsset(urls[name+sigExt])
+	if err != nil {
+		return err
+	}
+
+	sig, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || !ed25519.Verify(key, bin, sig) {
+		return errReleaseSig
+	}
+
+	err = replaceBinary(exe, bin)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(writer, "%v: updated from %v to %v\n", pgmName, pgmVersion(), rel.Tag)
+
+	return nil
+}
+
+/*
+IsNewer returns true if the release tag is a later semantic version than the current version, and nil.
+So an update never downgrades, such as when the latest release is withdrawn for an older one.
+A current version that is not a semantic version, such as "(devel)", is older than any release.
+If the tag is not a semantic version, isNewer returns an error.
+*/
+func isNewer(tag, current string) (bool, error) {
+	rel, ok := parseSemver(tag)
+	if !ok {
+		return false, fmt.Errorf("%w: %v", errReleaseTag, tag)
+	}
+
+	cur, ok := parseSemver(current)
+	if !ok {
+		return true, nil
+	}
+
+	return 0 < rel.compare(cur), nil
+}
+
+/*
+ParseSemver returns the semantic version, e.g. "v1.2.3-rc.1+build", and true, or false if it is not one.
+Build metadata, after "+", is ignored, as it does not order versions.
+*/
+func parseSemver(ver string) (semver, bool) {
+	var sv semver
+
+	ver, ok := strings.CutPrefix(ver, "v")
+	ver, _, _ = strings.Cut(ver, "+")
+	core, pre, hasPre := strings.Cut(ver, "-")
+
+	nums := strings.Split(core, ".")
+	if !ok || len(nums) != len(sv.core) || (hasPre && pre == "") {
+		return sv, false
+	}
+
+	for inx, num := range nums {
+		val, err := strconv.Atoi(num)
+		if err != nil || val < 0 || num != strconv.Itoa(val) {
+			return sv, false
+		}
+
+		sv.core[inx] = val
+	}
+
+	if hasPre {
+		sv.pre = strings.Split(pre, ".")
+	}
+
+	return sv, true
+}
+
+/*
+Compare returns a negative number, zero or a positive number as this version is earlier than, the same as,
+or later than the other, by semantic versioning precedence.
+A pre-release is earlier than its release, and its identifiers are compared in turn,
+numerically if both are numbers, else numbers are earlier, else lexically.
+*/
+func (sv semver) compare(other semver) int {
+	if order := slices.Compare(sv.core[:], other.core[:]); order != 0 {
+		return order
+	}
+
+	if len(sv.pre) == 0 || len(other.pre) == 0 {
+		return len(other.pre) - len(sv.pre)
+	}
+
+	return slices.CompareFunc(sv.pre, other.pre, func(ident, otherIdent string) int {
+		num, err := strconv.Atoi(ident)
+		otherNum, otherErr := strconv.Atoi(otherIdent)
+
+		switch {
+		case err == nil && otherErr == nil:
+			return cmp.Compare(num, otherNum)
+		case err == nil:
+			return -1
+		case otherErr == nil:
+			return 1
+		default:
+			return strings.Compare(ident, otherIdent)
+		}
+	})
+}
+
+// FetchAsset returns the contents of the release asset at the URL and nil, or an error.
+func fetchAsset(url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("http.NewRequest: %w", err)
+	}
+
+	return apiDo(req)
+}
+
+/*
+ReplaceBinary replaces the named executable with the binary and returns nil.
+The binary is written next to it then renamed over it, and the old executable is moved aside first,
+as Windows cannot replace a running executable but can rename it.
+If replaceBinary fails, it returns an error and the executable is unchanged.
+*/
+func replaceBinary(exe string, bin []byte) error {
+	const perm = 0o755
+
+	tmp, old := exe+".new", exe+".old"
+
+	err := os.WriteFile(tmp, bin, perm)
+	if err != nil {
+		return err
+	}
+
+	os.Remove(old) // left by a previous update on Windows, if any
+
+	err = os.Rename(exe, old)
+	if err != nil {
+		os.Remove(tmp)
+
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+
+	err = os.Rename(tmp, exe)
+	if err != nil {
+		os.Rename(old, exe)
+		os.Remove(tmp)
+
+		return fmt.Errorf("os.Rename: %w", err)
+	}
+
+	os.Remove(old) // fails harmlessly on Windows while the old executable runs
+
+	return nil
+}
@@ -0,0 +1,64 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+const (
+	presetsVersion  = 2 // of the built-in presets, incremented when one is added or changed
+	standardVersion = 1 // of the standard transaction format, incremented if its fields change
+)
+
+// InputFormats and outputFormats are the formats of statements cas2trn reads and of transactions it writes.
+var (
+	inputFormats  = []string{"csv", "text", "pdf", "zip"}
+	outputFormats = []string{outputStandard, outputDebitCredit, outputExcelCSV, outputLedger, outputParquet,
+		outputXLSX, outputArrow}
+)
+
+/*
+WriteVersion writes the version of cas2trn, with the revision it was built from if known,
+the Go version and platform, the versions of the standard format and built-in presets,
+and the formats supported, to the writer.
+*/
+func writeVersion(writer io.Writer) {
+	version := pgmVersion()
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, set := range info.Settings {
+			if set.Key == "vcs.revision" {
+				version += " revision " + set.Value
+			}
+		}
+	}
+
+	fmt.Fprintf(writer, "%v %v\n", pgmName, version)
+	fmt.Fprintf(writer, "%v %v/%v\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(writer, "standard format version %v\n", standardVersion)
+	fmt.Fprintf(writer, "presets version %v, %v built-in\n", presetsVersion, len(presets))
+	fmt.Fprintf(writer, "input formats %v\n", strings.Join(inputFormats, ", "))
+	fmt.Fprintf(writer, "output formats %v\n", strings.Join(outputFormats, ", "))
+}
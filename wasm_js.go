@@ -0,0 +1,109 @@
+//go:build js && wasm
+
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"syscall/js"
+)
+
+/*
+When built for WebAssembly, e.g. "GOOS=js GOARCH=wasm go build -o cas2trn.wasm",
+cas2trn exports function cas2trn.translate to JavaScript instead of reading files,
+so statements can be translated in a browser without uploading them.
+*/
+func init() {
+	exportJS = func() {
+		js.Global().Set(pgmName, js.ValueOf(map[string]any{"translate": js.FuncOf(translateJS)}))
+		select {} // wait for calls from JavaScript
+	}
+}
+
+/*
+TranslateJS is cas2trn.translate(statement, config) in JavaScript.
+The statement is a Uint8Array of CSV, or a zip archive if config has name ending ".zip".
+The config is JSON of an object whose keys are cas2trn flag names and values are flag values,
+e.g. '{"nfields": 5, "datei": 1, "dateformat": "02/01/2006"}', and cannot have the flags served profiles cannot.
+It returns an array of transactions, each an object as in the serve command's JSON format.
+If translation fails, it returns an Error.
+*/
+func translateJS(_ js.Value, args []js.Value) any {
+	const nArgs = 2
+	if len(args) != nArgs {
+		return jsError(fmt.Errorf("%v.translate needs statement and config arguments", pgmName))
+	}
+
+	data := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(data, args[0])
+
+	var flags map[string]any
+
+	err := json.Unmarshal([]byte(args[1].String()), &flags)
+	if err != nil {
+		return jsError(fmt.Errorf("json.Unmarshal: %w", err))
+	}
+
+	name, _ := flags["name"].(string)
+	delete(flags, "name")
+
+	fargs := make([]string, 0, len(flags))
+	for flg, val := range flags {
+		if num, ok := val.(float64); ok {
+			val = strconv.FormatFloat(num, 'f', -1, 64) // not in exponent form, which flags cannot parse
+		}
+
+		fargs = append(fargs, fmt.Sprintf("-%v=%v", flg, val))
+	}
+
+	fset := flag.NewFlagSet(pgmName, flag.ContinueOnError)
+	fset.SetOutput(io.Discard)
+
+	// The flags are checked as served profiles are, so help or an output file is an error.
+	cfg, err := parseConfig(fset, fargs)
+
+	err = cmp.Or(checkServedFlags(fset), err)
+	if err != nil {
+		return jsError(err)
+	}
+
+	var rows []any
+
+	tlr := translator{cfg: cfg, write: func(trn *transact) {
+		rows = append(rows, trn.object())
+	}}
+
+	err = tlr.translateAttachment(attachment{data: data, name: name})
+	if err != nil {
+		return jsError(err)
+	}
+
+	return rows
+}
+
+// JsError returns the error as a JavaScript Error.
+func jsError(err error) js.Value {
+	return js.Global().Get("Error").New(err.Error())
+}
@@ -0,0 +1,81 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+)
+
+var errWhatsNew = errors.New("whatsnew needs a state file and statement files")
+
+/*
+WhatsNew writes the number of transactions in each statement file that are new,
+and the number already imported according to the state file, then returns nil.
+Nothing is written to the output, and the state file is not changed,
+so it is a quick check whether there is anything to translate.
+If there is no state file or statement file, or whatsNew fails to read one, it returns an error.
+*/
+func whatsNew(writer io.Writer, cfg config, files []string) error {
+	if cfg.stateFile == "" || len(files) == 0 {
+		return errWhatsNew
+	}
+
+	prg, err := loadProgress(cfg.stateFile)
+	if err != nil {
+		return fmt.Errorf("loadProgress: %w", err)
+	}
+
+	for _, file := range expandGlobs(files) {
+		nNew, err := countTransacts(cfg, file, maps.Clone(prg))
+		if err != nil {
+			return err
+		}
+
+		nAll, err := countTransacts(cfg, file, nil)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(writer, "%v: %v new, %v already imported\n", file, nNew, nAll-nNew)
+	}
+
+	return nil
+}
+
+/*
+CountTransacts returns the number of transactions translated from the statement file, resuming from the progress,
+and nil.
+If it fails to translate the file, countTransacts returns an error.
+*/
+func countTransacts(cfg config, file string, prg progress) (int, error) {
+	cfg.stateFile = "" // so the progress is not saved
+
+	nTransacts := 0
+	tlr := &translator{cfg: cfg, progress: prg, write: func(*transact) { nTransacts++ }}
+
+	err := tlr.translateFile(file)
+	if err != nil {
+		return 0, fmt.Errorf("translator.translateFile: %w", err)
+	}
+
+	return nTransacts, nil
+}
@@ -0,0 +1,276 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The parts of an xlsx workbook that do not depend on its transactions.
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ` +
+		`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ` +
+		`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+%v</Types>`
+	xlsxSheetType = `<Override PartName="/xl/worksheets/sheet%v.xml" ` +
+		`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+`
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" ` +
+		`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" ` +
+		`Target="xl/workbook.xml"/>
+</Relationships>`
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font>` +
+		`<font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="4"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+		`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+		`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+		`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>
+</styleSheet>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetViews><sheetView workbookViewId="0">` +
+		`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>
+<sheetData>
+`
+	xlsxSheetEnd = "</sheetData>\n</worksheet>"
+)
+
+// The styles of cells in an xlsx workbook, by their index in its cellXfs.
+const (
+	xlsxHeaderStyle = 1
+	xlsxDateStyle   = 2
+	xlsxAmountStyle = 3
+)
+
+// The limits of sheet names in an xlsx workbook.
+const (
+	xlsxMaxSheetName = 31
+	xlsxSheetNameBad = `[]:*?/\`
+)
+
+/*
+WriteXLSX writes the transactions to the writer as an xlsx workbook and returns nil.
+The workbook has a sheet for each this account, in the order they first appear.
+Each sheet has a frozen header row of the field names of the standard format, then each extra field.
+Dates are dates, amounts are numbers with two decimal places, and the other fields are text.
+If writeXLSX fails to write the workbook, it returns an error.
+*/
+func writeXLSX(writer io.Writer, trns []transact, extraNames []string) error {
+	var accts []string
+
+	byAcct := make(map[string][]transact)
+
+	for _, trn := range trns {
+		if _, ok := byAcct[trn.thisAcct]; !ok {
+			accts = append(accts, trn.thisAcct)
+		}
+
+		byAcct[trn.thisAcct] = append(byAcct[trn.thisAcct], trn)
+	}
+
+	if len(accts) == 0 {
+		accts = append(accts, "") // a workbook needs a sheet
+	}
+
+	names := append([]string{"date", "thisacct", "otheracct", "memo", "amount", "currency"}, extraNames...)
+
+	var sheetTypes, sheets, sheetRels strings.Builder
+
+	arc := zip.NewWriter(writer)
+	used := make(map[string]bool)
+
+	for inx, acct := range accts {
+		sheetN := inx + 1
+		name := xlsxSheetName(acct, used)
+
+		fmt.Fprintf(&sheetTypes, xlsxSheetType, sheetN)
+		fmt.Fprintf(&sheets, `<sheet name="%v" sheetId="%v" r:id="rId%v"/>`, xmlEscape(name), sheetN, sheetN)
+		fmt.Fprintf(&sheetRels, `<Relationship Id="rId%v" `+
+			`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" `+
+			`Target="worksheets/sheet%v.xml"/>`, sheetN, sheetN)
+
+		err := writeZipPart(arc, "xl/worksheets/sheet"+strconv.Itoa(sheetN)+".xml",
+			xlsxSheet(names, byAcct[acct]))
+		if err != nil {
+			return err
+		}
+	}
+
+	stylesN := len(accts) + 1
+
+	for _, part := range []struct{ name, data string }{
+		{"[Content_Types].xml", fmt.Sprintf(xlsxContentTypes, sheetTypes.String())},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets>` + sheets.String() + `</sheets>
+</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + sheetRels.String() +
+			`<Relationship Id="rId` + strconv.Itoa(stylesN) + `" ` +
+			`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`},
+		{"xl/styles.xml", xlsxStyles},
+	} {
+		err := writeZipPart(arc, part.name, part.data)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := arc.Close()
+	if err != nil {
+		return fmt.Errorf("zip.Writer.Close: %w", err)
+	}
+
+	return nil
+}
+
+/*
+XLSXSheet returns the worksheet XML of the transactions, with a header row of the names.
+Dates are serial numbers formatted as dates, amounts are numbers, and the other fields are inline strings.
+*/
+func xlsxSheet(names []string, trns []transact) string {
+	var sheet strings.Builder
+
+	sheet.WriteString(xlsxSheetStart)
+	sheet.WriteString(`<row r="1">`)
+
+	for _, name := range names {
+		fmt.Fprintf(&sheet, `<c t="inlineStr" s="%v"><is><t>%v</t></is></c>`, xlsxHeaderStyle, xmlEscape(name))
+	}
+
+	sheet.WriteString("</row>\n")
+
+	// Dates are days since 1899-12-30, as Excel counts 1900 as a leap year.
+	const epochYear, epochDay = 1899, 30
+
+	epoch := time.Date(epochYear, time.December, epochDay, 0, 0, 0, 0, time.UTC)
+
+	for inx, trn := range trns {
+		fmt.Fprintf(&sheet, `<row r="%v">`, inx+2)
+
+		if date, err := time.Parse(time.DateOnly, trn.date); err == nil {
+			const hoursPerDay = 24
+
+			fmt.Fprintf(&sheet, `<c s="%v"><v>%v</v></c>`, xlsxDateStyle,
+				int(date.Sub(epoch).Hours()/hoursPerDay))
+		} else {
+			fmt.Fprintf(&sheet, `<c t="inlineStr"><is><t>%v</t></is></c>`, xmlEscape(trn.date))
+		}
+
+		for _, str := range []string{trn.thisAcct, trn.otherAcct, trn.memo} {
+			fmt.Fprintf(&sheet, `<c t="inlineStr"><is><t xml:space="preserve">%v</t></is></c>`, xmlEscape(str))
+		}
+
+		fmt.Fprintf(&sheet, `<c s="%v"><v>%v</v></c>`, xlsxAmountStyle, strconv.FormatFloat(trn.amount, 'f', -1, 64))
+
+		for _, str := range append([]string{trn.currency}, trn.extras...) {
+			fmt.Fprintf(&sheet, `<c t="inlineStr"><is><t xml:space="preserve">%v</t></is></c>`, xmlEscape(str))
+		}
+
+		sheet.WriteString("</row>\n")
+	}
+
+	sheet.WriteString(xlsxSheetEnd)
+
+	return sheet.String()
+}
+
+/*
+XLSXSheetName returns the name of the sheet for the account, which is unique among those used.
+Characters not allowed in sheet names are replaced with "-", and names are truncated to the maximum length.
+*/
+func xlsxSheetName(acct string, used map[string]bool) string {
+	name := strings.Map(func(chr rune) rune {
+		if strings.ContainsRune(xlsxSheetNameBad, chr) {
+			return '-'
+		}
+
+		return chr
+	}, acct)
+
+	base := cmp.Or(name, "Sheet")
+	name = truncateRunes(base, xlsxMaxSheetName)
+
+	for n := 2; used[name]; n++ {
+		suffix := " (" + strconv.Itoa(n) + ")"
+		name = truncateRunes(base, xlsxMaxSheetName-len(suffix)) + suffix
+	}
+
+	used[name] = true
+
+	return name
+}
+
+// TruncateRunes returns the string truncated to at most n runes.
+func truncateRunes(str string, n int) string {
+	runes := []rune(str)
+	if len(runes) <= n {
+		return str
+	}
+
+	return string(runes[:n])
+}
+
+// WriteZipPart writes a part, a file with the name and data, to the zip archive and returns nil or an error.
+func writeZipPart(arc *zip.Writer, name, data string) error {
+	part, err := arc.Create(name)
+	if err != nil {
+		return fmt.Errorf("zip.Writer.Create: %w", err)
+	}
+
+	_, err = io.WriteString(part, data)
+	if err != nil {
+		return fmt.Errorf("io.WriteString: %w", err)
+	}
+
+	return nil
+}
+
+// XMLEscape returns the string escaped for XML text and attribute values.
+func xmlEscape(str string) string {
+	var buf bytes.Buffer
+
+	_ = xml.EscapeText(&buf, []byte(str)) // writing to a buffer cannot fail
+
+	return buf.String()
+}
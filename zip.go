@@ -0,0 +1,326 @@
+/*
+Copyright (C) 2025 Andrew Flint.
+
+This file is part of cas2trn.
+
+Cas2trn is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Cas2trn is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with cas2trn.  If not, see <https://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+)
+
+// ZipPasswordEnv names the environment variable that is the default zip password.
+const zipPasswordEnv = "CAS2TRN_ZIPPASSWORD"
+
+// The constants of zip archives used to decrypt their entries.
+const (
+	zipFlagEncrypted = 0x1
+	zipFlagDataDesc  = 0x8
+	zipMethodAES     = 99
+	zipExtraAES      = 0x9901
+	zipHeaderLen     = 12 // of the traditional PKWARE encryption header
+	aesAuthLen       = 10
+	aesIterations    = 1000
+	aesVerifierLen   = 2
+)
+
+var (
+	errZipAuth     = errors.New("zip archive entry failed authentication, the password may be wrong")
+	errZipExtra    = errors.New("zip archive entry has no valid AES extra field")
+	errZipMethod   = errors.New("zip archive entry compression method is not supported")
+	errZipPassword = errors.New("zip archive entry is encrypted but the password is empty string or wrong, " +
+		"see zippassword")
+)
+
+/*
+The keys of the traditional PKWARE encryption, also known as ZipCrypto.
+They are updated with each byte of plain text.
+*/
+type zipKeys [3]uint32
+
+/*
+TranslateZip translates financial transactions in each account statement in the zip archive and returns nil.
+Encrypted statements are decrypted with the zip password.
+See translateStatement.
+If it fails to decrypt or read a statement, translateZip returns an error.
+*/
+func (tlr *translator) translateZip(arc *zip.Reader) error {
+	arcName := tlr.fileName
+
+	for _, file := range arc.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		data, err := readZipFile(file, tlr.cfg.zipPassword)
+		if err != nil {
+			return fmt.Errorf("%w: %v", err, file.Name)
+		}
+
+		tlr.fileName = path.Join(arcName, file.Name)
+
+		if strings.EqualFold(path.Ext(file.Name), ".pdf") {
+			err = tlr.translatePDF(data)
+		} else {
+			err = tlr.translateStatement(bytes.NewReader(data))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ReadZipFile returns the contents of the zip archive entry and nil.
+If the entry is encrypted, it is decrypted with the password,
+using either traditional PKWARE or WinZip AES encryption.
+If readZipFile fails to read or decrypt the entry, it returns an error.
+*/
+func readZipFile(file *zip.File, password string) ([]byte, error) {
+	if file.Flags&zipFlagEncrypted == 0 {
+		rdr, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("zip.File.Open: %w", err)
+		}
+		defer rdr.Close()
+
+		return io.ReadAll(rdr)
+	}
+
+	if password == "" {
+		return nil, errZipPassword
+	}
+
+	raw, err := file.OpenRaw()
+	if err != nil {
+		return nil, fmt.Errorf("zip.File.OpenRaw: %w", err)
+	}
+
+	data, err := io.ReadAll(raw)
+	if err != nil {
+		return nil, fmt.Errorf("io.ReadAll: %w", err)
+	}
+
+	method := file.Method
+	if method == zipMethodAES {
+		method, data, err = decryptAES(file, password, data)
+	} else {
+		data, err = decryptZipCrypto(file, password, data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = decompress(method, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if file.CRC32 != 0 && crc32.ChecksumIEEE(data) != file.CRC32 {
+		return nil, errZipAuth
+	}
+
+	return data, nil
+}
+
+/*
+DecryptAES returns the compression method and data of a zip archive entry decrypted with WinZip AES and nil.
+The method is from the AES extra field,
+and the data is authenticated by its HMAC-SHA1 before it is decrypted.
+If decryptAES fails to decrypt the data, it returns an error.
+*/
+func decryptAES(file *zip.File, password string, data []byte) (uint16, []byte, error) {
+	strength, method, ok := parseAESExtra(file.Extra)
+	if !ok {
+		return 0, nil, errZipExtra
+	}
+
+	const bytesPerStrength = 8
+
+	keyLen := int(strength)*bytesPerStrength + bytesPerStrength
+	saltLen := keyLen / 2
+
+	if len(data) < saltLen+aesVerifierLen+aesAuthLen {
+		return 0, nil, errZipAuth
+	}
+
+	salt, data := data[:saltLen], data[saltLen:]
+	verifier, data := data[:aesVerifierLen], data[aesVerifierLen:]
+	data, auth := data[:len(data)-aesAuthLen], data[len(data)-aesAuthLen:]
+
+	keys, err := pbkdf2.Key(sha1.New, password, salt, aesIterations, 2*keyLen+aesVerifierLen)
+	if err != nil {
+		return 0, nil, fmt.Errorf("pbkdf2.Key: %w", err)
+	}
+
+	if !bytes.Equal(keys[2*keyLen:], verifier) {
+		return 0, nil, errZipPassword
+	}
+
+	mac := hmac.New(sha1.New, keys[keyLen:2*keyLen])
+	mac.Write(data)
+
+	if !hmac.Equal(mac.Sum(nil)[:aesAuthLen], auth) {
+		return 0, nil, errZipAuth
+	}
+
+	block, err := aes.NewCipher(keys[:keyLen])
+	if err != nil {
+		return 0, nil, fmt.Errorf("aes.NewCipher: %w", err)
+	}
+
+	// WinZip AES uses counter mode with a little-endian counter starting at one.
+	plain := make([]byte, len(data))
+	ctr, stream := make([]byte, aes.BlockSize), make([]byte, aes.BlockSize)
+
+	for inx := 0; inx < len(data); inx += aes.BlockSize {
+		binary.LittleEndian.PutUint64(ctr, uint64(inx/aes.BlockSize+1))
+		block.Encrypt(stream, ctr)
+
+		for jnx := inx; jnx < min(inx+aes.BlockSize, len(data)); jnx++ {
+			plain[jnx] = data[jnx] ^ stream[jnx-inx]
+		}
+	}
+
+	return method, plain, nil
+}
+
+/*
+DecryptZipCrypto returns the data of a zip archive entry decrypted with traditional PKWARE encryption and nil.
+The last byte of the decrypted encryption header checks the password.
+If the password is wrong, decryptZipCrypto returns an error.
+*/
+func decryptZipCrypto(file *zip.File, password string, data []byte) ([]byte, error) {
+	if len(data) < zipHeaderLen {
+		return nil, errZipAuth
+	}
+
+	keys := zipKeys{0x12345678, 0x23456789, 0x34567890}
+	for _, b := range []byte(password) {
+		keys.update(b)
+	}
+
+	plain := make([]byte, len(data))
+	for inx, b := range data {
+		plain[inx] = b ^ keys.stream()
+		keys.update(plain[inx])
+	}
+
+	// The check byte is the high byte of the CRC, or of the DOS time if the CRC follows the data.
+	const crcShift, timeShift = 24, 8
+
+	check := byte(file.CRC32 >> crcShift)
+	if file.Flags&zipFlagDataDesc != 0 {
+		check = byte(file.ModifiedTime >> timeShift)
+	}
+
+	if plain[zipHeaderLen-1] != check {
+		return nil, errZipPassword
+	}
+
+	return plain[zipHeaderLen:], nil
+}
+
+/*
+Decompress returns the data of a zip archive entry decompressed with the method and nil.
+Only the store and deflate methods are supported.
+If decompress fails, it returns an error.
+*/
+func decompress(method uint16, data []byte) ([]byte, error) {
+	switch method {
+	case zip.Store:
+		return data, nil
+	case zip.Deflate:
+		rdr := flate.NewReader(bytes.NewReader(data))
+		defer rdr.Close()
+
+		out, err := io.ReadAll(rdr)
+		if err != nil {
+			return nil, fmt.Errorf("flate.Reader.Read: %w", err)
+		}
+
+		return out, nil
+	default:
+		return nil, errZipMethod
+	}
+}
+
+/*
+ParseAESExtra returns the key strength and compression method from the WinZip AES extra field and true.
+If there is no valid AES extra field, parseAESExtra returns false.
+*/
+func parseAESExtra(extra []byte) (uint8, uint16, bool) {
+	const (
+		headerLen = 4
+		fieldLen  = 7
+	)
+
+	for headerLen <= len(extra) {
+		id, size := binary.LittleEndian.Uint16(extra), int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[headerLen:]
+
+		if len(extra) < size {
+			return 0, 0, false
+		}
+
+		if id == zipExtraAES && size == fieldLen && string(extra[2:4]) == "AE" {
+			strength := extra[4]
+
+			const maxStrength = 3 // 256-bit keys
+			if strength < 1 || maxStrength < strength {
+				return 0, 0, false
+			}
+
+			return strength, binary.LittleEndian.Uint16(extra[5:]), true
+		}
+
+		extra = extra[size:]
+	}
+
+	return 0, 0, false
+}
+
+// Stream returns the next byte of the key stream, as defined by PKWARE.
+func (keys *zipKeys) stream() byte {
+	tmp := keys[2] | 2
+
+	return byte((tmp * (tmp ^ 1)) >> 8)
+}
+
+// Update updates the keys with a byte of plain text, as defined by PKWARE.
+func (keys *zipKeys) update(b byte) {
+	const multiplier = 134775813
+
+	keys[0] = crc32.IEEETable[byte(keys[0])^b] ^ (keys[0] >> 8)
+	keys[1] = (keys[1]+uint32(byte(keys[0])))*multiplier + 1
+	keys[2] = crc32.IEEETable[byte(keys[2])^byte(keys[1]>>24)] ^ (keys[2] >> 8)
+}